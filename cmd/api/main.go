@@ -2,117 +2,203 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"malaysia-crypto-exchange-arbitrage/internal/arbitrage"
+	"malaysia-crypto-exchange-arbitrage/internal/database"
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"malaysia-crypto-exchange-arbitrage/internal/exchange/hata"
 	"malaysia-crypto-exchange-arbitrage/internal/exchange/luno"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/mxglobal"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/paper"
+	"malaysia-crypto-exchange-arbitrage/internal/fx"
+	"malaysia-crypto-exchange-arbitrage/internal/notifier"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
+	"malaysia-crypto-exchange-arbitrage/internal/rebalancer"
 	"malaysia-crypto-exchange-arbitrage/internal/server"
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
-func gracefulShutdown(fiberServer *server.FiberServer, done chan bool) {
-	// Create context that listens for the interrupt signal from the OS.
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
-
-	// Listen for the interrupt signal.
+// gracefulShutdown waits for ctx to be cancelled, then gives the server 5 seconds to finish the
+// requests it is currently handling.
+func gracefulShutdown(ctx context.Context, fiberServer *server.FiberServer) {
 	<-ctx.Done()
 
-	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	log.Println("shutting down server gracefully, press Ctrl+C again to force")
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := fiberServer.ShutdownWithContext(ctx); err != nil {
+	if err := fiberServer.ShutdownWithContext(shutdownCtx); err != nil {
 		log.Printf("Server forced to shutdown with error: %v", err)
 	}
 
 	log.Println("Server exiting")
+}
+
+func createExchanges(config *config.Config) map[string]domain.Exchanger {
+	exchanges := make(map[string]domain.Exchanger)
+
+	if config.Exchange[domain.Luno.String()].Enabled {
+		lunoEx := luno.CreateClient(config.Exchange[domain.Luno.String()].ApiKey, config.Exchange[domain.Luno.String()].ApiSecret, httpOptions(config, domain.Luno.String()))
+		exchanges[lunoEx.GetName()] = lunoEx
+	}
+	if config.Exchange[domain.Hata.String()].Enabled {
+		hataEx := hata.CreateClient(config.Exchange[domain.Hata.String()].ApiKey, config.Exchange[domain.Hata.String()].ApiSecret, httpOptions(config, domain.Hata.String()))
+		exchanges[hataEx.GetName()] = hataEx
+	}
+	if config.Exchange[domain.MXGlobal.String()].Enabled {
+		mxglobalEx := mxglobal.CreateClient(config.Exchange[domain.MXGlobal.String()].ApiKey, config.Exchange[domain.MXGlobal.String()].ApiSecret, httpOptions(config, domain.MXGlobal.String()))
+		exchanges[mxglobalEx.GetName()] = mxglobalEx
+	}
+
+	if config.Trading.Enabled && config.Trading.Paper {
+		for name, exchange := range exchanges {
+			exchanges[name] = paper.CreateClient(exchange, config.Exchange[name].TakerFee, config.Trading.Balances[name])
+		}
+	}
+
+	return exchanges
+}
+
+// httpOptions applies the exchange's configured rate limit and retries to the default HTTP client options.
+func httpOptions(config *config.Config, exchange string) httpclient.Options {
+	options := httpclient.DefaultOptions
+	if requestsPerSecond := config.Exchange[exchange].RequestsPerSecond; requestsPerSecond > 0 {
+		options.RequestsPerSecond = requestsPerSecond
+		options.Burst = max(int(requestsPerSecond), 1)
+	}
+	if maxRetries := config.Exchange[exchange].MaxRetries; maxRetries > 0 {
+		options.MaxRetries = maxRetries
+	}
+	return options
+}
 
-	// Notify the main goroutine that the shutdown is complete
-	done <- true
+func enabledPairs(config *config.Config) []string {
+	pairs := make([]string, 0)
+	for pair := range config.Market {
+		if config.Market[pair].Enabled {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
 }
 
 func main() {
+	mode := flag.String("mode", "all", "what to run: all (watcher and API server), watch (watcher only) or serve (API server only)")
+	interval := flag.Duration("interval", 30*time.Second, "how often the scheduled watcher polls the order books")
+	stream := flag.Bool("stream", false, "analyze on websocket order book updates instead of polling every interval")
+	flag.Parse()
+
+	var runWatcher, runServer bool
+	switch *mode {
+	case "all":
+		runWatcher, runServer = true, true
+	case "watch":
+		runWatcher = true
+	case "serve":
+		runServer = true
+	default:
+		log.Fatalf("invalid -mode %q, expected all, watch or serve", *mode)
+	}
+
+	// Cancelled on the first interrupt; a second one kills the process as stop restores the default handling
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	debug := true
-	if debug {
-		ctx, cancel := context.WithCancel(context.Background())
+	db := database.New()
+	defer db.Close()
 
-		config := config.GetConfig()
+	config := config.GetConfig()
+	arbitrage.Configure(config)
 
-		exchanges := make(map[string]domain.Exchanger)
+	var wg sync.WaitGroup
 
-		lunoEx := luno.CreateClient(config.Exchange["Luno"].ApiKey, config.Exchange["Luno"].ApiSecret)
-		hataEx := hata.CreateClient(config.Exchange["Hata"].ApiKey, config.Exchange["Hata"].ApiSecret)
+	if runWatcher {
+		arbitrage.Store = db
 
-		exchanges[lunoEx.GetName()] = lunoEx
-		exchanges[hataEx.GetName()] = hataEx
+		router, err := notifier.CreateRouter(config)
+		if err != nil {
+			log.Fatalf("failed to create notifiers: %v", err)
+		}
+		arbitrage.Notifier = router
 
-		pairs := make([]string, 0)
-		for pair := range config.Market {
-			if config.Market[pair].Enabled {
-				pairs = append(pairs, pair)
-			}
+		exchanges := createExchanges(config)
+		fxProvider, err := fx.CreateProvider(config, exchanges)
+		if err != nil {
+			log.Fatalf("failed to create fx provider: %v", err)
 		}
+		arbitrage.Fx = fxProvider
+
+		watcherMode := domain.Scheduled
+		if *stream {
+			watcherMode = domain.Stream
+		}
+		watcher := arbitrage.NewArbitrageScheduledWatcher(ctx, exchanges, enabledPairs(config), *interval, watcherMode)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			watcher.Start()
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arbitrage.PruneHistory(ctx, db)
+		}()
 
-		// a, _ := lunoEx.GetDepositAddress("AVAXMYR")
-		// fmt.Println(a)
-		// b, _ := hataEx.GetDepositAddress("AVAXMYR")
-		// fmt.Println(b)
-		// fee, _ := lunoEx.GetTransferFee("AVAXMYR", b, 3)
-		// fmt.Println(fee)
-
-		watcher := arbitrage.NewArbitrageScheduledWatcher(ctx, exchanges, pairs, 30*time.Second, domain.Scheduled)
-		watcher.Start()
-
-		// for pair := range config.Market {
-		// 	if config.Market[pair].Enabled {
-		// 		go arbitrage.StartWatching(ctx, pair, []domain.Exchanger{ex, ex2}, 30*time.Second)
-		// 	}
-		// }
-
-		// Set up a channel to handle system interrupts (Ctrl+C)
-		interrupt := make(chan os.Signal, 1)
-		signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
-
-		// Block until an interrupt signal is received
-		select {
-		case <-interrupt:
-			cancel()
-			fmt.Println("Interrupt signal received. Shutting down...")
+		if config.Rebalance.Enabled {
+			rebalancer := rebalancer.CreateRebalancer(config, exchanges, enabledPairs(config))
+			rebalanceInterval := time.Duration(config.Rebalance.IntervalSeconds) * time.Second
+			if rebalanceInterval <= 0 {
+				rebalanceInterval = time.Hour
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rebalancer.Start(ctx, rebalanceInterval)
+			}()
 		}
-	} else {
+	}
+
+	if runServer {
 		server := server.New()
+		server.Events = arbitrage.Events
+		server.Tracker = arbitrage.Tracker
+		server.Market = arbitrage.Market
+		server.Config = config
 
 		server.RegisterFiberRoutes()
 
-		// Create a done channel to signal when the shutdown is complete
-		done := make(chan bool, 1)
-
 		go func() {
 			port, _ := strconv.Atoi(os.Getenv("PORT"))
 			err := server.Listen(fmt.Sprintf(":%d", port))
 			if err != nil {
-				panic(fmt.Sprintf("http server error: %s", err))
+				log.Printf("http server error: %s", err)
+				stop()
 			}
 		}()
 
-		// Run graceful shutdown in a separate goroutine
-		go gracefulShutdown(server, done)
-
-		// Wait for the graceful shutdown to complete
-		<-done
-		log.Println("Graceful shutdown complete.")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gracefulShutdown(ctx, server)
+		}()
 	}
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	wg.Wait()
+	log.Println("Graceful shutdown complete.")
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"malaysia-crypto-exchange-arbitrage/internal/arbitrage"
+	"malaysia-crypto-exchange-arbitrage/internal/backtest"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "", "config file used for fees, slippage and minimums (default: the application config)")
+	pairs := flag.String("pairs", "", "comma separated pairs to replay (default: all recorded pairs)")
+	from := flag.String("from", "", "replay records at or after this RFC3339 time")
+	to := flag.String("to", "", "replay records before this RFC3339 time")
+	maxSkew := flag.Duration("max-skew", 5*time.Second, "maximum age difference between order books analyzed together")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [scraping log files...]\n\nReplays recorded order books through the arbitrage analyzer. Reads logs/scraping.log by default.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	path := *configPath
+	if path == "" {
+		path = config.DefaultPath()
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	arbitrage.Configure(cfg)
+
+	options := backtest.Options{MaxSkew: *maxSkew}
+	if *pairs != "" {
+		options.Pairs = strings.Split(*pairs, ",")
+	}
+	if *from != "" {
+		if options.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			log.Fatalf("invalid -from: %v", err)
+		}
+	}
+	if *to != "" {
+		if options.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"logs/scraping.log"}
+	}
+
+	records := make([]backtest.Record, 0)
+	skipped := 0
+	for _, file := range files {
+		fileRecords, fileSkipped, err := backtest.ReadScrapingLogFile(file)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", file, err)
+		}
+		records = append(records, fileRecords...)
+		skipped += fileSkipped
+	}
+
+	report := backtest.Replay(records, options)
+	printReport(report, skipped)
+}
+
+func printReport(report backtest.Report, skipped int) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Period\t%s - %s\n", report.First.Format(time.RFC3339), report.Last.Format(time.RFC3339))
+	fmt.Fprintf(w, "Order books replayed\t%d (%d log lines skipped)\n", report.Records, skipped)
+	fmt.Fprintf(w, "Analyzer runs\t%d\n", report.Evaluations)
+	fmt.Fprintf(w, "Opportunities fired\t%d\n", report.Opportunities)
+	fmt.Fprintf(w, "Total simulated profit\t%s\n", report.TotalProfit)
+	fmt.Fprintf(w, "Rejected by transfer minimums\t%d\n", report.BelowMinimum)
+	fmt.Fprintf(w, "Transfer fee not included (dynamic)\t%d\n", report.DynamicTransferFee)
+
+	fmt.Fprintf(w, "\nPair\tOpportunities\tProfit\n")
+	pairs := make([]string, 0, len(report.ByPair))
+	for pair := range report.ByPair {
+		pairs = append(pairs, pair)
+	}
+	slices.Sort(pairs)
+	for _, pair := range pairs {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", pair, report.ByPair[pair].Opportunities, report.ByPair[pair].Profit)
+	}
+
+	fmt.Fprintf(w, "\nHour\tOpportunities\tProfit\n")
+	for hour, bucket := range report.ByHour {
+		if bucket.Opportunities == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%02d:00\t%d\t%s\n", hour, bucket.Opportunities, bucket.Profit)
+	}
+}
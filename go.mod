@@ -3,23 +3,26 @@ module malaysia-crypto-exchange-arbitrage
 go 1.21.2
 
 require (
+	github.com/coder/websocket v1.8.12
+	github.com/disgoorg/disgo v0.18.14
 	github.com/gofiber/contrib/websocket v1.3.2
 	github.com/gofiber/fiber/v2 v2.52.5
 	github.com/joho/godotenv v1.5.1
+	github.com/luno/luno-go v0.0.32
 	github.com/mattn/go-sqlite3 v1.14.24
+	go.uber.org/zap v1.27.0
+	golang.org/x/time v0.6.0
+	gopkg.in/lumberjack.v3 v3.0.0-20201005055756-ca5a24b664f0
 )
 
 require (
 	github.com/andybalholm/brotli v1.1.1 // indirect
-	github.com/coder/websocket v1.8.12 // indirect
-	github.com/disgoorg/disgo v0.18.14 // indirect
 	github.com/disgoorg/json v1.2.0 // indirect
 	github.com/disgoorg/snowflake/v2 v2.0.3 // indirect
 	github.com/fasthttp/websocket v1.5.10 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/klauspost/compress v1.17.11 // indirect
-	github.com/luno/luno-go v0.0.32 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
@@ -30,10 +33,7 @@ require (
 	github.com/valyala/fasthttp v1.57.0 // indirect
 	github.com/valyala/tcplisten v1.0.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/crypto v0.29.0 // indirect
 	golang.org/x/net v0.31.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
-	golang.org/x/time v0.6.0 // indirect
-	gopkg.in/lumberjack.v3 v3.0.0-20201005055756-ca5a24b664f0 // indirect
 )
@@ -5,301 +5,411 @@ import (
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
+	"slices"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
 )
 
-var Config = config.GetConfig()
+// Config holds the fees, limits and policies the package runs with. It stays empty until Configure is
+// called, so loading the package never reads a config file.
+var Config = &config.Config{}
 var Logger = logger.Get()
 var ArbitrageLogger = logger.GetArbitrageLogger()
 
-func Analyze(lunoOutput domain.OrderBook, hataOutput domain.OrderBook) (output []domain.ArbitrageOpportunity, err error) {
-	if lunoOutput.Pair != hataOutput.Pair {
-		return nil, fmt.Errorf("pair mismatch: %s != %s", lunoOutput.Pair, hataOutput.Pair)
+// Capital spent per trade when no limit is configured
+var defaultMaxCapital = decimal.NewFromInt64(5000)
+
+var one = decimal.NewFromInt64(1)
+
+// tradeParameters are the configured fees, limits and precisions for buying on one exchange and
+// selling on another.
+type tradeParameters struct {
+	buyFee       decimal.Decimal // taker fee rate on the buy exchange
+	sellFee      decimal.Decimal // taker fee rate on the sell exchange
+	transferFee  decimal.Decimal // withdraw fee in the base currency
+	slippageMode domain.SlippageDetectionModeEnum
+	slippage     decimal.Decimal
+	maxCapital   decimal.Decimal
+	maxVolume    decimal.Decimal // Inventory mode: most base currency the sell exchange holds, zero for no limit
+	baseScale    int
+	quoteScale   int
+}
+
+// settlement is what the exchanges charge and pay out for a trade, rounded to the quote precision.
+type settlement struct {
+	buyFee         decimal.Decimal
+	totalBuyPrice  decimal.Decimal
+	sellFee        decimal.Decimal
+	totalSellPrice decimal.Decimal
+	transferFee    decimal.Decimal
+	netProfit      decimal.Decimal
+}
+
+// Analyze evaluates every directed buy/sell exchange pair across the given
+// order books and returns the opportunities ranked by net profit, highest first.
+// Pairs in Inventory mode are sized by the balances, which are otherwise unused.
+func Analyze(balances domain.Balances, orderbooks ...domain.OrderBook) (output []domain.ArbitrageOpportunity, err error) {
+	if len(orderbooks) < 2 {
+		return nil, fmt.Errorf("at least two order books are required, got %d", len(orderbooks))
+	}
+	for _, orderbook := range orderbooks[1:] {
+		if orderbook.Pair != orderbooks[0].Pair {
+			return nil, fmt.Errorf("pair mismatch: %s != %s", orderbooks[0].Pair, orderbook.Pair)
+		}
+	}
+
+	for i, buyOrderbook := range orderbooks {
+		for j, sellOrderbook := range orderbooks {
+			if i == j {
+				continue
+			}
+
+			arbitrageOpportunity, err := analyze(buyOrderbook, sellOrderbook, balances)
+			if err != nil {
+				Logger.Error(fmt.Sprintf("Failed to analyze %s buying on %s and selling on %s: %s", buyOrderbook.Pair, buyOrderbook.Exchange, sellOrderbook.Exchange, err.Error()))
+				continue
+			}
+			if arbitrageOpportunity != nil {
+				output = append(output, *arbitrageOpportunity)
+			}
+		}
 	}
-	return analyze(lunoOutput, hataOutput)
+
+	slices.SortStableFunc(output, func(a, b domain.ArbitrageOpportunity) int {
+		return b.NetProfit.Cmp(a.NetProfit)
+	})
+
+	return output, nil
 }
 
-func analyze(firstExchangePrice domain.OrderBook, secondExchangePrice domain.OrderBook) (output []domain.ArbitrageOpportunity, err error) {
+// analyze checks a single direction: buying on buyOrderbook and selling on sellOrderbook. Books taken
+// further apart than MaxOrderBookSkewSeconds are still analyzed, the opportunity is flagged Skewed.
+// It returns nil when the lowest ask on the buy side is not below the highest bid on the sell side,
+// or in Inventory mode when either exchange holds nothing to trade with.
+func analyze(buyOrderbook domain.OrderBook, sellOrderbook domain.OrderBook, balances domain.Balances) (*domain.ArbitrageOpportunity, error) {
+	if len(buyOrderbook.Asks) == 0 || len(sellOrderbook.Bids) == 0 {
+		return nil, nil
+	}
+
 	// Extract prices from exchange outputs
-	firstExchangeAskPrice := firstExchangePrice.Asks[0].Price
-	firstExchangeBidPrice := firstExchangePrice.Bids[0].Price
-	secondExchangeAskPrice := secondExchangePrice.Asks[0].Price
-	secondExchangeBidPrice := secondExchangePrice.Bids[0].Price
+	buyExchangeAskPrice := buyOrderbook.Asks[0].Price
+	sellExchangeBidPrice := sellOrderbook.Bids[0].Price
+
+	// Buy exchange ask must be lower than sell exchange bid
+	if buyExchangeAskPrice.Cmp(sellExchangeBidPrice) >= 0 {
+		return nil, nil
+	}
+
+	baseScale, quoteScale, err := pairScales(buyOrderbook.Pair)
+	if err != nil {
+		return nil, err
+	}
+	base, quote, err := domain.SplitPair(buyOrderbook.Pair)
+	if err != nil {
+		return nil, err
+	}
+	mode := Config.Arbitrage[buyOrderbook.Pair].Mode
+	buyExchange, sellExchange := buyOrderbook.Exchange.String(), sellOrderbook.Exchange.String()
 
 	// Calculate fees
-	firstExchangeTakerFee := Config.Exchange[firstExchangePrice.Exchange.String()].TakerFee
-	secondExchangeTakerFee := Config.Exchange[secondExchangePrice.Exchange.String()].TakerFee
-	pairTransferFee := Config.Exchange[firstExchangePrice.Exchange.String()].Crypto[firstExchangePrice.Pair].WithdrawFee //this transfer fee is used for estimating the amount we need to sell, if the transfer fee cannot be determined before the calculation we will deduct it in the next step
-	slippage := Config.Arbitrage[firstExchangePrice.Pair].Slippage
-	slippageMode := Config.Arbitrage[firstExchangePrice.Pair].SlippageMode
-
-	var realPairTransferFee float32 = 0
-	if pairTransferFee != -1 {
+	pairTransferFee := Config.Exchange[buyExchange].Crypto[buyOrderbook.Pair].WithdrawFee //this transfer fee is used for estimating the amount we need to sell, if the transfer fee cannot be determined before the calculation we will deduct it in the next step
+	isDynamicTransferFee := pairTransferFee.Sign() < 0                                    // configured as -1
+	realPairTransferFee := decimal.Zero()
+	if !isDynamicTransferFee {
 		realPairTransferFee = pairTransferFee
 	}
+	if mode == domain.Inventory {
+		// Both legs trade from balances already on each exchange, nothing is withdrawn
+		isDynamicTransferFee = false
+		realPairTransferFee = decimal.Zero()
+	}
 
-	// Calculate price differences
-	firstExchangePriceDiff := firstExchangeAskPrice - secondExchangeBidPrice  //1042 - 1039
-	secondExchangePriceDiff := secondExchangeAskPrice - firstExchangeBidPrice //1040 - 1041
-
-	// Determine best arbitrage opportunity
-	if secondExchangePriceDiff < 0 {
-		// Second exchange ask is lower than first exchange bid - consider buying on second exchange and selling on first exchange
-		buyOrders, sellOrders, err := generatePotentialLimitOrder(secondExchangePrice, firstExchangePrice, realPairTransferFee, slippageMode, slippage)
-		if err != nil {
-			return nil, err
-		}
-		Logger.Info(secondExchangePrice.Pair + " BuyOrders: " + fmt.Sprintf("%v", buyOrders))
-		Logger.Info(secondExchangePrice.Pair + " SellOrders: " + fmt.Sprintf("%v", sellOrders))
-
-		// Calculate weighted average prices and totals from orders
-		var totalBuyVolume, totalBuyAmount float32
-		for _, order := range buyOrders {
-			totalBuyVolume += order.Volume
-			totalBuyAmount += order.Price * order.Volume
-		}
-		buyPrice := totalBuyAmount / totalBuyVolume       // Average price per unit
-		buyFee := totalBuyAmount * secondExchangeTakerFee // Platform fee
-		totalBuyPrice := totalBuyAmount + buyFee          // Total cost including fees
-
-		var totalSellVolume, totalSellAmount float32
-		for _, order := range sellOrders {
-			totalSellVolume += order.Volume
-			totalSellAmount += order.Price * order.Volume
-		}
-		sellPrice := totalSellAmount / totalSellVolume     // Average price per unit
-		sellFee := totalSellAmount * firstExchangeTakerFee // Platform fee
-		totalSellPrice := totalSellAmount - sellFee        // Total revenue after fees
-
-		priceDiff := sellPrice - buyPrice // Difference in price per unit
-
-		arbitrageOpportunity := &domain.ArbitrageOpportunity{
-			Pair:                 firstExchangePrice.Pair,
-			BuyOn:                secondExchangePrice.Exchange.String(),
-			SellOn:               firstExchangePrice.Exchange.String(),
-			BuyPrice:             buyPrice,
-			BuyVolume:            totalBuyVolume,
-			BuyFee:               buyFee,
-			SellPrice:            sellPrice,
-			SellVolume:           totalSellVolume,
-			SellFee:              sellFee,
-			PriceDiff:            priceDiff,
-			TotalBuyPrice:        totalBuyPrice,
-			TotalSellPrice:       totalSellPrice,
-			NativeTransferFee:    realPairTransferFee,
-			TransferFee:          realPairTransferFee * buyPrice,
-			NetProfit:            totalSellPrice - totalBuyPrice - realPairTransferFee*buyPrice,
-			BuyOrders:            buyOrders,
-			SellOrders:           sellOrders,
-			IsDynamicTransferFee: pairTransferFee == -1,
+	params := tradeParameters{
+		buyFee:       Config.Exchange[buyExchange].TakerFee,
+		sellFee:      Config.Exchange[sellExchange].TakerFee,
+		transferFee:  realPairTransferFee,
+		slippageMode: Config.Arbitrage[buyOrderbook.Pair].SlippageMode,
+		slippage:     Config.Arbitrage[buyOrderbook.Pair].Slippage,
+		maxCapital:   capitalLimit(buyOrderbook.Pair, buyExchange),
+		baseScale:    baseScale,
+		quoteScale:   quoteScale,
+	}
+	if mode == domain.Inventory {
+		quoteBalance := balances.Get(buyExchange, quote)
+		baseBalance := balances.Get(sellExchange, base)
+		if quoteBalance.Sign() <= 0 || baseBalance.Sign() <= 0 {
+			return nil, nil
 		}
+		// The buy fee is charged on top of the amount spent, so keep room for it in the balance
+		params.maxCapital = domain.MinDecimal(params.maxCapital, quoteBalance.Div(one.Add(params.buyFee), quoteScale))
+		params.maxVolume = baseBalance
+	}
 
-		arbitrageOpportunity.Profitable = arbitrageOpportunity.NetProfit > 0
-
-		// if arbitrageOpportunity.Profitable {
-		output = append(output, *arbitrageOpportunity)
-		// }
+	buyOrders, sellOrders, profitCurve, err := generatePotentialLimitOrder(buyOrderbook, sellOrderbook, params)
+	if err != nil {
+		return nil, err
 	}
-	if firstExchangePriceDiff < 0 {
-		// First exchange ask is lower than second exchange bid - consider buying on first exchange and selling on second exchange
-		buyOrders, sellOrders, err := generatePotentialLimitOrder(firstExchangePrice, secondExchangePrice, realPairTransferFee, slippageMode, slippage)
-		if err != nil {
-			return nil, err
-		}
-		Logger.Info(firstExchangePrice.Pair + " BuyOrders: " + fmt.Sprintf("%v", buyOrders))
-		Logger.Info(firstExchangePrice.Pair + " SellOrders: " + fmt.Sprintf("%v", sellOrders))
-
-		// Calculate weighted average prices and totals from orders
-		var totalBuyVolume, totalBuyAmount float32
-		for _, order := range buyOrders {
-			totalBuyVolume += order.Volume
-			totalBuyAmount += order.Price * order.Volume
-		}
-		buyPrice := totalBuyAmount / totalBuyVolume      // Average price per unit
-		buyFee := totalBuyAmount * firstExchangeTakerFee // Platform fee
-		totalBuyPrice := totalBuyAmount + buyFee         // Total cost including fees
-
-		var totalSellVolume, totalSellAmount float32
-		for _, order := range sellOrders {
-			totalSellVolume += order.Volume
-			totalSellAmount += order.Price * order.Volume
-		}
-		sellPrice := totalSellAmount / totalSellVolume      // Average price per unit
-		sellFee := totalSellAmount * secondExchangeTakerFee // Platform fee
-		totalSellPrice := totalSellAmount - sellFee         // Total revenue after fees
-
-		priceDiff := sellPrice - buyPrice // Difference in price per unit
-
-		arbitrageOpportunity := &domain.ArbitrageOpportunity{
-			Pair:                 firstExchangePrice.Pair,
-			BuyOn:                firstExchangePrice.Exchange.String(),
-			SellOn:               secondExchangePrice.Exchange.String(),
-			BuyPrice:             buyPrice,
-			BuyVolume:            totalBuyVolume,
-			BuyFee:               buyFee,
-			SellPrice:            sellPrice,
-			SellVolume:           totalSellVolume,
-			SellFee:              sellFee,
-			PriceDiff:            priceDiff,
-			TotalBuyPrice:        totalBuyPrice,
-			TotalSellPrice:       totalSellPrice,
-			NativeTransferFee:    realPairTransferFee,
-			TransferFee:          realPairTransferFee * buyPrice,
-			NetProfit:            totalSellPrice - totalBuyPrice - realPairTransferFee*buyPrice,
-			BuyOrders:            buyOrders,
-			SellOrders:           sellOrders,
-			IsDynamicTransferFee: pairTransferFee == -1,
-		}
+	Logger.Info(buyOrderbook.Pair + " BuyOrders: " + fmt.Sprintf("%v", buyOrders))
+	Logger.Info(buyOrderbook.Pair + " SellOrders: " + fmt.Sprintf("%v", sellOrders))
+
+	// Calculate weighted average prices and totals from orders
+	totalBuyVolume, totalBuyAmount := sumPriceLevels(buyOrders)
+	buyPrice := domain.Quo(totalBuyAmount, totalBuyVolume, domain.DefaultScale) // Average price per unit
+
+	totalSellVolume, totalSellAmount := sumPriceLevels(sellOrders)
+	sellPrice := domain.Quo(totalSellAmount, totalSellVolume, domain.DefaultScale) // Average price per unit
+
+	trade := settle(totalBuyAmount, totalSellAmount, buyPrice, params)
+
+	arbitrageOpportunity := &domain.ArbitrageOpportunity{
+		Pair:                 buyOrderbook.Pair,
+		BuyOn:                buyOrderbook.Exchange.String(),
+		SellOn:               sellOrderbook.Exchange.String(),
+		BuyPrice:             buyPrice,
+		BuyVolume:            totalBuyVolume,
+		BuyFee:               trade.buyFee,
+		SellPrice:            sellPrice,
+		SellVolume:           totalSellVolume,
+		SellFee:              trade.sellFee,
+		PriceDiff:            sellPrice.Sub(buyPrice), // Difference in price per unit
+		TotalBuyPrice:        trade.totalBuyPrice,
+		TotalSellPrice:       trade.totalSellPrice,
+		NativeTransferFee:    realPairTransferFee,
+		TransferFee:          trade.transferFee,
+		NetProfit:            trade.netProfit,
+		BuyOrders:            buyOrders,
+		SellOrders:           sellOrders,
+		OptimalVolume:        totalBuyVolume,
+		ProfitCurve:          profitCurve,
+		IsDynamicTransferFee: isDynamicTransferFee,
+		DetectedAt:           time.Now(),
+		Mode:                 mode,
+		BookSkew:             domain.GetSkew(buyOrderbook, sellOrderbook),
+	}
+	arbitrageOpportunity.Skewed = Config.MaxOrderBookSkewSeconds > 0 && arbitrageOpportunity.BookSkew > seconds(Config.MaxOrderBookSkewSeconds)
 
-		arbitrageOpportunity.Profitable = arbitrageOpportunity.NetProfit > 0
+	arbitrageOpportunity.Profitable = arbitrageOpportunity.NetProfit.Sign() > 0
 
-		// if arbitrageOpportunity.Profitable {
-		output = append(output, *arbitrageOpportunity)
-		// }
+	if mode == domain.Inventory {
+		arbitrageOpportunity.BalancesAfter, arbitrageOpportunity.InventoryImbalance = balancesAfterTrade(balances, arbitrageOpportunity, base, quote)
 	}
 
-	return output, nil
-
+	return arbitrageOpportunity, nil
 }
 
-func generatePotentialLimitOrder(buyOrderbook domain.OrderBook, sellOrderbook domain.OrderBook, transferFee float32, slippageMode domain.SlippageDetectionModeEnum, slippage float32) (buyOrder []domain.PriceLevel, sellOrder []domain.PriceLevel, err error) {
-	const maxMYR float32 = 5000
+// balancesAfterTrade returns the buy and sell exchange balances once both legs of an Inventory mode
+// trade fill, and the share of their combined base currency left on the buy exchange.
+func balancesAfterTrade(balances domain.Balances, opportunity *domain.ArbitrageOpportunity, base string, quote string) (after domain.Balances, imbalance decimal.Decimal) {
+	after = domain.Balances{
+		opportunity.BuyOn: {
+			base:  balances.Get(opportunity.BuyOn, base).Add(opportunity.BuyVolume),
+			quote: balances.Get(opportunity.BuyOn, quote).Sub(opportunity.TotalBuyPrice),
+		},
+		opportunity.SellOn: {
+			base:  balances.Get(opportunity.SellOn, base).Sub(opportunity.SellVolume),
+			quote: balances.Get(opportunity.SellOn, quote).Add(opportunity.TotalSellPrice),
+		},
+	}
 
-	// Step 1: Find asks within slippage
-	lowestAskPrice := buyOrderbook.Asks[0].Price
-	var maxAskPrice float32
-	if slippageMode == domain.Price {
-		maxAskPrice = lowestAskPrice + slippage
-	} else {
-		maxAskPrice = lowestAskPrice * (1 + slippage)
+	totalBase := after[opportunity.BuyOn][base].Add(after[opportunity.SellOn][base])
+	if totalBase.Sign() > 0 {
+		imbalance = domain.Quo(after[opportunity.BuyOn][base], totalBase, 4)
 	}
+	return after, imbalance
+}
 
-	var eligibleAsks []domain.PriceLevel
-	var totalBuyAmount float32
+// seconds converts a configured number of seconds, possibly fractional, into a duration.
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
 
-	for _, ask := range buyOrderbook.Asks {
-		if ask.Price > maxAskPrice {
-			break
-		}
+// pairScales returns the configured precision of the pair's base and quote currencies.
+func pairScales(pair string) (baseScale int, quoteScale int, err error) {
+	base, quote, err := domain.SplitPair(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+	return Config.GetScale(base), Config.GetScale(quote), nil
+}
 
-		orderAmount := ask.Price * ask.Volume
-		if totalBuyAmount+orderAmount > maxMYR {
-			// Calculate partial volume that fits within maxMYR
-			remainingMYR := maxMYR - totalBuyAmount
-			partialVolume := remainingMYR / ask.Price
-			eligibleAsks = append(eligibleAsks, domain.PriceLevel{
-				Price:  ask.Price,
-				Volume: partialVolume,
-			})
-			break
-		}
+// settle rounds a trade the way the exchanges settle it: costs and fees are rounded up and proceeds
+// down to the quote precision, so the reported profit is never better than the realized one.
+func settle(buyAmount decimal.Decimal, sellAmount decimal.Decimal, buyPrice decimal.Decimal, params tradeParameters) (trade settlement) {
+	trade.buyFee = domain.RoundUp(buyAmount.Mul(params.buyFee), params.quoteScale)
+	trade.totalBuyPrice = domain.RoundUp(buyAmount, params.quoteScale).Add(trade.buyFee) // Total cost including fees
+	trade.sellFee = domain.RoundUp(sellAmount.Mul(params.sellFee), params.quoteScale)
+	trade.totalSellPrice = domain.RoundDown(sellAmount, params.quoteScale).Sub(trade.sellFee) // Total revenue after fees
+	trade.transferFee = transferCost(params.transferFee, buyPrice, params.quoteScale)
+	trade.netProfit = trade.totalSellPrice.Sub(trade.totalBuyPrice).Sub(trade.transferFee)
+	return trade
+}
 
-		eligibleAsks = append(eligibleAsks, ask)
-		totalBuyAmount += orderAmount
+// transferCost values a withdraw fee paid in the base currency at the buy price.
+func transferCost(nativeTransferFee decimal.Decimal, buyPrice decimal.Decimal, quoteScale int) decimal.Decimal {
+	return domain.RoundUp(nativeTransferFee.Mul(buyPrice), quoteScale)
+}
+
+// sumPriceLevels returns the total volume and the total quote amount of the levels.
+func sumPriceLevels(levels []domain.PriceLevel) (volume decimal.Decimal, amount decimal.Decimal) {
+	volume, amount = decimal.Zero(), decimal.Zero()
+	for _, level := range levels {
+		volume = volume.Add(level.Volume)
+		amount = amount.Add(level.Price.Mul(level.Volume))
 	}
+	return volume, amount
+}
 
-	if len(eligibleAsks) == 0 {
-		return buyOrder, sellOrder, fmt.Errorf("no eligible asks found within slippage")
+// capitalLimit returns the most quote currency a single trade may spend buying the pair on the exchange:
+// the smallest of the configured pair, exchange and global limits, or defaultMaxCapital when none is set.
+func capitalLimit(pair string, buyExchange string) decimal.Decimal {
+	limits := []decimal.Decimal{Config.Arbitrage[pair].MaxCapital, Config.Exchange[buyExchange].MaxCapital, Config.MaxCapital}
+
+	maxCapital := decimal.Zero()
+	for _, limit := range limits {
+		if limit.Sign() > 0 && (maxCapital.Sign() == 0 || limit.Cmp(maxCapital) < 0) {
+			maxCapital = limit
+		}
 	}
+	if maxCapital.Sign() == 0 {
+		return defaultMaxCapital
+	}
+
+	return maxCapital
+}
 
-	// Step 2: Find matching bids within slippage
+// tradeStep is a slice of volume matched between one ask level and one bid level.
+type tradeStep struct {
+	askPrice decimal.Decimal
+	bidPrice decimal.Decimal
+	volume   decimal.Decimal
+}
+
+// generatePotentialLimitOrder walks the asks of the buy order book and the bids of the sell order book
+// level by level, within the slippage band and the capital limit, and sizes the trade at the volume
+// with the highest net profit. Every matched unit earns bid*(1-sellFee) - ask*(1+buyFee); the walk stops
+// at the first level where that is no longer positive, and the fixed transfer cost is charged on top.
+// The profit curve holds the net profit after each matched step. When even the best levels lose money
+// the top of both books is returned so that the loss can still be reported.
+func generatePotentialLimitOrder(buyOrderbook domain.OrderBook, sellOrderbook domain.OrderBook, params tradeParameters) (buyOrder []domain.PriceLevel, sellOrder []domain.PriceLevel, profitCurve []domain.ProfitPoint, err error) {
+	// Step 1: Find asks and bids within slippage
+	lowestAskPrice := buyOrderbook.Asks[0].Price
 	highestBidPrice := sellOrderbook.Bids[0].Price
-	var minBidPrice float32
-	if slippageMode == domain.Price {
-		minBidPrice = highestBidPrice - slippage
+	var maxAskPrice, minBidPrice decimal.Decimal
+	if params.slippageMode == domain.Price {
+		maxAskPrice = lowestAskPrice.Add(params.slippage)
+		minBidPrice = highestBidPrice.Sub(params.slippage)
 	} else {
-		minBidPrice = highestBidPrice * (1 - slippage)
+		maxAskPrice = lowestAskPrice.Mul(one.Add(params.slippage))
+		minBidPrice = highestBidPrice.Mul(one.Sub(params.slippage))
+	}
+
+	var eligibleAsks []domain.PriceLevel
+	for _, ask := range buyOrderbook.Asks {
+		if ask.Price.Cmp(maxAskPrice) > 0 {
+			break
+		}
+		eligibleAsks = append(eligibleAsks, ask)
 	}
 
 	var eligibleBids []domain.PriceLevel
 	for _, bid := range sellOrderbook.Bids {
-		if bid.Price < minBidPrice {
+		if bid.Price.Cmp(minBidPrice) < 0 {
 			break
 		}
 		eligibleBids = append(eligibleBids, bid)
 	}
 
+	if len(eligibleAsks) == 0 {
+		return nil, nil, nil, fmt.Errorf("no eligible asks found within slippage")
+	}
 	if len(eligibleBids) == 0 {
-		return buyOrder, sellOrder, fmt.Errorf("no eligible bids found within slippage")
+		return nil, nil, nil, fmt.Errorf("no eligible bids found within slippage")
 	}
 
-	// Step 3: Calculate total crypto amount after buying & transfer fee
-	var totalCryptoAmount float32
-	for _, ask := range eligibleAsks {
-		totalCryptoAmount += ask.Volume
-	}
-	totalCryptoAmount = totalCryptoAmount - transferFee
+	// Step 2: Match asks against bids while the marginal unit is profitable and capital remains
+	steps := make([]tradeStep, 0)
+	profitCurve = make([]domain.ProfitPoint, 0)
+	totalVolume, totalBuyAmount, totalSellAmount := decimal.Zero(), decimal.Zero(), decimal.Zero()
+	askIndex, bidIndex := 0, 0
+	askRemaining, bidRemaining := eligibleAsks[0].Volume, eligibleBids[0].Volume
 
-	// Step 4: Match with bid orders
-	var totalBidVolume float32
-	var finalBidVolume float32
+	for askIndex < len(eligibleAsks) && bidIndex < len(eligibleBids) {
+		ask := eligibleAsks[askIndex]
+		bid := eligibleBids[bidIndex]
 
-	for _, bid := range eligibleBids {
-		if totalBidVolume+bid.Volume >= totalCryptoAmount {
-			// Case 4.1: Current bid order completes the total crypto amount
-			// remainingVolume := totalCryptoAmount - totalBidVolume
-			finalBidVolume = totalCryptoAmount
+		marginalProfit := bid.Price.Mul(one.Sub(params.sellFee)).Sub(ask.Price.Mul(one.Add(params.buyFee)))
+		if marginalProfit.Sign() <= 0 && len(steps) > 0 {
 			break
 		}
-		// Case 4.2: Need more bid orders
-		totalBidVolume += bid.Volume
-		finalBidVolume = totalBidVolume
-	}
 
-	if finalBidVolume < totalCryptoAmount {
-		// Adjust buy amount to match available sell volume
-		totalCryptoAmount = finalBidVolume
-	}
+		// The capital bound volume is truncated to the base precision so the order never exceeds it
+		volume := domain.MinDecimal(askRemaining, bidRemaining, params.maxCapital.Sub(totalBuyAmount).Div(ask.Price, params.baseScale))
+		if params.maxVolume.Sign() > 0 {
+			volume = domain.MinDecimal(volume, params.maxVolume.Sub(totalVolume))
+		}
+		if volume.Sign() <= 0 {
+			break
+		}
 
-	// Create buy orders from eligible asks
-	buyOrder = make([]domain.PriceLevel, 0)
-	var accumulatedBuyVolume float32
-	for _, ask := range eligibleAsks {
-		if accumulatedBuyVolume >= totalCryptoAmount {
+		steps = append(steps, tradeStep{askPrice: ask.Price, bidPrice: bid.Price, volume: volume})
+		totalVolume = totalVolume.Add(volume)
+		totalBuyAmount = totalBuyAmount.Add(ask.Price.Mul(volume))
+		totalSellAmount = totalSellAmount.Add(bid.Price.Mul(volume))
+		trade := settle(totalBuyAmount, totalSellAmount, domain.Quo(totalBuyAmount, totalVolume, domain.DefaultScale), params)
+		profitCurve = append(profitCurve, domain.ProfitPoint{
+			Volume:    totalVolume,
+			NetProfit: trade.netProfit,
+		})
+
+		if marginalProfit.Sign() <= 0 {
 			break
 		}
 
-		var volume float32
-		if accumulatedBuyVolume+ask.Volume > totalCryptoAmount {
-			volume = totalCryptoAmount - accumulatedBuyVolume
-		} else {
-			volume = ask.Volume
+		askRemaining = askRemaining.Sub(volume)
+		bidRemaining = bidRemaining.Sub(volume)
+		if askRemaining.Sign() <= 0 {
+			askIndex++
+			if askIndex < len(eligibleAsks) {
+				askRemaining = eligibleAsks[askIndex].Volume
+			}
+		}
+		if bidRemaining.Sign() <= 0 {
+			bidIndex++
+			if bidIndex < len(eligibleBids) {
+				bidRemaining = eligibleBids[bidIndex].Volume
+			}
 		}
+	}
 
-		buyOrder = append(buyOrder, domain.PriceLevel{
-			Price:  ask.Price,
-			Volume: volume,
-		})
-		accumulatedBuyVolume += volume
+	if len(steps) == 0 {
+		return nil, nil, nil, fmt.Errorf("no volume available within capital limit %v", params.maxCapital)
 	}
 
-	// Create sell orders from eligible bids
-	sellOrder = make([]domain.PriceLevel, 0)
-	var accumulatedSellVolume float32
-	for _, bid := range eligibleBids {
-		if accumulatedSellVolume >= totalCryptoAmount {
-			break
+	// Step 3: Size the trade at the most profitable point of the curve. The transfer cost grows with the
+	// average buy price, so the last profitable step is not necessarily the best one.
+	best := 0
+	for i, point := range profitCurve {
+		if point.NetProfit.Cmp(profitCurve[best].NetProfit) > 0 {
+			best = i
 		}
+	}
 
-		var volume float32
-		if accumulatedSellVolume+bid.Volume > totalCryptoAmount {
-			volume = totalCryptoAmount - accumulatedSellVolume
+	// Step 4: Merge the matched steps back into one limit order per price level
+	buyOrder = make([]domain.PriceLevel, 0)
+	sellOrder = make([]domain.PriceLevel, 0)
+	for _, step := range steps[:best+1] {
+		if len(buyOrder) > 0 && buyOrder[len(buyOrder)-1].Price.Cmp(step.askPrice) == 0 {
+			buyOrder[len(buyOrder)-1].Volume = buyOrder[len(buyOrder)-1].Volume.Add(step.volume)
 		} else {
-			volume = bid.Volume
+			buyOrder = append(buyOrder, domain.PriceLevel{Price: step.askPrice, Volume: step.volume})
+		}
+		if len(sellOrder) > 0 && sellOrder[len(sellOrder)-1].Price.Cmp(step.bidPrice) == 0 {
+			sellOrder[len(sellOrder)-1].Volume = sellOrder[len(sellOrder)-1].Volume.Add(step.volume)
+		} else {
+			sellOrder = append(sellOrder, domain.PriceLevel{Price: step.bidPrice, Volume: step.volume})
 		}
-
-		sellOrder = append(sellOrder, domain.PriceLevel{
-			Price:  bid.Price,
-			Volume: volume,
-		})
-		accumulatedSellVolume += volume
 	}
 
-	return buyOrder, sellOrder, nil
-	//Step 2: Get the matched bid order in the sell orderbook considering the slippage. (This is to get the total volume we can sell)
-	//Step 3: Get the total crypto amount after buying & deducting the transfer fee.
-	//Step 4: From the matched bid order
-	//Step 4.1: If total volume of bid order is more than the total crypto amount, then we just generate the limit order
-	//Step 4.2: If total volume of bid order is less than the total crypto amount, then we need to adjust the total crypto amount we can buy and find the volume of ask order that matches the total crypto amount, and generate the limit order
-	//Step 5: Find the average price * volume of the asks.
-
+	return buyOrder, sellOrder, profitCurve, nil
 }
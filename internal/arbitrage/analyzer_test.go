@@ -0,0 +1,325 @@
+package arbitrage
+
+import (
+	"encoding/json"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// configure runs the package with the config parsed from the JSON, restoring the empty config after the test.
+
+func configure(t *testing.T, configJson string) {
+	t.Helper()
+	var cfg config.Config
+	if err := json.Unmarshal([]byte(configJson), &cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	Configure(&cfg)
+	t.Cleanup(func() { Configure(&config.Config{}) })
+}
+
+// levels builds price levels from price and volume pairs.
+
+func levels(priceVolumes ...string) []domain.PriceLevel {
+	levels := make([]domain.PriceLevel, 0, len(priceVolumes)/2)
+	for i := 0; i < len(priceVolumes); i += 2 {
+		levels = append(levels, domain.PriceLevel{Price: dec(priceVolumes[i]), Volume: dec(priceVolumes[i+1])})
+	}
+	return levels
+}
+
+func TestAnalyzeRanksByNetProfit(t *testing.T) {
+	configure(t, `{"Precision": {"MYR": 2, "SOL": 4}}`)
+
+	opportunities, err := Analyze(nil,
+		domain.OrderBook{Exchange: domain.Luno, Pair: "SOLMYR", Asks: levels("1040", "1"), Bids: levels("1030", "1")},
+		domain.OrderBook{Exchange: domain.Hata, Pair: "SOLMYR", Asks: levels("1000", "1"), Bids: levels("995", "1")},
+		domain.OrderBook{Exchange: domain.MXGlobal, Pair: "SOLMYR", Asks: levels("1020", "1"), Bids: levels("1015", "1")},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only the directions whose lowest ask is below the other exchange's highest bid are analyzed
+	want := []struct {
+		buyOn     string
+		sellOn    string
+		netProfit string
+	}{
+		{"Hata", "Luno", "30"},
+		{"Hata", "MXGlobal", "15"},
+		{"MXGlobal", "Luno", "10"},
+	}
+	if len(opportunities) != len(want) {
+		t.Fatalf("expected %d opportunities; got %+v", len(want), opportunities)
+	}
+	for i, opportunity := range opportunities {
+		if opportunity.BuyOn != want[i].buyOn || opportunity.SellOn != want[i].sellOn || opportunity.NetProfit.Cmp(dec(want[i].netProfit)) != 0 {
+			t.Errorf("expected opportunity %d buying on %s and selling on %s for %s; got %s to %s for %v",
+				i, want[i].buyOn, want[i].sellOn, want[i].netProfit, opportunity.BuyOn, opportunity.SellOn, opportunity.NetProfit)
+		}
+	}
+}
+
+func TestAnalyzeRejectsInvalidOrderBooks(t *testing.T) {
+	configure(t, `{}`)
+
+	tests := []struct {
+		name       string
+		orderbooks []domain.OrderBook
+	}{
+		{"single order book", []domain.OrderBook{{Exchange: domain.Luno, Pair: "SOLMYR"}}},
+		{"pair mismatch", []domain.OrderBook{{Exchange: domain.Luno, Pair: "SOLMYR"}, {Exchange: domain.Hata, Pair: "AVAXMYR"}}},
+	}
+	for _, test := range tests {
+		if _, err := Analyze(nil, test.orderbooks...); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestCapitalLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		pair       string
+		exchange   string
+		maxCapital string
+	}{
+		{"no limit", `{}`, "SOLMYR", "Hata", "5000"},
+		{"pair limit", `{"Arbitrage": {"SOLMYR": {"MaxCapital": 3000}}, "MaxCapital": 4000}`, "SOLMYR", "Hata", "3000"},
+		{"exchange limit", `{"Arbitrage": {"SOLMYR": {"MaxCapital": 3000}}, "Exchange": {"Hata": {"MaxCapital": 2000}}}`, "SOLMYR", "Hata", "2000"},
+		{"other exchange", `{"Arbitrage": {"SOLMYR": {"MaxCapital": 3000}}, "Exchange": {"Hata": {"MaxCapital": 2000}}}`, "SOLMYR", "Luno", "3000"},
+		{"global limit", `{"Arbitrage": {"SOLMYR": {"MaxCapital": 3000}}, "MaxCapital": 1000}`, "SOLMYR", "Hata", "1000"},
+		{"other pair", `{"Arbitrage": {"SOLMYR": {"MaxCapital": 3000}}, "MaxCapital": 4000}`, "AVAXMYR", "Hata", "4000"},
+	}
+	for _, test := range tests {
+		configure(t, test.config)
+		if got := capitalLimit(test.pair, test.exchange); got.Cmp(dec(test.maxCapital)) != 0 {
+			t.Errorf("%s: expected %s; got %v", test.name, test.maxCapital, got)
+		}
+	}
+}
+
+func TestAnalyzeSizesTradeAtBestProfit(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		buyVolume   string
+		netProfit   string
+		profitCurve []string
+	}{
+		{
+			name:        "every profitable level",
+			config:      `{"Arbitrage": {"SOLMYR": {"SlippageMode": 1, "Slippage": 0.5}}, "Precision": {"MYR": 2, "SOL": 4}}`,
+			buyVolume:   "2",
+			netProfit:   "31",
+			profitCurve: []string{"30", "31"},
+		},
+		{
+			// The transfer cost grows with the average buy price faster than the second level earns
+			name: "transfer cost outgrowing the last level",
+			config: `{
+				"Arbitrage": {"SOLMYR": {"SlippageMode": 1, "Slippage": 0.5}},
+				"Exchange": {"Hata": {"Crypto": {"SOLMYR": {"WithdrawFee": 0.1}}}},
+				"Precision": {"MYR": 2, "SOL": 4}
+			}`,
+			buyVolume:   "1",
+			netProfit:   "20",
+			profitCurve: []string{"20", "19.55"},
+		},
+		{
+			name:        "capital limit",
+			config:      `{"Arbitrage": {"SOLMYR": {"SlippageMode": 1, "Slippage": 0.5, "MaxCapital": 150}}, "Precision": {"MYR": 2, "SOL": 4}}`,
+			buyVolume:   "1.3875",
+			netProfit:   "30.38",
+			profitCurve: []string{"30", "30.38"},
+		},
+	}
+	for _, test := range tests {
+		configure(t, test.config)
+
+		opportunity, err := analyze(
+			domain.OrderBook{Exchange: domain.Hata, Pair: "SOLMYR", Asks: levels("100", "1", "129", "1", "131", "1")},
+			domain.OrderBook{Exchange: domain.Luno, Pair: "SOLMYR", Bids: levels("130", "3")},
+			nil)
+		if err != nil || opportunity == nil {
+			t.Fatalf("%s: expected an opportunity; got %v", test.name, err)
+		}
+		if opportunity.BuyVolume.Cmp(dec(test.buyVolume)) != 0 || opportunity.SellVolume.Cmp(dec(test.buyVolume)) != 0 {
+			t.Errorf("%s: expected %s bought and sold; got %v and %v", test.name, test.buyVolume, opportunity.BuyVolume, opportunity.SellVolume)
+		}
+		if opportunity.NetProfit.Cmp(dec(test.netProfit)) != 0 {
+			t.Errorf("%s: expected a net profit of %s; got %v", test.name, test.netProfit, opportunity.NetProfit)
+		}
+		if len(opportunity.ProfitCurve) != len(test.profitCurve) {
+			t.Fatalf("%s: expected %d points on the profit curve; got %+v", test.name, len(test.profitCurve), opportunity.ProfitCurve)
+		}
+		for i, point := range opportunity.ProfitCurve {
+			if point.NetProfit.Cmp(dec(test.profitCurve[i])) != 0 {
+				t.Errorf("%s: expected %s at point %d of the profit curve; got %v", test.name, test.profitCurve[i], i, point.NetProfit)
+			}
+		}
+	}
+}
+
+func TestSettleRoundsAgainstTheTrader(t *testing.T) {
+	params := tradeParameters{buyFee: dec("0.001"), sellFee: dec("0.001"), transferFee: dec("0.0001"), quoteScale: 2}
+
+	trade := settle(dec("100.005"), dec("110.009"), dec("100.003"), params)
+
+	expected := settlement{
+		buyFee:         dec("0.11"),   // 0.100005 rounded up
+		totalBuyPrice:  dec("100.12"), // 100.01 rounded up, plus the fee
+		sellFee:        dec("0.12"),   // 0.110009 rounded up
+		totalSellPrice: dec("109.88"), // 110.00 rounded down, less the fee
+		transferFee:    dec("0.02"),   // 0.0100003 rounded up
+		netProfit:      dec("9.74"),
+	}
+	for _, field := range []struct {
+		name     string
+		got      decimal.Decimal
+		expected decimal.Decimal
+	}{
+		{"buy fee", trade.buyFee, expected.buyFee},
+		{"total buy price", trade.totalBuyPrice, expected.totalBuyPrice},
+		{"sell fee", trade.sellFee, expected.sellFee},
+		{"total sell price", trade.totalSellPrice, expected.totalSellPrice},
+		{"transfer fee", trade.transferFee, expected.transferFee},
+		{"net profit", trade.netProfit, expected.netProfit},
+	} {
+		if field.got.Cmp(field.expected) != 0 {
+			t.Errorf("expected %s %v; got %v", field.name, field.expected, field.got)
+		}
+	}
+}
+
+func TestAnalyzeInventorySizing(t *testing.T) {
+	configure(t, `{
+		"Arbitrage": {"AVAXMYR": {"Mode": 1, "SlippageMode": 1, "Slippage": 0.1}},
+		"Exchange": {"Hata": {"TakerFee": 0.01, "Crypto": {"AVAXMYR": {"WithdrawFee": 0.5}}}},
+		"Precision": {"MYR": 2, "AVAX": 4}
+	}`)
+	buyOrderbook := domain.OrderBook{Exchange: domain.Hata, Pair: "AVAXMYR", Asks: levels("100", "5")}
+	sellOrderbook := domain.OrderBook{Exchange: domain.Luno, Pair: "AVAXMYR", Bids: levels("110", "5")}
+
+	tests := []struct {
+		name      string
+		balances  domain.Balances
+		buyVolume string // empty when no opportunity is expected
+	}{
+		// 202 MYR pays for 200 MYR of AVAX and its 1% fee
+		{"limited by the quote balance", domain.Balances{"Hata": {"MYR": dec("202")}, "Luno": {"AVAX": dec("10")}}, "2"},
+		{"limited by the base balance", domain.Balances{"Hata": {"MYR": dec("1000")}, "Luno": {"AVAX": dec("1.5")}}, "1.5"},
+		{"no quote balance", domain.Balances{"Luno": {"AVAX": dec("10")}}, ""},
+		{"no base balance", domain.Balances{"Hata": {"MYR": dec("1000")}, "Luno": {"MYR": dec("1000")}}, ""},
+	}
+	for _, test := range tests {
+		opportunity, err := analyze(buyOrderbook, sellOrderbook, test.balances)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if test.buyVolume == "" {
+			if opportunity != nil {
+				t.Errorf("%s: expected no opportunity; got %+v", test.name, opportunity)
+			}
+			continue
+		}
+		if opportunity == nil {
+			t.Fatalf("%s: expected an opportunity", test.name)
+		}
+		if opportunity.BuyVolume.Cmp(dec(test.buyVolume)) != 0 || opportunity.SellVolume.Cmp(dec(test.buyVolume)) != 0 {
+			t.Errorf("%s: expected %s bought and sold; got %v and %v", test.name, test.buyVolume, opportunity.BuyVolume, opportunity.SellVolume)
+		}
+		// Nothing is withdrawn in Inventory mode
+		if opportunity.Mode != domain.Inventory || opportunity.TransferFee.Sign() != 0 || opportunity.IsDynamicTransferFee {
+			t.Errorf("%s: expected an Inventory mode opportunity without transfer fee; got %v paying %v", test.name, opportunity.Mode, opportunity.TransferFee)
+		}
+		if opportunity.BalancesAfter == nil {
+			t.Errorf("%s: expected the balances after the trade", test.name)
+		}
+	}
+}
+
+func TestBalancesAfterTrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  domain.Balances
+		after     domain.Balances
+		imbalance string
+	}{
+		{
+			name:      "base moves to the buy exchange",
+			balances:  domain.Balances{"Hata": {"MYR": dec("202")}, "Luno": {"AVAX": dec("10")}},
+			after:     domain.Balances{"Hata": {"MYR": dec("0"), "AVAX": dec("2")}, "Luno": {"MYR": dec("220"), "AVAX": dec("8")}},
+			imbalance: "0.2",
+		},
+		{
+			name:      "balanced after the trade",
+			balances:  domain.Balances{"Hata": {"MYR": dec("500"), "AVAX": dec("3")}, "Luno": {"MYR": dec("100"), "AVAX": dec("7")}},
+			after:     domain.Balances{"Hata": {"MYR": dec("298"), "AVAX": dec("5")}, "Luno": {"MYR": dec("320"), "AVAX": dec("5")}},
+			imbalance: "0.5",
+		},
+		{
+			name:      "no base left",
+			balances:  domain.Balances{"Hata": {"MYR": dec("202"), "AVAX": dec("-2")}, "Luno": {"AVAX": dec("2")}},
+			after:     domain.Balances{"Hata": {"MYR": dec("0"), "AVAX": dec("0")}, "Luno": {"MYR": dec("220"), "AVAX": dec("0")}},
+			imbalance: "0",
+		},
+	}
+	opportunity := &domain.ArbitrageOpportunity{BuyOn: "Hata", SellOn: "Luno", BuyVolume: dec("2"), TotalBuyPrice: dec("202"), SellVolume: dec("2"), TotalSellPrice: dec("220")}
+	for _, test := range tests {
+		after, imbalance := balancesAfterTrade(test.balances, opportunity, "AVAX", "MYR")
+		for exchange, currencies := range test.after {
+			for currency, amount := range currencies {
+				if got := after.Get(exchange, currency); got.Cmp(amount) != 0 {
+					t.Errorf("%s: expected %v %s on %s; got %v", test.name, amount, currency, exchange, got)
+				}
+			}
+		}
+		if imbalance.Cmp(dec(test.imbalance)) != 0 {
+			t.Errorf("%s: expected an imbalance of %s; got %v", test.name, test.imbalance, imbalance)
+		}
+	}
+}
+
+func TestAnalyzeFlagsSkewedOrderBooks(t *testing.T) {
+	fetchedAt := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		config string
+		skew   time.Duration
+		skewed bool
+	}{
+		{"within the limit", `{"MaxOrderBookSkewSeconds": 2}`, time.Second, false},
+		{"beyond the limit", `{"MaxOrderBookSkewSeconds": 2}`, 3 * time.Second, true},
+		{"fractional limit", `{"MaxOrderBookSkewSeconds": 0.5}`, time.Second, true},
+		{"no limit", `{}`, time.Hour, false},
+	}
+	for _, test := range tests {
+		configure(t, test.config)
+		opportunity, err := analyze(
+			domain.OrderBook{Exchange: domain.Hata, Pair: "SOLMYR", Asks: levels("1000", "1"), FetchedAt: fetchedAt},
+			domain.OrderBook{Exchange: domain.Luno, Pair: "SOLMYR", Bids: levels("1010", "1"), FetchedAt: fetchedAt.Add(test.skew)},
+			nil)
+		if err != nil || opportunity == nil {
+			t.Fatalf("%s: expected an opportunity; got %v", test.name, err)
+		}
+		if opportunity.BookSkew != test.skew || opportunity.Skewed != test.skewed {
+			t.Errorf("%s: expected a skew of %v flagged %v; got %v flagged %v", test.name, test.skew, test.skewed, opportunity.BookSkew, opportunity.Skewed)
+		}
+	}
+}
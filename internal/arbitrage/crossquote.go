@@ -0,0 +1,90 @@
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+)
+
+// Fx converts the books of exchanges listing a pair's base in another quote currency, see fx.Provider.
+// Without it only books of the same pair are compared.
+var Fx domain.FxProvider
+
+// symbolOn returns the pair traded on the exchange in place of pair, which is pair itself unless the
+// market config lists the exchange under another quote currency.
+func symbolOn(pair string, exchange string) string {
+	if symbol, ok := Config.Market[pair].Symbols[exchange]; ok && symbol != "" {
+		return symbol
+	}
+	return pair
+}
+
+// toPair converts an order book fetched for another symbol of the same base currency into pair's
+// quote currency, so it can be analyzed alongside the books of pair.
+func toPair(ctx context.Context, orderbook domain.OrderBook, pair string) (domain.OrderBook, error) {
+	if orderbook.Pair == pair {
+		return orderbook, nil
+	}
+
+	rate, err := crossQuoteRate(ctx, orderbook.Pair, pair)
+	if err != nil {
+		return orderbook, err
+	}
+	return rate.ConvertOrderBook(orderbook, pair), nil
+}
+
+// crossQuoteRate returns the rate converting the quote currency of symbol into the quote currency of pair.
+func crossQuoteRate(ctx context.Context, symbol string, pair string) (rate domain.FxRate, err error) {
+	from, err := domain.ParsePair(symbol)
+	if err != nil {
+		return rate, err
+	}
+	to, err := domain.ParsePair(pair)
+	if err != nil {
+		return rate, err
+	}
+	if from.Base != to.Base {
+		return rate, fmt.Errorf("%s and %s do not trade the same currency", symbol, pair)
+	}
+	if Fx == nil {
+		return rate, errors.New("no fx provider to convert " + symbol + " into " + pair)
+	}
+
+	return Fx.GetRate(ctx, from.Quote, to.Quote)
+}
+
+// applyCrossQuote records which legs of the opportunity trade a converted book and what converting
+// their quote currency cost. The cost is already part of the converted prices, so NetProfit is unchanged.
+func applyCrossQuote(ctx context.Context, opportunity *domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) {
+	for _, orderbook := range orderbooks {
+		if orderbook.Symbol == "" {
+			continue
+		}
+		buyLeg := orderbook.Exchange.String() == opportunity.BuyOn
+		sellLeg := orderbook.Exchange.String() == opportunity.SellOn
+		if !buyLeg && !sellLeg {
+			continue
+		}
+
+		rate, err := crossQuoteRate(ctx, orderbook.Symbol, opportunity.Pair)
+		if err != nil {
+			Logger.Error("Failed to get fx rate of " + orderbook.Symbol + ": " + err.Error())
+			continue
+		}
+		if buyLeg {
+			opportunity.BuySymbol = orderbook.Symbol
+			opportunity.FxCost = opportunity.FxCost.Add(rate.GetConversionCost(opportunity.TotalBuyPrice, true))
+		} else {
+			opportunity.SellSymbol = orderbook.Symbol
+			opportunity.FxCost = opportunity.FxCost.Add(rate.GetConversionCost(opportunity.TotalSellPrice, false))
+		}
+	}
+
+	if opportunity.BuySymbol != "" || opportunity.SellSymbol != "" {
+		_, quoteScale, err := pairScales(opportunity.Pair)
+		if err == nil {
+			opportunity.FxCost = domain.RoundUp(opportunity.FxCost, quoteScale)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"testing"
+)
+
+type stubFx struct {
+	rates map[string]domain.FxRate // from + to => rate
+}
+
+func (fx *stubFx) GetRate(ctx context.Context, from string, to string) (domain.FxRate, error) {
+	rate, ok := fx.rates[from+to]
+	if !ok {
+		return rate, errors.New("no rate for " + from + to)
+	}
+	return rate, nil
+}
+
+func TestApplyCrossQuote(t *testing.T) {
+	configure(t, `{"Precision": {"MYR": 2, "SOL": 4}}`)
+	Fx = &stubFx{rates: map[string]domain.FxRate{"USDTMYR": {From: "USDT", To: "MYR", Bid: dec("4.4"), Ask: dec("4.5")}}}
+	t.Cleanup(func() { Fx = nil })
+
+	orderbooks := []domain.OrderBook{
+		{Exchange: domain.MXGlobal, Pair: "SOLMYR", Symbol: "SOLUSDT"},
+		{Exchange: domain.Luno, Pair: "SOLMYR"},
+		{Exchange: domain.Hata, Pair: "SOLMYR", Symbol: "SOLUSDC"},
+	}
+
+	tests := []struct {
+		name       string
+		buyOn      string
+		sellOn     string
+		buySymbol  string
+		sellSymbol string
+		fxCost     string
+	}{
+		// Paying 450 MYR at the 4.5 ask costs 5 MYR over the 4.45 mid rate
+		{"converted buy leg", "MXGlobal", "Luno", "SOLUSDT", "", "5"},
+		// Receiving 440 MYR at the 4.4 bid costs 5 MYR under the mid rate
+		{"converted sell leg", "Luno", "MXGlobal", "", "SOLUSDT", "5"},
+		{"no converted leg", "Luno", "Luno", "", "", "0"},
+		{"missing rate", "Hata", "Luno", "", "", "0"},
+	}
+	for _, test := range tests {
+		opportunity := domain.ArbitrageOpportunity{Pair: "SOLMYR", BuyOn: test.buyOn, SellOn: test.sellOn, TotalBuyPrice: dec("450"), TotalSellPrice: dec("440"), NetProfit: dec("12")}
+		applyCrossQuote(context.Background(), &opportunity, orderbooks)
+
+		if opportunity.BuySymbol != test.buySymbol || opportunity.SellSymbol != test.sellSymbol {
+			t.Errorf("%s: expected symbols %q and %q; got %q and %q", test.name, test.buySymbol, test.sellSymbol, opportunity.BuySymbol, opportunity.SellSymbol)
+		}
+		if opportunity.FxCost.Cmp(dec(test.fxCost)) != 0 {
+			t.Errorf("%s: expected an fx cost of %s; got %v", test.name, test.fxCost, opportunity.FxCost)
+		}
+		// The cost is already part of the converted prices
+		if opportunity.NetProfit.Cmp(dec("12")) != 0 {
+			t.Errorf("%s: expected the net profit unchanged; got %v", test.name, opportunity.NetProfit)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+type ExecutionResult struct {
+	BuyOrder       domain.Order
+	SellOrder      domain.Order
+	WithdrawalId   string
+	Received       decimal.Decimal // volume credited on the sell exchange after the transfer
+	RealizedProfit decimal.Decimal
+	Unhedged       decimal.Decimal // Inventory mode base volume bought minus sold when the legs filled differently, left for the operator to unwind
+}
+
+const executionTimeout = 15 * time.Minute
+const depositPollInterval = 5 * time.Second
+
+// Opportunities currently being executed, keyed by pair and direction, so the same spread
+// is not traded twice while a transfer is still in flight.
+var executing sync.Map
+
+// ExecuteAsync runs Execute in the background unless the same opportunity is already being executed.
+// The execution is abandoned when ctx is cancelled or after executionTimeout.
+func ExecuteAsync(ctx context.Context, opportunity domain.ArbitrageOpportunity, buyExchange domain.TradingExchanger, sellExchange domain.TradingExchanger) {
+	key := opportunity.Pair + ":" + opportunity.BuyOn + ":" + opportunity.SellOn
+	if _, running := executing.LoadOrStore(key, true); running {
+		Logger.Info("Skipping execution of " + key + ", previous execution still running")
+		return
+	}
+
+	go func() {
+		defer executing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(ctx, executionTimeout)
+		defer cancel()
+
+		result, err := Execute(ctx, opportunity, buyExchange, sellExchange)
+		if result.Unhedged.Sign() != 0 {
+			Logger.Error(fmt.Sprintf("Partial fill executing %s: bought %v on %s but sold %v on %s, %v left unhedged for the operator to unwind",
+				key,
+				result.BuyOrder.FilledVolume, opportunity.BuyOn,
+				result.SellOrder.FilledVolume, opportunity.SellOn,
+				result.Unhedged))
+		}
+		if err != nil {
+			Logger.Error("Failed to execute " + key + ": " + err.Error())
+			return
+		}
+		Logger.Info(fmt.Sprintf("Executed %s: bought %v at %v, received %v, sold %v at %v, realized profit %v",
+			key,
+			result.BuyOrder.FilledVolume, result.BuyOrder.GetAveragePrice(),
+			result.Received,
+			result.SellOrder.FilledVolume, result.SellOrder.GetAveragePrice(),
+			result.RealizedProfit))
+	}()
+}
+
+// Execute runs the buy -> transfer -> sell loop of an opportunity. Both legs are placed as limit
+// orders at the worst price of the planned orders and any unfilled remainder is cancelled.
+// Inventory mode opportunities place both legs at once from existing balances instead.
+func Execute(ctx context.Context, opportunity domain.ArbitrageOpportunity, buyExchange domain.TradingExchanger, sellExchange domain.TradingExchanger) (result ExecutionResult, err error) {
+	if len(opportunity.BuyOrders) == 0 || len(opportunity.SellOrders) == 0 {
+		return result, errors.New("opportunity has no planned orders")
+	}
+	if opportunity.Mode == domain.Inventory {
+		return executeInventory(ctx, opportunity, buyExchange, sellExchange)
+	}
+	base, _, err := domain.SplitPair(opportunity.Pair)
+	if err != nil {
+		return result, err
+	}
+
+	// Step 1: Buy
+	result.BuyOrder, err = placeAndSettle(ctx, buyExchange, domain.OrderRequest{
+		Pair:   opportunity.Pair,
+		Side:   domain.Buy,
+		Type:   domain.Limit,
+		Price:  opportunity.BuyOrders[len(opportunity.BuyOrders)-1].Price,
+		Volume: opportunity.BuyVolume,
+	})
+	if err != nil {
+		return result, fmt.Errorf("buy on %s: %w", buyExchange.GetName(), err)
+	}
+	if result.BuyOrder.FilledVolume.Sign() == 0 {
+		return result, fmt.Errorf("buy order %s on %s was not filled", result.BuyOrder.Id, buyExchange.GetName())
+	}
+
+	// Step 2: Transfer to the sell exchange
+	address, err := sellExchange.GetDepositAddress(ctx, opportunity.Pair)
+	if err != nil {
+		return result, fmt.Errorf("deposit address on %s: %w", sellExchange.GetName(), err)
+	}
+	balances, err := sellExchange.GetBalances(ctx)
+	if err != nil {
+		return result, fmt.Errorf("balances on %s: %w", sellExchange.GetName(), err)
+	}
+	balanceBefore := balances[base]
+
+	result.WithdrawalId, err = buyExchange.Withdraw(ctx, opportunity.Pair, address, result.BuyOrder.FilledVolume)
+	if err != nil {
+		return result, fmt.Errorf("withdraw from %s: %w", buyExchange.GetName(), err)
+	}
+
+	result.Received, err = waitForDeposit(ctx, sellExchange, base, balanceBefore)
+	if err != nil {
+		return result, err
+	}
+
+	// Step 3: Sell what arrived
+	result.SellOrder, err = placeAndSettle(ctx, sellExchange, domain.OrderRequest{
+		Pair:   opportunity.Pair,
+		Side:   domain.Sell,
+		Type:   domain.Limit,
+		Price:  opportunity.SellOrders[len(opportunity.SellOrders)-1].Price,
+		Volume: result.Received,
+	})
+	if err != nil {
+		return result, fmt.Errorf("sell on %s: %w", sellExchange.GetName(), err)
+	}
+
+	result.RealizedProfit = result.SellOrder.FilledAmount.Sub(result.SellOrder.Fee).Sub(result.BuyOrder.FilledAmount.Add(result.BuyOrder.Fee))
+
+	return result, nil
+}
+
+// executeInventory places the buy and sell legs of an Inventory mode opportunity concurrently,
+// each against the balance already held on its exchange. When one leg fails or fills less than the
+// other, the difference is reported as Unhedged, also alongside an error. The filled leg is not
+// reversed: trading it back would cross the spread again at an unknown price, so unwinding is left
+// to the operator.
+func executeInventory(ctx context.Context, opportunity domain.ArbitrageOpportunity, buyExchange domain.TradingExchanger, sellExchange domain.TradingExchanger) (result ExecutionResult, err error) {
+	var buyErr, sellErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		result.BuyOrder, buyErr = placeAndSettle(ctx, buyExchange, domain.OrderRequest{
+			Pair:   opportunity.Pair,
+			Side:   domain.Buy,
+			Type:   domain.Limit,
+			Price:  opportunity.BuyOrders[len(opportunity.BuyOrders)-1].Price,
+			Volume: opportunity.BuyVolume,
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		result.SellOrder, sellErr = placeAndSettle(ctx, sellExchange, domain.OrderRequest{
+			Pair:   opportunity.Pair,
+			Side:   domain.Sell,
+			Type:   domain.Limit,
+			Price:  opportunity.SellOrders[len(opportunity.SellOrders)-1].Price,
+			Volume: opportunity.SellVolume,
+		})
+	}()
+	wg.Wait()
+
+	result.Unhedged = result.BuyOrder.FilledVolume.Sub(result.SellOrder.FilledVolume)
+
+	if buyErr != nil {
+		err = fmt.Errorf("buy on %s: %w", buyExchange.GetName(), buyErr)
+	}
+	if sellErr != nil {
+		err = errors.Join(err, fmt.Errorf("sell on %s: %w", sellExchange.GetName(), sellErr))
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.RealizedProfit = result.SellOrder.FilledAmount.Sub(result.SellOrder.Fee).Sub(result.BuyOrder.FilledAmount.Add(result.BuyOrder.Fee))
+
+	return result, nil
+}
+
+// placeAndSettle places the order and cancels whatever did not fill immediately.
+func placeAndSettle(ctx context.Context, trader domain.Trader, request domain.OrderRequest) (order domain.Order, err error) {
+	order, err = trader.PlaceOrder(ctx, request)
+	if err != nil {
+		return order, err
+	}
+
+	if order.Status == domain.Open || order.Status == domain.PartiallyFilled {
+		err = trader.CancelOrder(ctx, order.Id)
+		if err != nil {
+			return order, err
+		}
+		return trader.GetOrder(ctx, order.Id)
+	}
+
+	return order, nil
+}
+
+func waitForDeposit(ctx context.Context, exchange domain.Trader, currency string, balanceBefore decimal.Decimal) (received decimal.Decimal, err error) {
+	for {
+		balances, err := exchange.GetBalances(ctx)
+		if err != nil {
+			return received, err
+		}
+		if balances[currency].Cmp(balanceBefore) > 0 {
+			return balances[currency].Sub(balanceBefore), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return received, fmt.Errorf("timeout waiting for %s deposit: %w", currency, ctx.Err())
+		case <-time.After(depositPollInterval):
+		}
+	}
+}
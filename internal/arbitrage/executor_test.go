@@ -0,0 +1,209 @@
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"sync"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// stubTrader fills every order it is sent with order, or with settled once a resting order is cancelled.
+type stubTrader struct {
+	domain.Exchanger
+	name      string
+	order     domain.Order // returned when an order is placed
+	settled   domain.Order // returned once the placed order is cancelled
+	placeErr  error
+	balances  []map[string]decimal.Decimal // returned by successive balance reads, the last one repeated
+	mu        sync.Mutex
+	placed    []domain.OrderRequest
+	cancelled []string
+	withdrawn decimal.Decimal
+}
+
+func (trader *stubTrader) GetName() string { return trader.name }
+
+func (trader *stubTrader) GetDepositAddress(ctx context.Context, pair string) (string, error) {
+	return trader.name + "-address", nil
+}
+
+func (trader *stubTrader) PlaceOrder(ctx context.Context, request domain.OrderRequest) (domain.Order, error) {
+	trader.mu.Lock()
+	defer trader.mu.Unlock()
+	trader.placed = append(trader.placed, request)
+	return trader.order, trader.placeErr
+}
+
+func (trader *stubTrader) CancelOrder(ctx context.Context, orderId string) error {
+	trader.mu.Lock()
+	defer trader.mu.Unlock()
+	trader.cancelled = append(trader.cancelled, orderId)
+	return nil
+}
+
+func (trader *stubTrader) GetOrder(ctx context.Context, orderId string) (domain.Order, error) {
+	return trader.settled, nil
+}
+
+func (trader *stubTrader) GetBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
+	trader.mu.Lock()
+	defer trader.mu.Unlock()
+	balances := trader.balances[0]
+	if len(trader.balances) > 1 {
+		trader.balances = trader.balances[1:]
+	}
+	return balances, nil
+}
+
+func (trader *stubTrader) Withdraw(ctx context.Context, pair string, address string, amount decimal.Decimal) (string, error) {
+	if address != "Luno-address" {
+		return "", errors.New("unexpected address " + address)
+	}
+	trader.withdrawn = amount
+	return "withdrawal-1", nil
+}
+
+func filledOrder(id string, status domain.OrderStatusEnum, volume string, amount string, fee string) domain.Order {
+	return domain.Order{Id: id, Status: status, FilledVolume: dec(volume), FilledAmount: dec(amount), Fee: dec(fee)}
+}
+
+func plannedOpportunity(mode domain.ArbitrageModeEnum) domain.ArbitrageOpportunity {
+	return domain.ArbitrageOpportunity{
+		Pair:       "SOLMYR",
+		BuyOn:      "Hata",
+		SellOn:     "Luno",
+		BuyVolume:  dec("1"),
+		SellVolume: dec("1"),
+		BuyOrders:  levels("99", "0.5", "100", "0.5"),
+		SellOrders: levels("111", "0.4", "110", "0.6"),
+		Mode:       mode,
+	}
+}
+
+func TestExecuteTransfersBetweenExchanges(t *testing.T) {
+	buyExchange := &stubTrader{name: "Hata", order: filledOrder("buy-1", domain.Filled, "1", "99.5", "0.1")}
+	sellExchange := &stubTrader{
+		name:     "Luno",
+		order:    filledOrder("sell-1", domain.PartiallyFilled, "0.5", "55", "0.05"),
+		settled:  filledOrder("sell-1", domain.Cancelled, "0.99", "109", "0.1"),
+		balances: []map[string]decimal.Decimal{{"SOL": dec("0.5")}, {"SOL": dec("1.49")}},
+	}
+
+	result, err := Execute(context.Background(), plannedOpportunity(domain.Transfer), buyExchange, sellExchange)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Both legs are limit orders at the worst planned price
+	if len(buyExchange.placed) != 1 || buyExchange.placed[0].Side != domain.Buy || buyExchange.placed[0].Price.Cmp(dec("100")) != 0 || buyExchange.placed[0].Volume.Cmp(dec("1")) != 0 {
+		t.Errorf("expected a buy of 1 at 100; got %+v", buyExchange.placed)
+	}
+	if buyExchange.withdrawn.Cmp(dec("1")) != 0 || result.WithdrawalId != "withdrawal-1" {
+		t.Errorf("expected the bought volume withdrawn; got %v (%s)", buyExchange.withdrawn, result.WithdrawalId)
+	}
+	// Only what arrived net of the withdraw fee is sold, and the unfilled remainder is cancelled
+	if len(sellExchange.placed) != 1 || sellExchange.placed[0].Side != domain.Sell || sellExchange.placed[0].Price.Cmp(dec("110")) != 0 || sellExchange.placed[0].Volume.Cmp(dec("0.99")) != 0 {
+		t.Errorf("expected a sell of 0.99 at 110; got %+v", sellExchange.placed)
+	}
+	if len(sellExchange.cancelled) != 1 || sellExchange.cancelled[0] != "sell-1" {
+		t.Errorf("expected the sell order cancelled; got %v", sellExchange.cancelled)
+	}
+	if result.Received.Cmp(dec("0.99")) != 0 || result.SellOrder.FilledVolume.Cmp(dec("0.99")) != 0 {
+		t.Errorf("expected 0.99 received and sold; got %v and %v", result.Received, result.SellOrder.FilledVolume)
+	}
+	if result.RealizedProfit.Cmp(dec("9.3")) != 0 {
+		t.Errorf("expected a realized profit of 9.3; got %v", result.RealizedProfit)
+	}
+}
+
+func TestExecuteStopsBeforeTransfer(t *testing.T) {
+	tests := []struct {
+		name        string
+		opportunity domain.ArbitrageOpportunity
+		buyOrder    domain.Order
+		placeErr    error
+	}{
+		{"no planned orders", domain.ArbitrageOpportunity{Pair: "SOLMYR", BuyOn: "Hata", SellOn: "Luno"}, domain.Order{}, nil},
+		{"buy not filled", plannedOpportunity(domain.Transfer), filledOrder("buy-1", domain.Cancelled, "0", "0", "0"), nil},
+		{"buy rejected", plannedOpportunity(domain.Transfer), domain.Order{}, errors.New("insufficient balance")},
+	}
+	for _, test := range tests {
+		buyExchange := &stubTrader{name: "Hata", order: test.buyOrder, settled: test.buyOrder, placeErr: test.placeErr}
+		sellExchange := &stubTrader{name: "Luno", balances: []map[string]decimal.Decimal{{}}}
+
+		if _, err := Execute(context.Background(), test.opportunity, buyExchange, sellExchange); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if buyExchange.withdrawn.Sign() != 0 || len(sellExchange.placed) != 0 {
+			t.Errorf("%s: expected nothing withdrawn or sold; got %v withdrawn and %+v", test.name, buyExchange.withdrawn, sellExchange.placed)
+		}
+	}
+}
+
+func TestExecuteInventory(t *testing.T) {
+	tests := []struct {
+		name           string
+		buyOrder       domain.Order
+		buyErr         error
+		sellOrder      domain.Order
+		sellSettled    domain.Order
+		sellErr        error
+		wantErr        bool
+		unhedged       string
+		realizedProfit string
+	}{
+		{
+			name:           "both legs filled",
+			buyOrder:       filledOrder("buy-1", domain.Filled, "1", "99.5", "0.1"),
+			sellOrder:      filledOrder("sell-1", domain.Filled, "1", "110.4", "0.1"),
+			unhedged:       "0",
+			realizedProfit: "10.7",
+		},
+		{
+			name:           "sell partially filled",
+			buyOrder:       filledOrder("buy-1", domain.Filled, "1", "99.5", "0.1"),
+			sellOrder:      filledOrder("sell-1", domain.PartiallyFilled, "0.4", "44.4", "0.04"),
+			sellSettled:    filledOrder("sell-1", domain.Cancelled, "0.4", "44.4", "0.04"),
+			unhedged:       "0.6",
+			realizedProfit: "-55.24",
+		},
+		{
+			name:     "sell failed",
+			buyOrder: filledOrder("buy-1", domain.Filled, "1", "99.5", "0.1"),
+			sellErr:  errors.New("insufficient balance"),
+			wantErr:  true,
+			unhedged: "1",
+		},
+		{
+			name:      "buy failed",
+			buyErr:    errors.New("insufficient balance"),
+			sellOrder: filledOrder("sell-1", domain.Filled, "1", "110.4", "0.1"),
+			wantErr:   true,
+			unhedged:  "-1",
+		},
+	}
+	for _, test := range tests {
+		buyExchange := &stubTrader{name: "Hata", order: test.buyOrder, placeErr: test.buyErr}
+		sellExchange := &stubTrader{name: "Luno", order: test.sellOrder, settled: test.sellSettled, placeErr: test.sellErr}
+
+		result, err := Execute(context.Background(), plannedOpportunity(domain.Inventory), buyExchange, sellExchange)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: expected an error %v; got %v", test.name, test.wantErr, err)
+		}
+		// Both legs are placed at once from the balances, nothing is withdrawn
+		if len(buyExchange.placed) != 1 || len(sellExchange.placed) != 1 || buyExchange.withdrawn.Sign() != 0 {
+			t.Errorf("%s: expected one order on each exchange and no withdrawal; got %+v and %+v", test.name, buyExchange.placed, sellExchange.placed)
+		} else if sellExchange.placed[0].Price.Cmp(dec("110")) != 0 || sellExchange.placed[0].Volume.Cmp(dec("1")) != 0 {
+			t.Errorf("%s: expected a sell of 1 at 110; got %+v", test.name, sellExchange.placed[0])
+		}
+		if result.Unhedged.Cmp(dec(test.unhedged)) != 0 {
+			t.Errorf("%s: expected %s unhedged; got %v", test.name, test.unhedged, result.Unhedged)
+		}
+		if test.realizedProfit != "" && result.RealizedProfit.Cmp(dec(test.realizedProfit)) != 0 {
+			t.Errorf("%s: expected a realized profit of %s; got %v", test.name, test.realizedProfit, result.RealizedProfit)
+		}
+	}
+}
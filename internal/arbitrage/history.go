@@ -0,0 +1,85 @@
+package arbitrage
+
+import (
+	"context"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultSaveInterval = time.Minute
+const defaultRetention = 30 * 24 * time.Hour
+const pruneInterval = time.Hour
+
+// savedAnalysis is the last analysis of a route that was stored.
+type savedAnalysis struct {
+	at         time.Time
+	profitable bool
+}
+
+// Routes whose analyses were stored, keyed by pair and direction, so a spread that persists is not
+// stored again on every analysis.
+var savedAnalyses = make(map[string]savedAnalysis)
+var savedAnalysesMutex sync.Mutex
+
+// shouldSave reports whether the analysis of a route is worth storing: the first one, one whose
+// profitability changed since the last stored, or one at least History.SaveIntervalSeconds after it.
+func shouldSave(opportunity domain.ArbitrageOpportunity) bool {
+	interval := seconds(Config.History.SaveIntervalSeconds)
+	if interval <= 0 {
+		interval = defaultSaveInterval
+	}
+
+	savedAnalysesMutex.Lock()
+	defer savedAnalysesMutex.Unlock()
+
+	last, ok := savedAnalyses[savedAnalysisKey(opportunity)]
+	return !ok || last.profitable != opportunity.Profitable || analyzedAt(opportunity).Sub(last.at) >= interval
+}
+
+// markSaved remembers the analysis of a route as stored, once the store accepted it, so a failed
+// save does not hold back the next analysis of the route.
+func markSaved(opportunity domain.ArbitrageOpportunity) {
+	savedAnalysesMutex.Lock()
+	defer savedAnalysesMutex.Unlock()
+
+	savedAnalyses[savedAnalysisKey(opportunity)] = savedAnalysis{at: analyzedAt(opportunity), profitable: opportunity.Profitable}
+}
+
+func savedAnalysisKey(opportunity domain.ArbitrageOpportunity) string {
+	return opportunity.Pair + ":" + opportunity.BuyOn + ":" + opportunity.SellOn
+}
+
+func analyzedAt(opportunity domain.ArbitrageOpportunity) time.Time {
+	if opportunity.DetectedAt.IsZero() {
+		return time.Now()
+	}
+	return opportunity.DetectedAt
+}
+
+// PruneHistory deletes the stored opportunities older than History.RetentionDays every hour until ctx
+// is cancelled.
+func PruneHistory(ctx context.Context, store HistoryStore) {
+	retention := time.Duration(Config.History.RetentionDays) * 24 * time.Hour
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.DeleteOpportunitiesBefore(ctx, time.Now().Add(-retention))
+		if err != nil {
+			Logger.Error("Failed to prune stored opportunities: " + err.Error())
+		} else if deleted > 0 {
+			Logger.Info("Pruned " + strconv.FormatInt(deleted, 10) + " stored opportunities older than " + retention.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
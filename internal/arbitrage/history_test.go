@@ -0,0 +1,74 @@
+package arbitrage
+
+import (
+	"context"
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestShouldSaveThrottlesUnchangedRoutes(t *testing.T) {
+	clear(savedAnalyses)
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	analysis := func(buyOn string, profitable bool, at time.Duration) domain.ArbitrageOpportunity {
+		return domain.ArbitrageOpportunity{Pair: "SOLMYR", BuyOn: buyOn, SellOn: "Luno", Profitable: profitable, DetectedAt: start.Add(at)}
+	}
+
+	tests := []struct {
+		name     string
+		analysis domain.ArbitrageOpportunity
+		want     bool
+	}{
+		{"first analysis of a route", analysis("Hata", false, 0), true},
+		{"unchanged within the interval", analysis("Hata", false, 30*time.Second), false},
+		{"other route", analysis("MXGlobal", false, 30*time.Second), true},
+		{"turned profitable", analysis("Hata", true, 40*time.Second), true},
+		{"still profitable", analysis("Hata", true, 90*time.Second), false},
+		{"interval since the last stored", analysis("Hata", true, 100*time.Second), true},
+		{"no longer profitable", analysis("Hata", false, 101*time.Second), true},
+	}
+	for _, test := range tests {
+		got := shouldSave(test.analysis)
+		if got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+		if got {
+			markSaved(test.analysis)
+		}
+	}
+}
+
+type stubStore struct {
+	errs  []error
+	saved []domain.ArbitrageOpportunity
+}
+
+func (store *stubStore) SaveOpportunity(ctx context.Context, opportunity domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) (int64, error) {
+	if len(store.errs) > 0 {
+		err := store.errs[0]
+		store.errs = store.errs[1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	store.saved = append(store.saved, opportunity)
+	return int64(len(store.saved)), nil
+}
+
+func TestFailedSaveDoesNotThrottleTheRoute(t *testing.T) {
+	clear(savedAnalyses)
+	store := &stubStore{errs: []error{errors.New("database is locked")}}
+	Store = store
+	t.Cleanup(func() { Store = nil })
+
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	for _, at := range []time.Duration{0, time.Second, 2 * time.Second} {
+		analysis := domain.ArbitrageOpportunity{Pair: "SOLMYR", BuyOn: "Hata", SellOn: "Luno", DetectedAt: start.Add(at)}
+		recordArbitrageOutput(context.Background(), &analysis, nil)
+	}
+
+	if len(store.saved) != 1 || !store.saved[0].DetectedAt.Equal(start.Add(time.Second)) {
+		t.Errorf("expected the analysis after the failed save stored and the next one throttled; got %+v", store.saved)
+	}
+}
@@ -0,0 +1,95 @@
+package arbitrage
+
+import (
+	"context"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/triangular"
+	"sync"
+	"time"
+)
+
+const defaultStartCurrency = "MYR"
+
+// WatchTriangular looks for triangular cycles on every exchange with triangular arbitrage enabled,
+// publishing the cycles returning at least the exchange's MinProfit. The scheduled watcher runs it every interval.
+func WatchTriangular(ctx context.Context, exchanges map[string]domain.Exchanger, timeoutInterval time.Duration) {
+	for name, exchange := range exchanges {
+		if !Config.Triangular[name].Enabled {
+			continue
+		}
+		if !Market.Allow(name, time.Now()) {
+			Logger.Info("Skipping triangular cycles on " + name + ": circuit breaker open")
+			continue
+		}
+		exchangeCtx, cancel := context.WithTimeout(ctx, timeoutInterval)
+		watchTriangular(exchangeCtx, exchange)
+		cancel()
+	}
+}
+
+func watchTriangular(ctx context.Context, exchange domain.Exchanger) {
+	triangularConfig := Config.Triangular[exchange.GetName()]
+
+	orderbooks := getTriangularOrderBooks(ctx, exchange, triangularConfig.Pairs)
+	if len(orderbooks) < 3 {
+		Logger.Info("Not enough order books on " + exchange.GetName() + " to look for triangular cycles")
+		return
+	}
+
+	params := triangular.Parameters{
+		StartCurrency: triangularConfig.StartCurrency,
+		Capital:       triangularConfig.Capital,
+		TakerFee:      Config.Exchange[exchange.GetName()].TakerFee,
+		Scale:         Config.GetScale,
+	}
+	if params.StartCurrency == "" {
+		params.StartCurrency = defaultStartCurrency
+	}
+	if params.Capital.Sign() <= 0 {
+		params.Capital = Config.MaxCapital
+	}
+	if params.Capital.Sign() <= 0 {
+		params.Capital = defaultMaxCapital
+	}
+
+	now := time.Now()
+	for _, opportunity := range triangular.Analyze(orderbooks, params) {
+		if !opportunity.Profitable || opportunity.NetProfit.Cmp(triangularConfig.MinProfit) < 0 {
+			continue
+		}
+		Logger.Info(fmt.Sprintf("Triangular opportunity on %s: %s spending %v %s returns %v (%v%%)",
+			opportunity.Exchange, opportunity.GetRoute(), opportunity.StartAmount, params.StartCurrency, opportunity.NetProfit, opportunity.ReturnPercentage))
+		Events.Publish(domain.NewTriangularEvent(opportunity, now))
+	}
+}
+
+// getTriangularOrderBooks fetches the order books of the pairs concurrently, so the cycle legs are
+// priced at about the same time. Pairs that fail to load are left out.
+func getTriangularOrderBooks(ctx context.Context, exchange domain.Exchanger, pairs []string) []domain.OrderBook {
+	fetched := make([]*domain.OrderBook, len(pairs))
+	var wg sync.WaitGroup
+	for i, pair := range pairs {
+		wg.Add(1)
+		go func(i int, pair string) {
+			defer wg.Done()
+			orderbook, err := exchange.GetCurrentOrderBook(ctx, pair)
+			if err != nil {
+				Market.RecordError(exchange.GetName(), err, time.Now())
+				Logger.Error("Failed to get order book for " + exchange.GetName() + " Symbol:" + pair + " Error:" + err.Error())
+				return
+			}
+			Market.RecordOrderBook(orderbook, time.Now())
+			fetched[i] = &orderbook
+		}(i, pair)
+	}
+	wg.Wait()
+
+	orderbooks := make([]domain.OrderBook, 0, len(pairs))
+	for _, orderbook := range fetched {
+		if orderbook != nil {
+			orderbooks = append(orderbooks, *orderbook)
+		}
+	}
+	return orderbooks
+}
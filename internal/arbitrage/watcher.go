@@ -6,20 +6,62 @@ import (
 	"errors"
 	"fmt"
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/pubsub"
+	"malaysia-crypto-exchange-arbitrage/internal/tracker"
 	"time"
+
+	"github.com/luno/luno-go/decimal"
 )
 
+// OpportunityStore persists analyzed opportunities, see database.Service.
+type OpportunityStore interface {
+	SaveOpportunity(ctx context.Context, opportunity domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) (int64, error)
+}
+
+// HistoryStore deletes stored opportunities once they are no longer needed, see database.Service.
+type HistoryStore interface {
+	DeleteOpportunitiesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Store receives the analyzed opportunities when set: every change of a route's profitability, and
+// otherwise one analysis per route every History.SaveIntervalSeconds.
+var Store OpportunityStore
+
+// Events carries top of book changes and the opportunity lifecycle published by Tracker.
+var Events = pubsub.New[domain.Event]()
+
+// Tracker follows the lifecycle of the opportunities worth alerting on and decides which alerts to send.
+var Tracker = tracker.NewOpportunityTracker(Events, 0, decimal.Zero())
+
+// Market caches the latest order books and the health of every exchange, and trips its circuit breaker.
+var Market = tracker.NewMarketTracker(0, 0)
+
+// Configure sets the config the package runs with and rebuilds Tracker and Market from its alert and
+// circuit breaker settings. It must be called before anything else in the package is used.
+func Configure(cfg *config.Config) {
+	Config = cfg
+	Tracker = tracker.NewOpportunityTracker(Events, time.Duration(cfg.Alerts.CooldownSeconds)*time.Second, cfg.Alerts.ProfitChangePercentage)
+	Market = tracker.NewMarketTracker(cfg.CircuitBreaker.FailureThreshold, time.Duration(cfg.CircuitBreaker.CooldownSeconds)*time.Second)
+}
+
 type ArbitrageScheduledWatcher struct {
 	Exchanges map[string]domain.Exchanger
 	Pairs     []string
 	Interval  time.Duration
+	Debounce  time.Duration // Stream mode: how long to coalesce book updates before analyzing
 	ticker    *time.Ticker
 	ctx       context.Context
 	Mode      domain.ArbitrageWatcherModeEnum
+	analyze   func(pair string, orderbooks []domain.OrderBook) // Stream mode: analyzes the latest books of a pair, analyzeStreamed by default
 }
 
+const defaultStreamDebounce = 200 * time.Millisecond
+
 func NewArbitrageScheduledWatcher(ctx context.Context, exchanges map[string]domain.Exchanger, pairs []string, interval time.Duration, mode domain.ArbitrageWatcherModeEnum) *ArbitrageScheduledWatcher {
-	return &ArbitrageScheduledWatcher{ctx: ctx, Exchanges: exchanges, Pairs: pairs, Interval: interval, Mode: mode}
+	watcher := &ArbitrageScheduledWatcher{ctx: ctx, Exchanges: exchanges, Pairs: pairs, Interval: interval, Debounce: defaultStreamDebounce, Mode: mode}
+	watcher.analyze = watcher.analyzeStreamed
+	return watcher
 }
 
 func (watcher *ArbitrageScheduledWatcher) Start() {
@@ -37,8 +79,9 @@ func (watcher *ArbitrageScheduledWatcher) StartScheduled() {
 	// Run immediately first time
 	for _, pair := range watcher.Pairs {
 		Logger.Info("Start watching " + pair + " every " + watcher.Interval.String() + " seconds")
-		Watch(pair, watcher.Exchanges, watcher.Interval)
+		Watch(watcher.ctx, pair, watcher.Exchanges, watcher.Interval)
 	}
+	WatchTriangular(watcher.ctx, watcher.Exchanges, watcher.Interval)
 
 	// Then run on ticker
 	for {
@@ -48,17 +91,199 @@ func (watcher *ArbitrageScheduledWatcher) StartScheduled() {
 			return
 		case <-watcher.ticker.C:
 			for _, pair := range watcher.Pairs {
-				Watch(pair, watcher.Exchanges, watcher.Interval)
+				Watch(watcher.ctx, pair, watcher.Exchanges, watcher.Interval)
 			}
+			WatchTriangular(watcher.ctx, watcher.Exchanges, watcher.Interval)
 		}
 	}
 }
 
+// StartStream subscribes to the order book stream of every exchange/pair, keeps the latest book
+// per exchange and analyzes a pair whenever any of its books changes. Updates arriving within
+// Debounce of the first pending change are coalesced into a single analysis. Each pair is analyzed
+// by its own worker, so the stream keeps being read while lookups and alerts are in progress.
 func (watcher *ArbitrageScheduledWatcher) StartStream() {
+	updates := make(chan *domain.OrderBook, len(watcher.Exchanges)*len(watcher.Pairs))
+	states := make(chan domain.ConnectionState, len(watcher.Exchanges)*len(watcher.Pairs))
+	streamedPairs := make(map[string]string) // exchange:symbol => pair the streamed book is analyzed as
+
 	for _, exchange := range watcher.Exchanges {
+		if notifier, ok := exchange.(domain.ConnectionNotifier); ok {
+			go forwardConnectionStates(watcher.ctx, notifier.GetConnectionStates(), states)
+		}
+
 		for _, pair := range watcher.Pairs {
-			Logger.Info("Start streaming " + pair + " on " + exchange.GetName())
-			exchange.SubscribeSocket(watcher.ctx, pair)
+			symbol := symbolOn(pair, exchange.GetName())
+			streamedPairs[exchange.GetName()+":"+symbol] = pair
+
+			Logger.Info("Start streaming " + symbol + " on " + exchange.GetName())
+			err := exchange.SubscribeSocket(watcher.ctx, symbol)
+			if err != nil {
+				Market.RecordError(exchange.GetName(), err, time.Now())
+				Logger.Error("Failed to subscribe " + symbol + " on " + exchange.GetName() + ": " + err.Error())
+				continue
+			}
+
+			exchangeUpdates, err := exchange.GetOrderBookUpdates(symbol)
+			if err != nil {
+				Logger.Error("Failed to get order book updates for " + symbol + " on " + exchange.GetName() + ": " + err.Error())
+				continue
+			}
+
+			go forwardOrderBookUpdates(watcher.ctx, exchangeUpdates, updates)
+		}
+	}
+
+	latestOrderBooks := make(map[string]map[domain.ExchangeEnum]domain.OrderBook) // pair => exchange => latest book
+	droppedAt := make(map[string]time.Time)                                       // exchange:symbol => when its stream last dropped
+	pendingPairs := make(map[string]bool)
+	debounce := time.NewTimer(watcher.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	analyses := make(map[string]chan []domain.OrderBook, len(watcher.Pairs)) // pair => books of the next analysis
+	for _, pair := range watcher.Pairs {
+		analyses[pair] = make(chan []domain.OrderBook, 1)
+		go watcher.analyzePair(pair, analyses[pair])
+	}
+
+	markPending := func(pair string) {
+		if len(pendingPairs) == 0 {
+			debounce.Reset(watcher.Debounce)
+		}
+		pendingPairs[pair] = true
+	}
+
+	for {
+		select {
+		case <-watcher.ctx.Done():
+			Logger.Info("Stop streaming")
+			return
+		case orderbook := <-updates:
+			stream := orderbook.Exchange.String() + ":" + orderbook.Pair
+			if orderbook.FetchedAt.Before(droppedAt[stream]) {
+				// Still in flight when the stream dropped, the book rebuilt after reconnecting replaces it
+				continue
+			}
+			Market.RecordOrderBook(*orderbook, time.Now())
+			pair := streamedPairs[stream]
+			if latestOrderBooks[pair] == nil {
+				latestOrderBooks[pair] = make(map[domain.ExchangeEnum]domain.OrderBook)
+			}
+			latestOrderBooks[pair][orderbook.Exchange] = *orderbook
+			markPending(pair)
+		case state := <-states:
+			if state.State != domain.Disconnected && state.State != domain.Resyncing {
+				continue
+			}
+			exchange, err := domain.ParseExchange(state.Exchange)
+			if err != nil {
+				continue
+			}
+			// The book of a dropped stream stops changing without going stale until it is rebuilt
+			stream := state.Exchange + ":" + state.Pair
+			droppedAt[stream] = state.At
+			pair := streamedPairs[stream]
+			if _, ok := latestOrderBooks[pair][exchange]; ok {
+				delete(latestOrderBooks[pair], exchange)
+				markPending(pair)
+			}
+		case <-debounce.C:
+			now := time.Now()
+			for pair := range pendingPairs {
+				orderbooks := make([]domain.OrderBook, 0, len(latestOrderBooks[pair]))
+				for exchange, orderbook := range latestOrderBooks[pair] {
+					// The latest book of an exchange failing since is not trusted
+					if Market.GetCircuitState(exchange.String(), now) == domain.CircuitOpen {
+						continue
+					}
+					orderbooks = append(orderbooks, orderbook)
+				}
+
+				// Books waiting for a busy worker are superseded by the latest ones
+				select {
+				case <-analyses[pair]:
+				default:
+				}
+				analyses[pair] <- orderbooks
+			}
+			clear(pendingPairs)
+		}
+	}
+}
+
+// analyzePair analyzes the books of the pair sent by the stream loop, one set at a time, until the
+// watcher stops.
+func (watcher *ArbitrageScheduledWatcher) analyzePair(pair string, analyses <-chan []domain.OrderBook) {
+	for {
+		select {
+		case <-watcher.ctx.Done():
+			return
+		case streamed := <-analyses:
+			watcher.analyze(pair, streamed)
+		}
+	}
+}
+
+// analyzeStreamed converts the books streamed for another symbol into the pair's quote currency, then
+// processes the pair's books.
+func (watcher *ArbitrageScheduledWatcher) analyzeStreamed(pair string, streamed []domain.OrderBook) {
+	orderbooks := make([]domain.OrderBook, 0, len(streamed))
+	for _, orderbook := range streamed {
+		converted, err := toPair(watcher.ctx, orderbook, pair)
+		if err != nil {
+			Logger.Error("Failed to convert " + orderbook.Pair + " on " + orderbook.Exchange.String() + " into " + pair + ": " + err.Error())
+			continue
+		}
+		orderbooks = append(orderbooks, converted)
+	}
+	if len(orderbooks) < 2 {
+		closeOpportunities(pair, time.Now())
+		return
+	}
+
+	processOrderBooks(watcher.ctx, watcher.Exchanges, orderbooks, watcher.Interval)
+}
+
+func forwardOrderBookUpdates(ctx context.Context, from <-chan *domain.OrderBook, to chan<- *domain.OrderBook) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case orderbook, ok := <-from:
+			if !ok {
+				return
+			}
+			select {
+			case to <- orderbook:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// forwardConnectionStates publishes the stream connection states of an exchange as events, recording
+// the errors of dropped connections against the exchange, and forwards them to the stream loop.
+func forwardConnectionStates(ctx context.Context, from <-chan domain.ConnectionState, to chan<- domain.ConnectionState) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-from:
+			if !ok {
+				return
+			}
+			Logger.Info(state.Exchange + " stream for " + state.Pair + " is " + state.State.String())
+			if state.Error != "" && (state.State == domain.Disconnected || state.State == domain.Resyncing) {
+				Market.RecordError(state.Exchange, errors.New(state.Error), state.At)
+			}
+			Events.Publish(domain.NewConnectionEvent(state))
+			select {
+			case to <- state:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
@@ -84,21 +309,64 @@ func (watcher *ArbitrageScheduledWatcher) StartStream() {
 // 	}
 // }
 
-func Watch(pair string, exchanges map[string]domain.Exchanger, timeoutInterval time.Duration) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutInterval)
+// Watch fetches the pair's order books and processes them, giving up on the fetch after timeoutInterval.
+// Executions started from the opportunities found run until ctx is cancelled.
+func Watch(ctx context.Context, pair string, exchanges map[string]domain.Exchanger, timeoutInterval time.Duration) {
+	fetchCtx, cancel := context.WithTimeout(ctx, timeoutInterval)
 	defer cancel()
 
-	orderbooks, err := getOrderBookFromApi(ctx, exchanges, pair)
+	orderbooks, err := getOrderBookFromApi(fetchCtx, exchanges, pair)
 	if err != nil {
 		Logger.Error("Failed to get order books: " + err.Error())
+		closeOpportunities(pair, time.Now())
 		return
 	}
 
-	arbitrageOutput, _ := Analyze(orderbooks[0], orderbooks[1])
+	processOrderBooks(ctx, exchanges, orderbooks, timeoutInterval)
+}
+
+// processOrderBooks analyzes the order books of a single pair, resolves dynamic transfer fees and
+// withdraw/deposit minimums, then logs and alerts the resulting opportunities. Books older than their
+// exchange's MaxOrderBookAgeSeconds are left out. The lookups give up after timeout, while executions
+// of the alerted opportunities run until ctx is cancelled.
+func processOrderBooks(ctx context.Context, exchanges map[string]domain.Exchanger, orderbooks []domain.OrderBook, timeout time.Duration) {
+	executionCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
+	now := time.Now()
+	pair := orderbooks[0].Pair
+	orderbooks = freshOrderBooks(orderbooks, now)
+	if len(orderbooks) < 2 {
+		closeOpportunities(pair, now)
+		return
+	}
+	for _, orderbook := range orderbooks {
+		Events.Publish(domain.NewTopOfBookEvent(orderbook.GetTopOfBook(), now))
+	}
+
+	var balances domain.Balances
+	if Config.Arbitrage[pair].Mode == domain.Inventory {
+		var err error
+		balances, err = domain.LoadBalances(ctx, exchanges, Config.Trading.Balances)
+		if err != nil {
+			Logger.Error("Failed to get balances, using configured balances: " + err.Error())
+		}
+	}
+
+	arbitrageOutput, err := Analyze(balances, orderbooks...)
+	if err != nil {
+		Logger.Error("Failed to analyze order books: " + err.Error())
+		return
+	}
+
+	alertOpportunities := make([]domain.ArbitrageOpportunity, 0)
+	// Opportunities whose transfer checks failed are neither alerted nor closed until they can be checked again
+	unresolvedOpportunities := make([]domain.ArbitrageOpportunity, 0)
 	for _, arbitrageOutput := range arbitrageOutput {
+		applyCrossQuote(ctx, &arbitrageOutput, orderbooks)
 		if !checkArbitrageOutput(&arbitrageOutput) {
-			logArbitrageOutput(&arbitrageOutput)
+			recordArbitrageOutput(ctx, &arbitrageOutput, orderbooks)
 			continue
 		}
 
@@ -106,118 +374,235 @@ func Watch(pair string, exchanges map[string]domain.Exchanger, timeoutInterval t
 		buyExchange := exchanges[arbitrageOutput.BuyOn]
 		sellExchange := exchanges[arbitrageOutput.SellOn]
 
+		if arbitrageOutput.Mode == domain.Inventory {
+			// Nothing is transferred, so there is no transfer fee or withdraw/deposit minimum to check
+			recordArbitrageOutput(ctx, &arbitrageOutput, orderbooks)
+			if ShouldAlert(arbitrageOutput) {
+				alertOpportunities = append(alertOpportunities, arbitrageOutput)
+				executeOpportunity(executionCtx, arbitrageOutput, buyExchange, sellExchange)
+			}
+			continue
+		}
+
 		// Get transfer fee if not already set
 		if arbitrageOutput.IsDynamicTransferFee {
 			transferFee, err := getTransferFeeFromApi(ctx, buyExchange, sellExchange, arbitrageOutput.Pair, arbitrageOutput.BuyVolume)
 			if err != nil {
-				Logger.Error("Failed to get transfer fees: " + err.Error())
-				return
+				Logger.Error("Failed to get transfer fee from " + arbitrageOutput.BuyOn + " to " + arbitrageOutput.SellOn + " for " + arbitrageOutput.Pair + ": " + err.Error())
+				unresolvedOpportunities = append(unresolvedOpportunities, arbitrageOutput)
+				continue
 			}
 			arbitrageOutput.NativeTransferFee = transferFee
 		}
 
-		arbitrageOutput.TransferFee = arbitrageOutput.NativeTransferFee * arbitrageOutput.BuyPrice
-		arbitrageOutput.SellVolume = arbitrageOutput.BuyVolume - arbitrageOutput.NativeTransferFee
+		_, quoteScale, err := pairScales(arbitrageOutput.Pair)
+		if err != nil {
+			Logger.Error("Failed to get pair precision: " + err.Error())
+			unresolvedOpportunities = append(unresolvedOpportunities, arbitrageOutput)
+			continue
+		}
+		arbitrageOutput.TransferFee = transferCost(arbitrageOutput.NativeTransferFee, arbitrageOutput.BuyPrice, quoteScale)
+		arbitrageOutput.SellVolume = arbitrageOutput.BuyVolume.Sub(arbitrageOutput.NativeTransferFee)
 		arbitrageOutput.NetProfit = arbitrageOutput.GetNetProfit()
-		arbitrageOutput.Profitable = arbitrageOutput.NetProfit > 0
+		arbitrageOutput.Profitable = arbitrageOutput.NetProfit.Sign() > 0
 
-		logArbitrageOutput(&arbitrageOutput)
+		recordArbitrageOutput(ctx, &arbitrageOutput, orderbooks)
 
 		// Check withdrawal minimum on buy exchange
-		withdrawMin, err := buyExchange.GetWithdrawMin(arbitrageOutput.Pair)
+		withdrawMin, err := buyExchange.GetWithdrawMin(ctx, arbitrageOutput.Pair)
 		if err != nil {
-			Logger.Error("Failed to get withdrawal minimum: " + err.Error())
-			return
+			Logger.Error("Failed to get withdrawal minimum on " + arbitrageOutput.BuyOn + " for " + arbitrageOutput.Pair + ": " + err.Error())
+			unresolvedOpportunities = append(unresolvedOpportunities, arbitrageOutput)
+			continue
 		}
-		if arbitrageOutput.BuyVolume < withdrawMin {
+		if arbitrageOutput.BuyVolume.Cmp(withdrawMin) < 0 {
 			Logger.Info(fmt.Sprintf("Buy volume %v is below withdrawal minimum %v", arbitrageOutput.BuyVolume, withdrawMin))
 			continue
 		}
 
 		// Check deposit minimum on sell exchange
-		depositMin, err := sellExchange.GetDepositMin(arbitrageOutput.Pair)
+		depositMin, err := sellExchange.GetDepositMin(ctx, arbitrageOutput.Pair)
 		if err != nil {
-			Logger.Error("Failed to get deposit minimum: " + err.Error())
-			return
+			Logger.Error("Failed to get deposit minimum on " + arbitrageOutput.SellOn + " for " + arbitrageOutput.Pair + ": " + err.Error())
+			unresolvedOpportunities = append(unresolvedOpportunities, arbitrageOutput)
+			continue
 		}
-		if arbitrageOutput.SellVolume < depositMin {
+		if arbitrageOutput.SellVolume.Cmp(depositMin) < 0 {
 			Logger.Info(fmt.Sprintf("Sell volume %v is below deposit minimum %v", arbitrageOutput.SellVolume, depositMin))
 			continue
 		}
 
-		if arbitrageOutput.Profitable && arbitrageOutput.NetProfit >= 2 {
-			AlertDiscord(arbitrageOutput)
+		if ShouldAlert(arbitrageOutput) {
+			alertOpportunities = append(alertOpportunities, arbitrageOutput)
+			executeOpportunity(executionCtx, arbitrageOutput, buyExchange, sellExchange)
+		}
+	}
+
+	// Alert on lifecycle changes only, rather than on every analysis while a spread persists
+	for _, opportunityAlert := range Tracker.Update(pair, alertOpportunities, unresolvedOpportunities, now) {
+		alert(opportunityAlert)
+	}
+}
+
+// closeOpportunities closes the open opportunities of a pair that can no longer be analyzed, because
+// fewer than two of its order books are available or fresh.
+func closeOpportunities(pair string, now time.Time) {
+	for _, opportunityAlert := range Tracker.Update(pair, nil, nil, now) {
+		alert(opportunityAlert)
+	}
+}
+
+// ShouldAlert reports whether a checked opportunity is worth alerting on under its pair's alert policy.
+// Opportunities built on skewed order books are never alerted.
+func ShouldAlert(arbitrageOutput domain.ArbitrageOpportunity) bool {
+	return !arbitrageOutput.Skewed && GetAlertPolicy(arbitrageOutput.Pair).Allows(arbitrageOutput)
+}
+
+// freshOrderBooks returns the order books no older than their exchange's MaxOrderBookAgeSeconds at now.
+func freshOrderBooks(orderbooks []domain.OrderBook, now time.Time) []domain.OrderBook {
+	fresh := make([]domain.OrderBook, 0, len(orderbooks))
+	for _, orderbook := range orderbooks {
+		exchange := orderbook.Exchange.String()
+		if orderbook.IsStale(now, seconds(Config.Exchange[exchange].MaxOrderBookAgeSeconds)) {
+			Logger.Info(fmt.Sprintf("Skipping stale %s order book on %s: %v old", orderbook.Pair, exchange, orderbook.GetAge(now).Round(time.Millisecond)))
+			continue
 		}
+		fresh = append(fresh, orderbook)
+	}
+	return fresh
+}
+
+// GetAlertPolicy returns the configured alert policy of the pair. Pairs without an arbitrage config
+// alert from defaultMinProfit.
+func GetAlertPolicy(pair string) domain.AlertPolicy {
+	arbitrageConfig, ok := Config.Arbitrage[pair]
+	if !ok {
+		return domain.AlertPolicy{Mode: domain.MinAbsoluteProfit, MinProfit: defaultMinProfit}
+	}
+
+	return domain.AlertPolicy{
+		Mode:                arbitrageConfig.AlertPolicy,
+		MinProfit:           arbitrageConfig.MinProfit,
+		MinProfitPercentage: arbitrageConfig.MinProfitPercentage,
 	}
 }
 
+var defaultMinProfit = decimal.NewFromInt64(2)
+
+func executeOpportunity(ctx context.Context, arbitrageOutput domain.ArbitrageOpportunity, buyExchange domain.Exchanger, sellExchange domain.Exchanger) {
+	if !Config.Trading.Enabled {
+		return
+	}
+
+	if arbitrageOutput.BuySymbol != "" || arbitrageOutput.SellSymbol != "" {
+		Logger.Info("Trading enabled but cross-quote opportunities on " + arbitrageOutput.Pair + " are alerted only")
+		return
+	}
+
+	buyTrader, buyOk := buyExchange.(domain.TradingExchanger)
+	sellTrader, sellOk := sellExchange.(domain.TradingExchanger)
+	if !buyOk || !sellOk {
+		Logger.Info("Trading enabled but " + arbitrageOutput.BuyOn + " or " + arbitrageOutput.SellOn + " does not support order execution")
+		return
+	}
+
+	ExecuteAsync(ctx, arbitrageOutput, buyTrader, sellTrader)
+}
+
+// getOrderBookFromApi fetches the pair's order book from every exchange whose circuit breaker allows
+// it. Exchanges failing or timing out are recorded and left out, so the pair is still analyzed on the
+// others; it only fails when fewer than two order books are left to compare.
 func getOrderBookFromApi(ctx context.Context, exchanges map[string]domain.Exchanger, pair string) ([]domain.OrderBook, error) {
-	orderbooks := make([]domain.OrderBook, len(exchanges))
-	errCh := make(chan error, len(exchanges))
-	i := 0
+	type fetchResult struct {
+		exchange  string
+		orderbook domain.OrderBook
+		err       error
+	}
+	results := make(chan fetchResult, len(exchanges))
+	pending := make(map[string]bool)
 
-	for _, ex := range exchanges {
-		go func(i int, ex domain.Exchanger) {
-			orderbook, err := ex.GetCurrentOrderBook(pair)
+	for name, ex := range exchanges {
+		if !Market.Allow(name, time.Now()) {
+			Logger.Info("Skipping " + name + " for " + pair + ": circuit breaker open")
+			continue
+		}
+		pending[name] = true
+
+		go func(name string, ex domain.Exchanger) {
+			started := time.Now()
+			orderbook, err := ex.GetCurrentOrderBook(ctx, symbolOn(pair, name))
 			if err != nil {
-				errCh <- err
+				Market.RecordError(name, err, time.Now())
+				results <- fetchResult{exchange: name, err: err}
 				return
 			}
-			orderbooks[i] = orderbook
-			errCh <- nil
-		}(i, ex)
-		i++
+			Market.RecordLatency(name, time.Since(started))
+			Market.RecordOrderBook(orderbook, time.Now())
+
+			orderbook, err = toPair(ctx, orderbook, pair)
+			results <- fetchResult{exchange: name, orderbook: orderbook, err: err}
+		}(name, ex)
 	}
 
-	for i := range exchanges {
+	orderbooks := make([]domain.OrderBook, 0, len(pending))
+	var errs []error
+	for len(pending) > 0 {
 		select {
 		case <-ctx.Done():
-			Logger.Error("Timeout while getting order books for " + exchanges[i].GetName() + " Symbol:" + pair)
-			return nil, errors.New("timeout while getting order books for " + exchanges[i].GetName() + " Symbol:" + pair)
-		case err := <-errCh:
-			if err != nil {
-				Logger.Error("Failed to get order book for " + exchanges[i].GetName() + " Symbol:" + pair + " Error:" + err.Error())
-				return nil, err
+			for name := range pending {
+				err := errors.New("timeout while getting order book for " + name + " Symbol:" + pair)
+				Market.RecordError(name, err, time.Now())
+				Logger.Error(err.Error())
+				errs = append(errs, err)
 			}
+			clear(pending)
+		case result := <-results:
+			delete(pending, result.exchange)
+			if result.err != nil {
+				Logger.Error("Failed to get order book for " + result.exchange + " Symbol:" + pair + " Error:" + result.err.Error())
+				errs = append(errs, result.err)
+				continue
+			}
+			orderbooks = append(orderbooks, result.orderbook)
 		}
 	}
 
+	if len(orderbooks) < 2 {
+		return orderbooks, errors.Join(append(errs, fmt.Errorf("%d order books available for %s, at least 2 are needed", len(orderbooks), pair))...)
+	}
 	return orderbooks, nil
 }
 
-func getTransferFeeFromApi(ctx context.Context, fromExchange domain.Exchanger, toExchange domain.Exchanger, pair string, amount float32) (float32, error) {
-	errCh := make(chan error, 1)
-	var transferFee float32
-	var depositAddress string
-	var err error
+func getTransferFeeFromApi(ctx context.Context, fromExchange domain.Exchanger, toExchange domain.Exchanger, pair string, amount decimal.Decimal) (decimal.Decimal, error) {
+	type feeResult struct {
+		fee decimal.Decimal
+		err error
+	}
+	// Buffered so the lookup can finish and exit after a timeout without anyone reading the result
+	results := make(chan feeResult, 1)
 
 	go func() {
-		depositAddress, err = toExchange.GetDepositAddress(pair)
+		depositAddress, err := toExchange.GetDepositAddress(ctx, pair)
 		if err != nil {
-			errCh <- err
+			results <- feeResult{err: err}
 			return
 		}
 
-		transferFee, err = fromExchange.GetTransferFee(pair, depositAddress, amount)
-		if err != nil {
-			errCh <- err
-			return
-		}
-		errCh <- nil
+		transferFee, err := fromExchange.GetTransferFee(ctx, pair, depositAddress, amount)
+		results <- feeResult{fee: transferFee, err: err}
 	}()
 
 	select {
 	case <-ctx.Done():
 		Logger.Error("Timeout while getting transfer fee for " + fromExchange.GetName() + " Symbol:" + pair)
-		return 0, errors.New("timeout while getting transfer fee for " + fromExchange.GetName() + " Symbol:" + pair)
-	case err := <-errCh:
-		if err != nil {
-			Logger.Error("Failed to get transfer fee for " + fromExchange.GetName() + " Symbol:" + pair + " Error:" + err.Error())
-			return 0, err
+		return decimal.Zero(), errors.New("timeout while getting transfer fee for " + fromExchange.GetName() + " Symbol:" + pair)
+	case result := <-results:
+		if result.err != nil {
+			Logger.Error("Failed to get transfer fee for " + fromExchange.GetName() + " Symbol:" + pair + " Error:" + result.err.Error())
+			return decimal.Zero(), result.err
 		}
+		return result.fee, nil
 	}
-
-	return transferFee, nil
 }
 
 func checkArbitrageOutput(arbitrageOutput *domain.ArbitrageOpportunity) bool {
@@ -225,12 +610,12 @@ func checkArbitrageOutput(arbitrageOutput *domain.ArbitrageOpportunity) bool {
 		return false
 	}
 
-	if arbitrageOutput.BuyPrice > arbitrageOutput.SellPrice {
+	if arbitrageOutput.BuyPrice.Cmp(arbitrageOutput.SellPrice) > 0 {
 		Logger.Error("Buy price is greater than sell price for " + arbitrageOutput.Pair + " on " + arbitrageOutput.BuyOn + " and " + arbitrageOutput.SellOn)
 		return false
 	}
 
-	if arbitrageOutput.BuyVolume != arbitrageOutput.SellVolume {
+	if arbitrageOutput.BuyVolume.Cmp(arbitrageOutput.SellVolume) != 0 {
 		Logger.Error("Buy volume is not equal to sell volume for " + arbitrageOutput.Pair + " on " + arbitrageOutput.BuyOn + " and " + arbitrageOutput.SellOn)
 		return false
 	}
@@ -238,6 +623,27 @@ func checkArbitrageOutput(arbitrageOutput *domain.ArbitrageOpportunity) bool {
 	return true
 }
 
+// recordArbitrageOutput logs the opportunity and persists it with the order books it was computed from,
+// unless an analysis of the same route was stored recently, see shouldSave.
+func recordArbitrageOutput(ctx context.Context, arbitrageOutput *domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) {
+	logArbitrageOutput(arbitrageOutput)
+
+	if Store == nil || arbitrageOutput == nil || !shouldSave(*arbitrageOutput) {
+		return
+	}
+	snapshots := make([]domain.OrderBook, 0, 2)
+	for _, orderbook := range orderbooks {
+		if orderbook.Exchange.String() == arbitrageOutput.BuyOn || orderbook.Exchange.String() == arbitrageOutput.SellOn {
+			snapshots = append(snapshots, orderbook)
+		}
+	}
+	if _, err := Store.SaveOpportunity(ctx, *arbitrageOutput, snapshots); err != nil {
+		Logger.Error("Failed to save arbitrage output: " + err.Error())
+		return
+	}
+	markSaved(*arbitrageOutput)
+}
+
 func logArbitrageOutput(arbitrageOutput *domain.ArbitrageOpportunity) {
 	if arbitrageOutput != nil {
 		jsonBytes, err := json.Marshal(arbitrageOutput)
@@ -0,0 +1,163 @@
+package arbitrage
+
+import (
+	"context"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// streamExchange streams the books and connection states the test sends it.
+type streamExchange struct {
+	domain.Exchanger
+	name    string
+	updates chan *domain.OrderBook
+	states  chan domain.ConnectionState
+}
+
+func newStreamExchange(name string) *streamExchange {
+	return &streamExchange{name: name, updates: make(chan *domain.OrderBook, 8), states: make(chan domain.ConnectionState, 8)}
+}
+
+func (exchange *streamExchange) GetName() string { return exchange.name }
+
+func (exchange *streamExchange) SubscribeSocket(ctx context.Context, pair string) error { return nil }
+
+func (exchange *streamExchange) GetOrderBookUpdates(pair string) (<-chan *domain.OrderBook, error) {
+	return exchange.updates, nil
+}
+
+func (exchange *streamExchange) GetConnectionStates() <-chan domain.ConnectionState {
+	return exchange.states
+}
+
+// streamedBook is a SOLMYR book whose best ask tells the versions of a stream apart.
+func streamedBook(exchange domain.ExchangeEnum, ask string, fetchedAt time.Time) *domain.OrderBook {
+	return &domain.OrderBook{Exchange: exchange, Pair: "SOLMYR", Asks: levels(ask, "1"), Bids: levels("1000", "1"), FetchedAt: fetchedAt}
+}
+
+// describeBooks lists the books as exchange=ask, sorted by exchange.
+func describeBooks(orderbooks []domain.OrderBook) string {
+	books := make([]string, 0, len(orderbooks))
+	for _, orderbook := range orderbooks {
+		books = append(books, orderbook.Exchange.String()+"="+orderbook.Asks[0].Price.String())
+	}
+	slices.Sort(books)
+	return strings.Join(books, " ")
+}
+
+func TestStartStreamAnalyzesLatestBooks(t *testing.T) {
+	configure(t, `{}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan bool)
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	luno, hata := newStreamExchange("Luno"), newStreamExchange("Hata")
+	watcher := NewArbitrageScheduledWatcher(ctx, map[string]domain.Exchanger{"Luno": luno, "Hata": hata}, []string{"SOLMYR"}, time.Second, domain.Stream)
+	watcher.Debounce = 50 * time.Millisecond
+
+	analyzed := make(chan string, 8)
+	release := make(chan bool)
+	var blocking atomic.Bool
+	watcher.analyze = func(pair string, orderbooks []domain.OrderBook) {
+		analyzed <- describeBooks(orderbooks)
+		if blocking.Load() {
+			<-release
+		}
+	}
+	go func() {
+		watcher.StartStream()
+		stopped <- true
+	}()
+
+	expectAnalysis := func(step string, want string) {
+		t.Helper()
+		select {
+		case got := <-analyzed:
+			if got != want {
+				t.Fatalf("%s: expected %q analyzed; got %q", step, want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: timed out waiting for %q to be analyzed", step, want)
+		}
+	}
+	expectNoAnalysis := func(step string) {
+		t.Helper()
+		select {
+		case got := <-analyzed:
+			t.Fatalf("%s: expected no analysis; got %q", step, got)
+		case <-time.After(4 * watcher.Debounce):
+		}
+	}
+
+	// Updates of every exchange within the debounce are coalesced into one analysis of the latest books
+	now := time.Now()
+	luno.updates <- streamedBook(domain.Luno, "1040", now)
+	hata.updates <- streamedBook(domain.Hata, "1030", now)
+	luno.updates <- streamedBook(domain.Luno, "1041", now)
+	expectAnalysis("coalesced updates", "Hata=1030 Luno=1041")
+	expectNoAnalysis("after the coalesced updates")
+
+	// The book of a disconnected stream is dropped
+	droppedAt := time.Now()
+	luno.states <- domain.ConnectionState{Exchange: "Luno", Pair: "SOLMYR", State: domain.Disconnected, At: droppedAt}
+	expectAnalysis("disconnected stream", "Hata=1030")
+
+	// A book still in flight when the stream dropped is ignored, the one rebuilt afterwards is analyzed
+	luno.updates <- streamedBook(domain.Luno, "1042", droppedAt.Add(-time.Millisecond))
+	expectNoAnalysis("book from before the drop")
+	luno.updates <- streamedBook(domain.Luno, "1043", droppedAt.Add(time.Millisecond))
+	expectAnalysis("book rebuilt after the drop", "Hata=1030 Luno=1043")
+
+	// Resyncing drops the book like a disconnect
+	hata.states <- domain.ConnectionState{Exchange: "Hata", Pair: "SOLMYR", State: domain.Resyncing, At: time.Now()}
+	expectAnalysis("resyncing stream", "Luno=1043")
+
+	// Other states leave the books alone
+	hata.states <- domain.ConnectionState{Exchange: "Hata", Pair: "SOLMYR", State: domain.Connected, At: time.Now()}
+	expectNoAnalysis("connected stream")
+
+	// While the worker is busy, the books waiting for it are superseded by the latest ones
+	blocking.Store(true)
+	hata.updates <- streamedBook(domain.Hata, "1031", time.Now())
+	expectAnalysis("busy worker", "Hata=1031 Luno=1043")
+	hata.updates <- streamedBook(domain.Hata, "1032", time.Now())
+	time.Sleep(4 * watcher.Debounce)
+	hata.updates <- streamedBook(domain.Hata, "1033", time.Now())
+	time.Sleep(4 * watcher.Debounce)
+	blocking.Store(false)
+	release <- true
+	expectAnalysis("superseded books", "Hata=1033 Luno=1043")
+	expectNoAnalysis("after the superseded books")
+}
+
+func TestFreshOrderBooks(t *testing.T) {
+	configure(t, `{"Exchange": {"Luno": {"MaxOrderBookAgeSeconds": 5}, "MXGlobal": {"MaxOrderBookAgeSeconds": 0.5}}}`)
+	now := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		orderbook domain.OrderBook
+		fresh     bool
+	}{
+		{"recent book", domain.OrderBook{Exchange: domain.Luno, FetchedAt: now.Add(-4 * time.Second)}, true},
+		{"old book", domain.OrderBook{Exchange: domain.Luno, FetchedAt: now.Add(-6 * time.Second)}, false},
+		{"old exchange timestamp", domain.OrderBook{Exchange: domain.Luno, FetchedAt: now, ExchangeTimestamp: now.Add(-6 * time.Second)}, false},
+		{"stream kept alive", domain.OrderBook{Exchange: domain.Luno, FetchedAt: now.Add(-time.Minute), AliveAt: now.Add(-time.Second)}, true},
+		{"fractional limit", domain.OrderBook{Exchange: domain.MXGlobal, FetchedAt: now.Add(-time.Second)}, false},
+		{"no limit", domain.OrderBook{Exchange: domain.Hata, FetchedAt: now.Add(-time.Hour)}, true},
+		{"no timestamp", domain.OrderBook{Exchange: domain.Luno}, true},
+	}
+	for _, test := range tests {
+		fresh := freshOrderBooks([]domain.OrderBook{test.orderbook}, now)
+		if got := len(fresh) == 1; got != test.fresh {
+			t.Errorf("%s: expected fresh %v; got %v", test.name, test.fresh, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package backtest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/hata"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/luno"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/mxglobal"
+	"os"
+	"strings"
+	"time"
+)
+
+// Record is an order book fetched from an exchange REST API, as recorded in the scraping log.
+type Record struct {
+	Timestamp time.Time
+	OrderBook domain.OrderBook
+}
+
+// scrapingLogLine is a line of logs/scraping.log. The message holds the raw REST response body,
+// the exchange and pair fields identify which order book it is.
+type scrapingLogLine struct {
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"msg"`
+	Exchange  string `json:"exchange"`
+	Pair      string `json:"pair"`
+}
+
+// Same layout as zapcore.ISO8601TimeEncoder
+const scrapingLogTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+var parsers = map[domain.ExchangeEnum]func(pair string, respBody []byte) (domain.OrderBook, error){
+	domain.Luno:     luno.ParseOrderBookResponse,
+	domain.Hata:     hata.ParseOrderBookResponse,
+	domain.MXGlobal: mxglobal.ParseOrderBookResponse,
+}
+
+// ReadScrapingLogFile reads a scraping log file, transparently decompressing rotated .gz backups.
+func ReadScrapingLogFile(path string) (records []Record, skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	return ReadScrapingLog(reader)
+}
+
+// ReadScrapingLog parses order book responses from a scraping log. Lines that are not order book
+// responses, or were written before the exchange and pair were recorded, are counted as skipped.
+func ReadScrapingLog(reader io.Reader) (records []Record, skipped int, err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024) // order book responses are large
+
+	for scanner.Scan() {
+		var line scrapingLogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			skipped++
+			continue
+		}
+
+		exchange, err := domain.ParseExchange(line.Exchange)
+		if err != nil || line.Pair == "" {
+			skipped++
+			continue
+		}
+
+		timestamp, err := time.Parse(scrapingLogTimeLayout, line.Timestamp)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		orderbook, err := parsers[exchange](line.Pair, []byte(line.Message))
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		if orderbook.FetchedAt.IsZero() {
+			orderbook.FetchedAt = timestamp
+		}
+		records = append(records, Record{Timestamp: timestamp, OrderBook: orderbook})
+	}
+
+	return records, skipped, scanner.Err()
+}
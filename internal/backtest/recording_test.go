@@ -0,0 +1,80 @@
+package backtest
+
+import (
+	"compress/gzip"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func readFixture(t *testing.T) []Record {
+	t.Helper()
+	records, skipped, err := ReadScrapingLogFile("testdata/scraping.log")
+	if err != nil {
+		t.Fatalf("failed to read the scraping log: %v", err)
+	}
+	// A line without exchange, one that is not JSON, an empty book and a bad timestamp
+	if len(records) != 4 || skipped != 4 {
+		t.Fatalf("expected 4 records and 4 skipped lines; got %d and %d", len(records), skipped)
+	}
+	return records
+}
+
+func TestReadScrapingLog(t *testing.T) {
+	records := readFixture(t)
+
+	hata, luno := records[0], records[1]
+	if hata.OrderBook.Exchange != domain.Hata || hata.OrderBook.Pair != "SOLMYR" || !hata.Timestamp.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected Hata record %+v", hata)
+	}
+	if !hata.OrderBook.FetchedAt.Equal(hata.Timestamp) {
+		t.Errorf("expected the book fetched at the log timestamp; got %v", hata.OrderBook.FetchedAt)
+	}
+	if luno.OrderBook.Exchange != domain.Luno || luno.OrderBook.Bids[0].Volume.Cmp(dec("3")) != 0 {
+		t.Errorf("unexpected Luno record %+v", luno)
+	}
+	if !luno.OrderBook.ExchangeTimestamp.Equal(luno.Timestamp) {
+		t.Errorf("expected Luno's own timestamp; got %v", luno.OrderBook.ExchangeTimestamp)
+	}
+
+	// Books are sorted best price first whatever the order of the response
+	if asks := records[2].OrderBook.Asks; asks[0].Price.Cmp(dec("1000")) != 0 || asks[1].Price.Cmp(dec("1004")) != 0 {
+		t.Errorf("expected the asks sorted lowest first; got %v", asks)
+	}
+	if records[3].OrderBook.Exchange != domain.MXGlobal {
+		t.Errorf("expected the MXGlobal record last; got %+v", records[3])
+	}
+}
+
+func TestReadScrapingLogFileDecompressesBackups(t *testing.T) {
+	content, err := os.ReadFile("testdata/scraping.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "scraping-2024-11-01T09-00-00.000.log.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := gzip.NewWriter(file)
+	writer.Write(content)
+	writer.Close()
+	file.Close()
+
+	records, skipped, err := ReadScrapingLogFile(path)
+	if err != nil || len(records) != 4 || skipped != 4 {
+		t.Errorf("expected the same records from the compressed log; got %d records, %d skipped (%v)", len(records), skipped, err)
+	}
+}
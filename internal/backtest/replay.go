@@ -0,0 +1,132 @@
+package backtest
+
+import (
+	"cmp"
+	"malaysia-crypto-exchange-arbitrage/internal/arbitrage"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"slices"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+type Options struct {
+	MaxSkew time.Duration // maximum age difference between the books analyzed together
+	Pairs   []string      // pairs to replay, all when empty
+	From    time.Time
+	To      time.Time
+}
+
+type Bucket struct {
+	Opportunities int
+	Profit        decimal.Decimal
+}
+
+type Report struct {
+	Records            int // order books replayed
+	Evaluations        int // times the analyzer ran
+	Opportunities      int // opportunities that would have fired an alert
+	TotalProfit        decimal.Decimal
+	DynamicTransferFee int // fired opportunities whose transfer fee is only known from the exchange API
+	BelowMinimum       int // profitable opportunities rejected by withdraw/deposit minimums
+	ByPair             map[string]*Bucket
+	ByHour             [24]Bucket
+	First              time.Time
+	Last               time.Time
+}
+
+// Replay feeds the recorded order books through the analyzer in timestamp order. After each record
+// the latest book of every exchange for that pair is analyzed, ignoring books that are more than
+// MaxSkew older than the record, and the opportunities the watcher would have alerted are tallied.
+func Replay(records []Record, options Options) Report {
+	report := Report{ByPair: make(map[string]*Bucket)}
+
+	slices.SortStableFunc(records, func(a, b Record) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	type timestampedOrderBook struct {
+		timestamp time.Time
+		orderbook domain.OrderBook
+	}
+	latestOrderBooks := make(map[string]map[domain.ExchangeEnum]timestampedOrderBook) // pair => exchange => latest book
+
+	for _, record := range records {
+		pair := record.OrderBook.Pair
+		if len(options.Pairs) > 0 && !slices.Contains(options.Pairs, pair) {
+			continue
+		}
+		if (!options.From.IsZero() && record.Timestamp.Before(options.From)) || (!options.To.IsZero() && !record.Timestamp.Before(options.To)) {
+			continue
+		}
+
+		report.Records++
+		if report.First.IsZero() {
+			report.First = record.Timestamp
+		}
+		report.Last = record.Timestamp
+
+		if latestOrderBooks[pair] == nil {
+			latestOrderBooks[pair] = make(map[domain.ExchangeEnum]timestampedOrderBook)
+		}
+		latestOrderBooks[pair][record.OrderBook.Exchange] = timestampedOrderBook{timestamp: record.Timestamp, orderbook: record.OrderBook}
+
+		orderbooks := make([]domain.OrderBook, 0, len(latestOrderBooks[pair]))
+		for _, latest := range latestOrderBooks[pair] {
+			if record.Timestamp.Sub(latest.timestamp) <= options.MaxSkew {
+				orderbooks = append(orderbooks, latest.orderbook)
+			}
+		}
+		if len(orderbooks) < 2 {
+			continue
+		}
+		// Keep the analyzer input deterministic regardless of map order
+		slices.SortFunc(orderbooks, func(a, b domain.OrderBook) int {
+			return cmp.Compare(a.Exchange, b.Exchange)
+		})
+
+		report.Evaluations++
+		// Inventory mode pairs are sized by the configured starting balances, which are not drawn down between records
+		opportunities, err := arbitrage.Analyze(arbitrage.Config.Trading.Balances, orderbooks...)
+		if err != nil {
+			continue
+		}
+
+		for _, opportunity := range opportunities {
+			if !arbitrage.ShouldAlert(opportunity) {
+				continue
+			}
+			if opportunity.Mode == domain.Transfer && !meetsTransferMinimums(opportunity) {
+				report.BelowMinimum++
+				continue
+			}
+
+			report.Opportunities++
+			report.TotalProfit = report.TotalProfit.Add(opportunity.NetProfit)
+			if opportunity.IsDynamicTransferFee {
+				report.DynamicTransferFee++
+			}
+
+			if report.ByPair[pair] == nil {
+				report.ByPair[pair] = &Bucket{}
+			}
+			report.ByPair[pair].Opportunities++
+			report.ByPair[pair].Profit = report.ByPair[pair].Profit.Add(opportunity.NetProfit)
+
+			hour := record.Timestamp.Hour()
+			report.ByHour[hour].Opportunities++
+			report.ByHour[hour].Profit = report.ByHour[hour].Profit.Add(opportunity.NetProfit)
+		}
+	}
+
+	return report
+}
+
+// meetsTransferMinimums applies the configured withdraw and deposit minimums, as the live watcher
+// does through the exchange adapters.
+func meetsTransferMinimums(opportunity domain.ArbitrageOpportunity) bool {
+	withdrawMin := arbitrage.Config.Exchange[opportunity.BuyOn].Crypto[opportunity.Pair].WithdrawMinAmount
+	depositMin := arbitrage.Config.Exchange[opportunity.SellOn].Crypto[opportunity.Pair].DepositMinAmount
+
+	return opportunity.BuyVolume.Cmp(withdrawMin) >= 0 && opportunity.SellVolume.Sub(opportunity.NativeTransferFee).Cmp(depositMin) >= 0
+}
@@ -0,0 +1,84 @@
+package backtest
+
+import (
+	"encoding/json"
+	"malaysia-crypto-exchange-arbitrage/internal/arbitrage"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"testing"
+	"time"
+)
+
+func configure(t *testing.T) {
+	t.Helper()
+	var cfg config.Config
+	err := json.Unmarshal([]byte(`{
+		"Arbitrage": {"SOLMYR": {"MinProfit": 1}},
+		"Exchange": {
+			"Luno": {"Crypto": {"SOLMYR": {}}},
+			"Hata": {"Crypto": {"SOLMYR": {"WithdrawMinAmount": 0.01}}},
+			"MXGlobal": {"Crypto": {"SOLMYR": {"DepositMinAmount": 1.5}}}
+		},
+		"Precision": {"MYR": 2, "SOL": 4}
+	}`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	arbitrage.Configure(&cfg)
+}
+
+func TestReplay(t *testing.T) {
+	configure(t)
+
+	report := Replay(readFixture(t), Options{MaxSkew: 5 * time.Second})
+
+	if report.Records != 4 {
+		t.Errorf("expected 4 records replayed; got %d", report.Records)
+	}
+	// The 09:00 Hata book is not analyzed against the hour old Luno book
+	if report.Evaluations != 2 {
+		t.Errorf("expected 2 analyzer runs; got %d", report.Evaluations)
+	}
+	// Buying 2 SOL at 1000 on Hata and selling them at 1020 on Luno, without fees
+	if report.Opportunities != 1 || report.TotalProfit.Cmp(dec("40")) != 0 {
+		t.Errorf("expected one opportunity making 40; got %d making %v", report.Opportunities, report.TotalProfit)
+	}
+	// Selling 1 SOL on MXGlobal is below its deposit minimum
+	if report.BelowMinimum != 1 {
+		t.Errorf("expected one opportunity below the transfer minimums; got %d", report.BelowMinimum)
+	}
+	if bucket := report.ByPair["SOLMYR"]; bucket == nil || bucket.Opportunities != 1 {
+		t.Errorf("expected the opportunity counted for SOLMYR; got %+v", bucket)
+	}
+	if report.ByHour[8].Opportunities != 1 || report.ByHour[9].Opportunities != 0 {
+		t.Errorf("expected the opportunity counted at 08:00; got %+v", report.ByHour)
+	}
+	if !report.First.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)) || !report.Last.Equal(time.Date(2024, 11, 1, 1, 0, 1, 0, time.UTC)) {
+		t.Errorf("unexpected period %v - %v", report.First, report.Last)
+	}
+}
+
+func TestReplayFilters(t *testing.T) {
+	configure(t)
+
+	from := time.Date(2024, 11, 1, 1, 0, 0, 0, time.UTC)
+	report := Replay(readFixture(t), Options{MaxSkew: 5 * time.Second, From: from})
+	if report.Records != 2 || report.Evaluations != 1 || report.Opportunities != 0 {
+		t.Errorf("expected only the 09:00 records replayed; got %+v", report)
+	}
+
+	report = Replay(readFixture(t), Options{MaxSkew: 5 * time.Second, To: from})
+	if report.Records != 2 || report.Opportunities != 1 {
+		t.Errorf("expected only the 08:00 records replayed; got %+v", report)
+	}
+
+	report = Replay(readFixture(t), Options{MaxSkew: 5 * time.Second, Pairs: []string{"AVAXMYR"}})
+	if report.Records != 0 || report.Evaluations != 0 {
+		t.Errorf("expected no SOLMYR records replayed; got %+v", report)
+	}
+
+	// Without any skew allowed the books are never analyzed together
+	report = Replay(readFixture(t), Options{})
+	if report.Evaluations != 0 {
+		t.Errorf("expected no analyzer runs; got %d", report.Evaluations)
+	}
+}
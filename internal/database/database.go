@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"os"
 	"strconv"
 	"time"
@@ -22,6 +23,20 @@ type Service interface {
 	// Close terminates the database connection.
 	// It returns an error if the connection cannot be closed.
 	Close() error
+
+	// SaveOpportunity stores an arbitrage opportunity together with its planned orders
+	// and the order book snapshots it was computed from. It returns the new opportunity id.
+	SaveOpportunity(ctx context.Context, opportunity domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) (int64, error)
+
+	// DeleteOpportunitiesBefore deletes the opportunities detected before the given time, with their
+	// planned orders and order book snapshots. It returns how many opportunities were deleted.
+	DeleteOpportunitiesBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// GetOpportunities returns the stored opportunities matching the filter, newest first.
+	GetOpportunities(ctx context.Context, filter OpportunityFilter) ([]OpportunityRecord, error)
+
+	// GetOrderBookSnapshots returns the order books an opportunity was computed from.
+	GetOrderBookSnapshots(ctx context.Context, opportunityId int64) ([]domain.OrderBook, error)
 }
 
 type service struct {
@@ -39,17 +54,45 @@ func New() Service {
 		return dbInstance
 	}
 
-	db, err := sql.Open("sqlite3", dburl)
+	instance, err := open(dburl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbInstance = instance
+	return dbInstance
+}
+
+func open(url string) (*service, error) {
+	db, err := sql.Open("sqlite3", url)
 	if err != nil {
 		// This will not be a connection error, but a DSN parse error or
 		// another initialization error.
-		log.Fatal(err)
+		return nil, err
 	}
 
-	dbInstance = &service{
+	s := &service{
 		db: db,
 	}
-	return dbInstance
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return s, nil
+}
+
+// migrate creates the schema if it does not exist yet.
+func (s *service) migrate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, statement := range schema {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Health checks the health of the database connection by pinging the database.
@@ -0,0 +1,388 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// Amounts are stored as decimal strings so they round-trip exactly, times and durations as milliseconds.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS opportunities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		detected_at INTEGER NOT NULL,
+		pair TEXT NOT NULL,
+		buy_on TEXT NOT NULL,
+		sell_on TEXT NOT NULL,
+		buy_price TEXT NOT NULL,
+		buy_volume TEXT NOT NULL,
+		buy_fee TEXT NOT NULL,
+		total_buy_price TEXT NOT NULL,
+		sell_price TEXT NOT NULL,
+		sell_volume TEXT NOT NULL,
+		sell_fee TEXT NOT NULL,
+		total_sell_price TEXT NOT NULL,
+		price_diff TEXT NOT NULL,
+		native_transfer_fee TEXT NOT NULL,
+		transfer_fee TEXT NOT NULL,
+		net_profit TEXT NOT NULL,
+		profitable INTEGER NOT NULL,
+		is_dynamic_transfer_fee INTEGER NOT NULL,
+		optimal_volume TEXT NOT NULL,
+		profit_curve TEXT NOT NULL,
+		mode INTEGER NOT NULL,
+		buy_symbol TEXT NOT NULL,
+		sell_symbol TEXT NOT NULL,
+		fx_cost TEXT NOT NULL,
+		book_skew_ms INTEGER NOT NULL,
+		skewed INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_opportunities_pair_detected_at ON opportunities (pair, detected_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_opportunities_detected_at ON opportunities (detected_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_opportunities_buy_on ON opportunities (buy_on)`,
+	`CREATE INDEX IF NOT EXISTS idx_opportunities_sell_on ON opportunities (sell_on)`,
+	`CREATE TABLE IF NOT EXISTS opportunity_orders (
+		opportunity_id INTEGER NOT NULL REFERENCES opportunities (id) ON DELETE CASCADE,
+		side TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		price TEXT NOT NULL,
+		volume TEXT NOT NULL,
+		PRIMARY KEY (opportunity_id, side, position)
+	)`,
+	`CREATE TABLE IF NOT EXISTS orderbook_snapshots (
+		opportunity_id INTEGER NOT NULL REFERENCES opportunities (id) ON DELETE CASCADE,
+		exchange TEXT NOT NULL,
+		pair TEXT NOT NULL,
+		asks TEXT NOT NULL,
+		bids TEXT NOT NULL,
+		fetched_at INTEGER NOT NULL,
+		exchange_timestamp INTEGER NOT NULL,
+		alive_at INTEGER NOT NULL,
+		PRIMARY KEY (opportunity_id, exchange)
+	)`,
+}
+
+// OpportunityFilter selects stored opportunities. Zero values are ignored.
+type OpportunityFilter struct {
+	Pair         string
+	Exchange     string // matches either the buy or the sell exchange
+	BuyOn        string
+	SellOn       string
+	From         time.Time
+	To           time.Time
+	Profitable   *bool
+	MinNetProfit *decimal.Decimal
+	Limit        int
+}
+
+type OpportunityRecord struct {
+	Id int64
+	domain.ArbitrageOpportunity
+}
+
+const defaultOpportunityLimit = 100
+
+func (s *service) SaveOpportunity(ctx context.Context, opportunity domain.ArbitrageOpportunity, orderbooks []domain.OrderBook) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	detectedAt := opportunity.DetectedAt
+	if detectedAt.IsZero() {
+		detectedAt = time.Now()
+	}
+
+	profitCurve, err := json.Marshal(opportunity.ProfitCurve)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO opportunities (
+		detected_at, pair, buy_on, sell_on,
+		buy_price, buy_volume, buy_fee, total_buy_price,
+		sell_price, sell_volume, sell_fee, total_sell_price,
+		price_diff, native_transfer_fee, transfer_fee, net_profit,
+		profitable, is_dynamic_transfer_fee,
+		optimal_volume, profit_curve, mode,
+		buy_symbol, sell_symbol, fx_cost,
+		book_skew_ms, skewed
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		detectedAt.UnixMilli(), opportunity.Pair, opportunity.BuyOn, opportunity.SellOn,
+		opportunity.BuyPrice.String(), opportunity.BuyVolume.String(), opportunity.BuyFee.String(), opportunity.TotalBuyPrice.String(),
+		opportunity.SellPrice.String(), opportunity.SellVolume.String(), opportunity.SellFee.String(), opportunity.TotalSellPrice.String(),
+		opportunity.PriceDiff.String(), opportunity.NativeTransferFee.String(), opportunity.TransferFee.String(), opportunity.NetProfit.String(),
+		opportunity.Profitable, opportunity.IsDynamicTransferFee,
+		opportunity.OptimalVolume.String(), string(profitCurve), opportunity.Mode,
+		opportunity.BuySymbol, opportunity.SellSymbol, opportunity.FxCost.String(),
+		opportunity.BookSkew.Milliseconds(), opportunity.Skewed,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert opportunity: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for side, orders := range map[string][]domain.PriceLevel{"buy": opportunity.BuyOrders, "sell": opportunity.SellOrders} {
+		for position, order := range orders {
+			_, err := tx.ExecContext(ctx, `INSERT INTO opportunity_orders (opportunity_id, side, position, price, volume) VALUES (?, ?, ?, ?, ?)`,
+				id, side, position, order.Price.String(), order.Volume.String())
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert opportunity order: %w", err)
+			}
+		}
+	}
+
+	for _, orderbook := range orderbooks {
+		asks, err := json.Marshal(orderbook.Asks)
+		if err != nil {
+			return 0, err
+		}
+		bids, err := json.Marshal(orderbook.Bids)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO orderbook_snapshots (opportunity_id, exchange, pair, asks, bids, fetched_at, exchange_timestamp, alive_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, orderbook.Exchange.String(), orderbook.Pair, string(asks), string(bids),
+			unixMilli(orderbook.FetchedAt), unixMilli(orderbook.ExchangeTimestamp), unixMilli(orderbook.AliveAt))
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert order book snapshot: %w", err)
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+func (s *service) DeleteOpportunitiesBefore(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Foreign keys are not enforced on every connection, so the cascade is not relied upon
+	for _, table := range []string{"opportunity_orders", "orderbook_snapshots"} {
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE opportunity_id IN (SELECT id FROM opportunities WHERE detected_at < ?)`, before.UnixMilli())
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM opportunities WHERE detected_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete opportunities: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+func (s *service) GetOpportunities(ctx context.Context, filter OpportunityFilter) ([]OpportunityRecord, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	if filter.Pair != "" {
+		conditions = append(conditions, "pair = ?")
+		args = append(args, filter.Pair)
+	}
+	if filter.Exchange != "" {
+		conditions = append(conditions, "(buy_on = ? OR sell_on = ?)")
+		args = append(args, filter.Exchange, filter.Exchange)
+	}
+	if filter.BuyOn != "" {
+		conditions = append(conditions, "buy_on = ?")
+		args = append(args, filter.BuyOn)
+	}
+	if filter.SellOn != "" {
+		conditions = append(conditions, "sell_on = ?")
+		args = append(args, filter.SellOn)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "detected_at >= ?")
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "detected_at < ?")
+		args = append(args, filter.To.UnixMilli())
+	}
+	if filter.Profitable != nil {
+		conditions = append(conditions, "profitable = ?")
+		args = append(args, *filter.Profitable)
+	}
+	if filter.MinNetProfit != nil {
+		conditions = append(conditions, "CAST(net_profit AS REAL) >= ?")
+		args = append(args, filter.MinNetProfit.Float64())
+	}
+
+	query := `SELECT id, detected_at, pair, buy_on, sell_on,
+		buy_price, buy_volume, buy_fee, total_buy_price,
+		sell_price, sell_volume, sell_fee, total_sell_price,
+		price_diff, native_transfer_fee, transfer_fee, net_profit,
+		profitable, is_dynamic_transfer_fee,
+		optimal_volume, profit_curve, mode,
+		buy_symbol, sell_symbol, fx_cost,
+		book_skew_ms, skewed
+		FROM opportunities`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultOpportunityLimit
+	}
+	query += " ORDER BY detected_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]OpportunityRecord, 0)
+	for rows.Next() {
+		var record OpportunityRecord
+		var detectedAt int64
+		var profitCurve string
+		var bookSkew int64
+		err := rows.Scan(&record.Id, &detectedAt, &record.Pair, &record.BuyOn, &record.SellOn,
+			(*decimalColumn)(&record.BuyPrice), (*decimalColumn)(&record.BuyVolume), (*decimalColumn)(&record.BuyFee), (*decimalColumn)(&record.TotalBuyPrice),
+			(*decimalColumn)(&record.SellPrice), (*decimalColumn)(&record.SellVolume), (*decimalColumn)(&record.SellFee), (*decimalColumn)(&record.TotalSellPrice),
+			(*decimalColumn)(&record.PriceDiff), (*decimalColumn)(&record.NativeTransferFee), (*decimalColumn)(&record.TransferFee), (*decimalColumn)(&record.NetProfit),
+			&record.Profitable, &record.IsDynamicTransferFee,
+			(*decimalColumn)(&record.OptimalVolume), &profitCurve, &record.Mode,
+			&record.BuySymbol, &record.SellSymbol, (*decimalColumn)(&record.FxCost),
+			&bookSkew, &record.Skewed)
+		if err != nil {
+			return nil, err
+		}
+		record.DetectedAt = time.UnixMilli(detectedAt)
+		record.BookSkew = time.Duration(bookSkew) * time.Millisecond
+		if err := json.Unmarshal([]byte(profitCurve), &record.ProfitCurve); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range records {
+		records[i].BuyOrders, records[i].SellOrders, err = s.getOpportunityOrders(ctx, records[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+func (s *service) getOpportunityOrders(ctx context.Context, opportunityId int64) (buyOrders []domain.PriceLevel, sellOrders []domain.PriceLevel, err error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT side, price, volume FROM opportunity_orders WHERE opportunity_id = ? ORDER BY side, position`, opportunityId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	buyOrders = make([]domain.PriceLevel, 0)
+	sellOrders = make([]domain.PriceLevel, 0)
+	for rows.Next() {
+		var side string
+		var order domain.PriceLevel
+		if err := rows.Scan(&side, (*decimalColumn)(&order.Price), (*decimalColumn)(&order.Volume)); err != nil {
+			return nil, nil, err
+		}
+		if side == "buy" {
+			buyOrders = append(buyOrders, order)
+		} else {
+			sellOrders = append(sellOrders, order)
+		}
+	}
+
+	return buyOrders, sellOrders, rows.Err()
+}
+
+func (s *service) GetOrderBookSnapshots(ctx context.Context, opportunityId int64) ([]domain.OrderBook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT exchange, pair, asks, bids, fetched_at, exchange_timestamp, alive_at FROM orderbook_snapshots WHERE opportunity_id = ? ORDER BY exchange`, opportunityId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orderbooks := make([]domain.OrderBook, 0)
+	for rows.Next() {
+		var exchange, asks, bids string
+		var fetchedAt, exchangeTimestamp, aliveAt int64
+		var orderbook domain.OrderBook
+		if err := rows.Scan(&exchange, &orderbook.Pair, &asks, &bids, &fetchedAt, &exchangeTimestamp, &aliveAt); err != nil {
+			return nil, err
+		}
+		orderbook.FetchedAt = fromUnixMilli(fetchedAt)
+		orderbook.ExchangeTimestamp = fromUnixMilli(exchangeTimestamp)
+		orderbook.AliveAt = fromUnixMilli(aliveAt)
+		orderbook.Exchange, err = domain.ParseExchange(exchange)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(asks), &orderbook.Asks); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(bids), &orderbook.Bids); err != nil {
+			return nil, err
+		}
+		orderbooks = append(orderbooks, orderbook)
+	}
+
+	return orderbooks, rows.Err()
+}
+
+// unixMilli stores a time as milliseconds since the epoch, 0 for the zero time.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// fromUnixMilli reads back a time stored by unixMilli.
+func fromUnixMilli(milliseconds int64) time.Time {
+	if milliseconds == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(milliseconds)
+}
+
+// decimalColumn scans a stored amount into a decimal.
+type decimalColumn decimal.Decimal
+
+func (d *decimalColumn) Scan(src any) (err error) {
+	var value decimal.Decimal
+	switch src := src.(type) {
+	case string:
+		value, err = decimal.NewFromString(src)
+	case []byte:
+		value, err = decimal.NewFromString(string(src))
+	case int64:
+		value = decimal.NewFromInt64(src)
+	case nil:
+		value = decimal.Zero()
+	default:
+		return fmt.Errorf("cannot scan %T into a decimal", src)
+	}
+	if err != nil {
+		return err
+	}
+	*d = decimalColumn(value)
+	return nil
+}
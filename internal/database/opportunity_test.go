@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func newTestService(t *testing.T) *service {
+	s, err := open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestSaveAndQueryOpportunities(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	orderbooks := []domain.OrderBook{
+		{Exchange: domain.Hata, Pair: "SOLMYR", Asks: []domain.PriceLevel{{Price: dec("1040"), Volume: dec("1")}}, Bids: []domain.PriceLevel{{Price: dec("1039"), Volume: dec("2")}},
+			FetchedAt: start.Add(-1500 * time.Millisecond), ExchangeTimestamp: start.Add(-2 * time.Second)},
+		{Exchange: domain.Luno, Pair: "SOLMYR", Asks: []domain.PriceLevel{{Price: dec("1046"), Volume: dec("1")}}, Bids: []domain.PriceLevel{{Price: dec("1045"), Volume: dec("3")}},
+			FetchedAt: start.Add(-time.Minute), AliveAt: start},
+	}
+
+	opportunities := []domain.ArbitrageOpportunity{
+		{Pair: "SOLMYR", BuyOn: "Hata", SellOn: "Luno", BuyPrice: dec("1040"), BuyVolume: dec("1"), SellPrice: dec("1045"), SellVolume: dec("1"), NetProfit: dec("3.5"), Profitable: true, DetectedAt: start,
+			BookSkew: 1500 * time.Millisecond, Skewed: true, OptimalVolume: dec("1"), ProfitCurve: []domain.ProfitPoint{{Volume: dec("0.5"), NetProfit: dec("1.5")}, {Volume: dec("1"), NetProfit: dec("3.5")}},
+			BuyOrders: []domain.PriceLevel{{Price: dec("1040"), Volume: dec("1")}}, SellOrders: []domain.PriceLevel{{Price: dec("1045"), Volume: dec("1")}}},
+		{Pair: "SOLMYR", BuyOn: "Luno", SellOn: "Hata", NetProfit: dec("-2"), DetectedAt: start.Add(time.Hour), Mode: domain.Inventory},
+		{Pair: "AVAXMYR", BuyOn: "MXGlobal", SellOn: "Hata", NetProfit: dec("1"), Profitable: true, DetectedAt: start.Add(2 * time.Hour),
+			BuySymbol: "AVAXUSDT", FxCost: dec("0.45")},
+	}
+
+	var firstId int64
+	for i, opportunity := range opportunities {
+		id, err := s.SaveOpportunity(ctx, opportunity, orderbooks)
+		if err != nil {
+			t.Fatalf("failed to save opportunity: %v", err)
+		}
+		if i == 0 {
+			firstId = id
+		}
+	}
+
+	records, err := s.GetOpportunities(ctx, OpportunityFilter{Pair: "SOLMYR"})
+	if err != nil {
+		t.Fatalf("failed to query opportunities: %v", err)
+	}
+	if len(records) != 2 || records[0].BuyOn != "Luno" {
+		t.Fatalf("expected 2 SOLMYR opportunities newest first; got %+v", records)
+	}
+	if records[0].Mode != domain.Inventory || records[1].Mode != domain.Transfer {
+		t.Errorf("expected the trading mode of each opportunity stored; got %v and %v", records[0].Mode, records[1].Mode)
+	}
+
+	profitable := true
+	records, _ = s.GetOpportunities(ctx, OpportunityFilter{Profitable: &profitable})
+	if len(records) != 2 {
+		t.Errorf("expected 2 profitable opportunities; got %d", len(records))
+	}
+
+	records, _ = s.GetOpportunities(ctx, OpportunityFilter{Exchange: "MXGlobal"})
+	if len(records) != 1 || records[0].Pair != "AVAXMYR" {
+		t.Fatalf("expected the MXGlobal opportunity; got %+v", records)
+	}
+	if records[0].BuySymbol != "AVAXUSDT" || records[0].SellSymbol != "" || records[0].FxCost.Cmp(dec("0.45")) != 0 {
+		t.Errorf("unexpected stored cross-quote legs %q %q costing %v", records[0].BuySymbol, records[0].SellSymbol, records[0].FxCost)
+	}
+
+	records, _ = s.GetOpportunities(ctx, OpportunityFilter{From: start, To: start.Add(time.Hour)})
+	if len(records) != 1 || records[0].Id != firstId {
+		t.Fatalf("expected only the first opportunity in the time range; got %+v", records)
+	}
+
+	record := records[0]
+	if !record.DetectedAt.Equal(start) || record.NetProfit.Cmp(dec("3.5")) != 0 || !record.Profitable {
+		t.Errorf("unexpected stored opportunity %+v", record)
+	}
+	if record.OptimalVolume.Cmp(dec("1")) != 0 || len(record.ProfitCurve) != 2 || record.ProfitCurve[1].Volume.Cmp(dec("1")) != 0 || record.ProfitCurve[1].NetProfit.Cmp(dec("3.5")) != 0 {
+		t.Errorf("unexpected stored sizing %v %+v", record.OptimalVolume, record.ProfitCurve)
+	}
+	if record.BookSkew != 1500*time.Millisecond || !record.Skewed {
+		t.Errorf("expected the book skew stored; got %v flagged %v", record.BookSkew, record.Skewed)
+	}
+	if len(record.BuyOrders) != 1 || record.BuyOrders[0].Price.Cmp(dec("1040")) != 0 || len(record.SellOrders) != 1 || record.SellOrders[0].Price.Cmp(dec("1045")) != 0 {
+		t.Errorf("unexpected stored orders %v %v", record.BuyOrders, record.SellOrders)
+	}
+
+	snapshots, err := s.GetOrderBookSnapshots(ctx, firstId)
+	if err != nil {
+		t.Fatalf("failed to query snapshots: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Exchange != domain.Hata || snapshots[1].Bids[0].Volume.Cmp(dec("3")) != 0 {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+	for i, snapshot := range snapshots {
+		stored := orderbooks[i]
+		if !snapshot.FetchedAt.Equal(stored.FetchedAt) || !snapshot.ExchangeTimestamp.Equal(stored.ExchangeTimestamp) || !snapshot.AliveAt.Equal(stored.AliveAt) {
+			t.Errorf("expected the %v snapshot taken at %v, %v and alive at %v; got %v, %v and %v", snapshot.Exchange,
+				stored.FetchedAt, stored.ExchangeTimestamp, stored.AliveAt, snapshot.FetchedAt, snapshot.ExchangeTimestamp, snapshot.AliveAt)
+		}
+	}
+}
+
+func TestDeleteOpportunitiesBefore(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	orderbooks := []domain.OrderBook{{Exchange: domain.Hata, Pair: "SOLMYR", Asks: []domain.PriceLevel{{Price: dec("1040"), Volume: dec("1")}}}}
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, err := s.SaveOpportunity(ctx, domain.ArbitrageOpportunity{Pair: "SOLMYR", BuyOn: "Hata", SellOn: "Luno", DetectedAt: start.Add(time.Duration(i) * time.Hour),
+			BuyOrders: []domain.PriceLevel{{Price: dec("1040"), Volume: dec("1")}}}, orderbooks)
+		if err != nil {
+			t.Fatalf("failed to save opportunity: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	deleted, err := s.DeleteOpportunitiesBefore(ctx, start.Add(90*time.Minute))
+	if err != nil || deleted != 2 {
+		t.Fatalf("expected 2 opportunities deleted; got %d (%v)", deleted, err)
+	}
+
+	records, _ := s.GetOpportunities(ctx, OpportunityFilter{})
+	if len(records) != 1 || records[0].Id != ids[2] {
+		t.Fatalf("expected only the newest opportunity left; got %+v", records)
+	}
+	var orders, snapshots int
+	s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM opportunity_orders`).Scan(&orders)
+	s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orderbook_snapshots`).Scan(&snapshots)
+	if orders != 1 || snapshots != 1 {
+		t.Errorf("expected the orders and snapshots of deleted opportunities gone; got %d orders and %d snapshots", orders, snapshots)
+	}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// AlertPolicy decides which opportunities of a pair are worth alerting on.
+type AlertPolicy struct {
+	Mode                AlertPolicyModeEnum
+	MinProfit           decimal.Decimal // net profit in the quote currency
+	MinProfitPercentage decimal.Decimal // net profit as a percentage of the capital spent buying
+}
+
+// Allows reports whether a profitable opportunity clears the thresholds the mode requires.
+func (policy AlertPolicy) Allows(opportunity ArbitrageOpportunity) bool {
+	if !opportunity.Profitable {
+		return false
+	}
+
+	absolute := opportunity.NetProfit.Cmp(policy.MinProfit) >= 0
+	percentage := opportunity.GetProfitPercentage().Cmp(policy.MinProfitPercentage) >= 0
+
+	switch policy.Mode {
+	case MinProfitPercentage:
+		return percentage
+	case MinAbsoluteProfitAndPercentage:
+		return absolute && percentage
+	default:
+		return absolute
+	}
+}
+
+// Alert is sent to notifiers when a tracked opportunity opens, moves significantly or closes.
+type Alert struct {
+	Type        EventTypeEnum        // OpportunityOpened, OpportunityUpdated or OpportunityClosed
+	Opportunity ArbitrageOpportunity // latest figures, the last ones seen when closed
+	OpenedAt    time.Time
+	AlertedAt   time.Time // when the transition was seen, the close time for closed opportunities
+	PeakProfit  decimal.Decimal
+}
+
+// GetDuration returns how long the opportunity had been open when alerted.
+func (alert Alert) GetDuration() time.Duration {
+	return alert.AlertedAt.Sub(alert.OpenedAt)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "testing"
+
+func TestAlertPolicyAllows(t *testing.T) {
+	// 3 MYR on 1000 MYR spent is a 0.3% return
+	opportunity := ArbitrageOpportunity{Profitable: true, TotalBuyPrice: dec("1000"), NetProfit: dec("3")}
+
+	tests := []struct {
+		name   string
+		policy AlertPolicy
+		want   bool
+	}{
+		{"absolute met", AlertPolicy{Mode: MinAbsoluteProfit, MinProfit: dec("3")}, true},
+		{"absolute missed", AlertPolicy{Mode: MinAbsoluteProfit, MinProfit: dec("3.01")}, false},
+		{"percentage met", AlertPolicy{Mode: MinProfitPercentage, MinProfit: dec("10"), MinProfitPercentage: dec("0.3")}, true},
+		{"percentage missed", AlertPolicy{Mode: MinProfitPercentage, MinProfitPercentage: dec("0.5")}, false},
+		{"both met", AlertPolicy{Mode: MinAbsoluteProfitAndPercentage, MinProfit: dec("2"), MinProfitPercentage: dec("0.25")}, true},
+		{"both with percentage missed", AlertPolicy{Mode: MinAbsoluteProfitAndPercentage, MinProfit: dec("2"), MinProfitPercentage: dec("0.5")}, false},
+		{"both with absolute missed", AlertPolicy{Mode: MinAbsoluteProfitAndPercentage, MinProfit: dec("5"), MinProfitPercentage: dec("0.1")}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.Allows(opportunity); got != test.want {
+			t.Errorf("%s: Allows = %v; want %v", test.name, got, test.want)
+		}
+	}
+
+	unprofitable := opportunity
+	unprofitable.Profitable = false
+	if (AlertPolicy{}).Allows(unprofitable) {
+		t.Error("expected an unprofitable opportunity to never alert")
+	}
+}
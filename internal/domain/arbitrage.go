@@ -1,27 +1,59 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
 type ArbitrageOpportunity struct {
 	Pair                 string
 	BuyOn                string
 	SellOn               string
-	BuyPrice             float32
-	BuyVolume            float32
-	BuyFee               float32
-	TotalBuyPrice        float32
-	SellPrice            float32
-	SellVolume           float32
-	SellFee              float32
-	TotalSellPrice       float32
-	PriceDiff            float32
-	NativeTransferFee    float32 // in native pair value (sol/avax/xrp/etc)
-	TransferFee          float32
-	NetProfit            float32
+	BuyPrice             decimal.Decimal
+	BuyVolume            decimal.Decimal
+	BuyFee               decimal.Decimal
+	TotalBuyPrice        decimal.Decimal
+	SellPrice            decimal.Decimal
+	SellVolume           decimal.Decimal
+	SellFee              decimal.Decimal
+	TotalSellPrice       decimal.Decimal
+	PriceDiff            decimal.Decimal
+	NativeTransferFee    decimal.Decimal // in native pair value (sol/avax/xrp/etc)
+	TransferFee          decimal.Decimal
+	NetProfit            decimal.Decimal
 	Profitable           bool
 	BuyOrders            []PriceLevel
 	SellOrders           []PriceLevel
-	IsDynamicTransferFee bool //need to acquire transfer fee from api
+	OptimalVolume        decimal.Decimal // trade size with the highest net profit
+	ProfitCurve          []ProfitPoint   // net profit after each matched order book level, up to the last profitable one
+	IsDynamicTransferFee bool            //need to acquire transfer fee from api
+	DetectedAt           time.Time
+	Mode                 ArbitrageModeEnum
+	BalancesAfter        Balances        `json:",omitempty"` // Inventory mode: buy and sell exchange balances once both legs fill
+	InventoryImbalance   decimal.Decimal // Inventory mode: share of the base currency held on the buy exchange after the trade, 0.5 is balanced
+	BuySymbol            string          `json:",omitempty"` // pair traded on the buy exchange when it is quoted in another currency than Pair, e.g. SOLUSDT
+	SellSymbol           string          `json:",omitempty"` // pair traded on the sell exchange when it is quoted in another currency than Pair
+	FxCost               decimal.Decimal // spread and fee of converting quote currencies on cross-quote legs, already included in NetProfit
+	BookSkew             time.Duration   // how far apart the buy and sell order books were taken
+	Skewed               bool            // BookSkew exceeds MaxOrderBookSkewSeconds, so the prices may never have coexisted
+}
+
+// ProfitPoint is the net profit of trading Volume units, after fees and transfer cost.
+type ProfitPoint struct {
+	Volume    decimal.Decimal
+	NetProfit decimal.Decimal
+}
+
+func (arbitrageOpportunity *ArbitrageOpportunity) GetNetProfit() decimal.Decimal {
+	return arbitrageOpportunity.TotalSellPrice.Sub(arbitrageOpportunity.TotalBuyPrice).Sub(arbitrageOpportunity.TransferFee)
 }
 
-func (arbitrageOpportunity *ArbitrageOpportunity) GetNetProfit() float32 {
-	return arbitrageOpportunity.TotalSellPrice - arbitrageOpportunity.TotalBuyPrice - arbitrageOpportunity.TransferFee
+// GetProfitPercentage returns the net profit as a percentage of the capital spent buying, or zero
+// when nothing was bought.
+func (arbitrageOpportunity *ArbitrageOpportunity) GetProfitPercentage() decimal.Decimal {
+	if arbitrageOpportunity.TotalBuyPrice.Sign() <= 0 {
+		return decimal.Zero()
+	}
+	return Quo(arbitrageOpportunity.NetProfit.MulInt64(100), arbitrageOpportunity.TotalBuyPrice, DefaultScale)
 }
@@ -1,5 +1,7 @@
 package domain
 
+import "fmt"
+
 type SlippageDetectionModeEnum int
 
 const (
@@ -21,3 +23,56 @@ const (
 func (e ArbitrageWatcherModeEnum) String() string {
 	return []string{"Scheduled", "Stream"}[e]
 }
+
+type AlertPolicyModeEnum int
+
+const (
+	MinAbsoluteProfit AlertPolicyModeEnum = iota
+	MinProfitPercentage
+	MinAbsoluteProfitAndPercentage
+)
+
+func (e AlertPolicyModeEnum) String() string {
+	return []string{"MinAbsoluteProfit", "MinProfitPercentage", "MinAbsoluteProfitAndPercentage"}[e]
+}
+
+type EventTypeEnum int
+
+const (
+	OpportunityOpened EventTypeEnum = iota
+	OpportunityUpdated
+	OpportunityClosed
+	TopOfBookChanged
+	TriangularOpportunityDetected
+	ConnectionStateChanged
+)
+
+func (e EventTypeEnum) String() string {
+	return []string{"OpportunityOpened", "OpportunityUpdated", "OpportunityClosed", "TopOfBookChanged", "TriangularOpportunityDetected", "ConnectionStateChanged"}[e]
+}
+
+// MarshalText encodes the event type by name for websocket and webhook clients.
+func (e EventTypeEnum) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *EventTypeEnum) UnmarshalText(text []byte) error {
+	for _, eventType := range []EventTypeEnum{OpportunityOpened, OpportunityUpdated, OpportunityClosed, TopOfBookChanged, TriangularOpportunityDetected, ConnectionStateChanged} {
+		if eventType.String() == string(text) {
+			*e = eventType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %s", text)
+}
+
+type ArbitrageModeEnum int
+
+const (
+	Transfer  ArbitrageModeEnum = iota // buy, withdraw to the sell exchange, then sell
+	Inventory                          // buy and sell at once from balances already held on both exchanges
+)
+
+func (e ArbitrageModeEnum) String() string {
+	return []string{"Transfer", "Inventory"}[e]
+}
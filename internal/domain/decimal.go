@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// Decimal places used for an asset without a configured precision, and for ratios such as
+// average prices that are not settled by an exchange.
+const DefaultScale = 8
+
+// The helpers below complement luno-go's decimal, which only truncates towards zero.
+
+// RoundDown rounds d towards negative infinity to the given number of decimal places.
+func RoundDown(d decimal.Decimal, scale int) decimal.Decimal {
+	truncated := d.ToScale(scale)
+	if truncated.Cmp(d) > 0 {
+		return truncated.Sub(unit(scale))
+	}
+	return truncated
+}
+
+// RoundUp rounds d towards positive infinity to the given number of decimal places.
+func RoundUp(d decimal.Decimal, scale int) decimal.Decimal {
+	truncated := d.ToScale(scale)
+	if truncated.Cmp(d) < 0 {
+		return truncated.Add(unit(scale))
+	}
+	return truncated
+}
+
+// Round rounds d half away from zero to the given number of decimal places.
+func Round(d decimal.Decimal, scale int) decimal.Decimal {
+	truncated := d.ToScale(scale)
+	remainder := d.Sub(truncated)
+	half := decimal.New(big.NewInt(5), scale+1)
+
+	if remainder.Sign() > 0 && remainder.Cmp(half) >= 0 {
+		return truncated.Add(unit(scale))
+	}
+	if remainder.Sign() < 0 && remainder.Neg().Cmp(half) >= 0 {
+		return truncated.Sub(unit(scale))
+	}
+	return truncated
+}
+
+// Quo divides d by y, rounding the result half away from zero. y must not be zero.
+func Quo(d decimal.Decimal, y decimal.Decimal, scale int) decimal.Decimal {
+	return Round(d.Div(y, scale+1), scale)
+}
+
+// MinDecimal returns the smallest of the given values.
+func MinDecimal(first decimal.Decimal, rest ...decimal.Decimal) decimal.Decimal {
+	smallest := first
+	for _, value := range rest {
+		if value.Cmp(smallest) < 0 {
+			smallest = value
+		}
+	}
+	return smallest
+}
+
+// Normalize strips trailing zeros, so that equal values parsed with different precision
+// (1000 and 1000.00) also share the same String.
+func Normalize(d decimal.Decimal) decimal.Decimal {
+	s := d.String()
+	if !strings.Contains(s, ".") {
+		return d
+	}
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	normalized, err := decimal.NewFromString(s)
+	if err != nil {
+		return d
+	}
+	return normalized
+}
+
+func unit(scale int) decimal.Decimal {
+	return decimal.New(big.NewInt(1), scale)
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		value     string
+		scale     int
+		roundDown string
+		roundUp   string
+		round     string
+	}{
+		{"1.005", 2, "1.00", "1.01", "1.01"},
+		{"1.004", 2, "1.00", "1.01", "1.00"},
+		{"-1.005", 2, "-1.01", "-1.00", "-1.01"},
+		{"2.5", 0, "2", "3", "3"},
+		{"7", 2, "7.00", "7.00", "7.00"},
+	}
+
+	for _, test := range tests {
+		value := dec(test.value)
+		if got := RoundDown(value, test.scale); got.String() != test.roundDown {
+			t.Errorf("RoundDown(%s, %d) = %s; want %s", test.value, test.scale, got, test.roundDown)
+		}
+		if got := RoundUp(value, test.scale); got.String() != test.roundUp {
+			t.Errorf("RoundUp(%s, %d) = %s; want %s", test.value, test.scale, got, test.roundUp)
+		}
+		if got := Round(value, test.scale); got.String() != test.round {
+			t.Errorf("Round(%s, %d) = %s; want %s", test.value, test.scale, got, test.round)
+		}
+	}
+}
+
+func TestQuo(t *testing.T) {
+	if got := Quo(dec("2"), dec("3"), 4); got.String() != "0.6667" {
+		t.Errorf("Quo(2, 3, 4) = %s; want 0.6667", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for value, want := range map[string]string{"1000.00": "1000", "0.0500": "0.05", "1000": "1000"} {
+		if got := Normalize(dec(value)); got.String() != want {
+			t.Errorf("Normalize(%s) = %s; want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package domain
+
+import "time"
+
+// Event is published by the watcher for live consumers such as the websocket endpoint. Exactly one
+// of Opportunity, Triangular, TopOfBook and Connection is set, depending on Type.
+type Event struct {
+	Type        EventTypeEnum
+	Pair        string                 // the pair, or the route of a triangular cycle such as MYR>USDT>SOL>MYR
+	Exchanges   []string               // exchanges the event concerns: buy and sell exchange, or the book's exchange
+	Opportunity *ArbitrageOpportunity  `json:",omitempty"`
+	Triangular  *TriangularOpportunity `json:",omitempty"`
+	TopOfBook   *TopOfBook             `json:",omitempty"`
+	Connection  *ConnectionState       `json:",omitempty"`
+	Timestamp   time.Time
+}
+
+// TopOfBook is the best ask and bid of an exchange's order book. A side is nil when the book
+// has no levels on it.
+type TopOfBook struct {
+	Exchange string
+	Pair     string
+	BestAsk  *PriceLevel
+	BestBid  *PriceLevel
+}
+
+// GetTopOfBook returns the best levels of the order book.
+func (orderBook OrderBook) GetTopOfBook() TopOfBook {
+	top := TopOfBook{Exchange: orderBook.Exchange.String(), Pair: orderBook.Pair}
+	if len(orderBook.Asks) > 0 {
+		bestAsk := orderBook.Asks[0]
+		top.BestAsk = &bestAsk
+	}
+	if len(orderBook.Bids) > 0 {
+		bestBid := orderBook.Bids[0]
+		top.BestBid = &bestBid
+	}
+	return top
+}
+
+func NewOpportunityEvent(eventType EventTypeEnum, opportunity ArbitrageOpportunity, timestamp time.Time) Event {
+	return Event{
+		Type:        eventType,
+		Pair:        opportunity.Pair,
+		Exchanges:   []string{opportunity.BuyOn, opportunity.SellOn},
+		Opportunity: &opportunity,
+		Timestamp:   timestamp,
+	}
+}
+
+func NewTriangularEvent(opportunity TriangularOpportunity, timestamp time.Time) Event {
+	return Event{
+		Type:       TriangularOpportunityDetected,
+		Pair:       opportunity.GetRoute(),
+		Exchanges:  []string{opportunity.Exchange},
+		Triangular: &opportunity,
+		Timestamp:  timestamp,
+	}
+}
+
+func NewConnectionEvent(state ConnectionState) Event {
+	return Event{
+		Type:       ConnectionStateChanged,
+		Pair:       state.Pair,
+		Exchanges:  []string{state.Exchange},
+		Connection: &state,
+		Timestamp:  state.At,
+	}
+}
+
+func NewTopOfBookEvent(top TopOfBook, timestamp time.Time) Event {
+	return Event{
+		Type:      TopOfBookChanged,
+		Pair:      top.Pair,
+		Exchanges: []string{top.Exchange},
+		TopOfBook: &top,
+		Timestamp: timestamp,
+	}
+}
@@ -3,17 +3,35 @@ package domain
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
 )
 
 type Exchanger interface {
 	// StartOrderStream() (err error)
 	SubscribeSocket(ctx context.Context, pair string) (err error)
-	GetCurrentOrderBook(pair string) (output OrderBook, err error)
+	GetOrderBookUpdates(pair string) (updates <-chan *OrderBook, err error)
+	GetCurrentOrderBook(ctx context.Context, pair string) (output OrderBook, err error)
 	GetName() string
-	GetTransferFee(pair string, address string, amount float32) (fee float32, err error)
-	GetWithdrawMin(pair string) (min float32, err error)
-	GetDepositMin(pair string) (min float32, err error)
-	GetDepositAddress(pair string) (address string, err error)
+	GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (fee decimal.Decimal, err error)
+	GetWithdrawMin(ctx context.Context, pair string) (min decimal.Decimal, err error)
+	GetDepositMin(ctx context.Context, pair string) (min decimal.Decimal, err error)
+	GetDepositAddress(ctx context.Context, pair string) (address string, err error)
+}
+
+// ConnectionNotifier is implemented by exchanges reporting the state of their order book streams.
+type ConnectionNotifier interface {
+	GetConnectionStates() <-chan ConnectionState
+}
+
+// ConnectionState is a change of state of an exchange's order book stream for a pair.
+type ConnectionState struct {
+	Exchange string
+	Pair     string
+	State    ConnectionStateEnum
+	Error    string `json:",omitempty"` // why the stream disconnected or resyncs
+	At       time.Time
 }
 
 type ExchangeState struct {
@@ -22,3 +40,22 @@ type ExchangeState struct {
 	Stop      chan bool
 	Mutex     sync.Mutex
 }
+
+// PublishUpdate sends a copy of the current order book to Updates without blocking.
+// An update the subscriber has not consumed yet is replaced, so subscribers always
+// receive the latest book. Callers must hold Mutex.
+func (state *ExchangeState) PublishUpdate() {
+	if state.OrderBook == nil || state.Updates == nil {
+		return
+	}
+
+	orderBook := state.OrderBook.Clone()
+	select {
+	case <-state.Updates:
+	default:
+	}
+	select {
+	case state.Updates <- &orderBook:
+	default:
+	}
+}
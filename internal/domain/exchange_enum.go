@@ -1,12 +1,82 @@
 package domain
 
+import "fmt"
+
 type ExchangeEnum int
 
 const (
 	Luno ExchangeEnum = iota
 	Hata
+	MXGlobal
 )
 
 func (e ExchangeEnum) String() string {
-	return []string{"Luno", "Hata"}[e]
+	return []string{"Luno", "Hata", "MXGlobal"}[e]
+}
+
+// ParseExchange returns the exchange with the given name.
+func ParseExchange(name string) (ExchangeEnum, error) {
+	for _, exchange := range []ExchangeEnum{Luno, Hata, MXGlobal} {
+		if exchange.String() == name {
+			return exchange, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown exchange %s", name)
+}
+
+// CircuitStateEnum is the state of an exchange's circuit breaker.
+type CircuitStateEnum int
+
+const (
+	CircuitClosed   CircuitStateEnum = iota // the exchange is analyzed
+	CircuitOpen                             // the exchange failed repeatedly and is left out until its cooldown ends
+	CircuitHalfOpen                         // the cooldown ended, the next fetch decides whether the circuit closes or opens again
+)
+
+func (e CircuitStateEnum) String() string {
+	return []string{"Closed", "Open", "HalfOpen"}[e]
+}
+
+// MarshalText encodes the circuit state by name for API clients.
+func (e CircuitStateEnum) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *CircuitStateEnum) UnmarshalText(text []byte) error {
+	for _, state := range []CircuitStateEnum{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+		if state.String() == string(text) {
+			*e = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown circuit state %s", text)
+}
+
+// ConnectionStateEnum is the state of an exchange's order book stream for a pair.
+type ConnectionStateEnum int
+
+const (
+	Connecting   ConnectionStateEnum = iota // dialing the stream
+	Connected                               // subscribed, waiting for or applying the snapshot and its updates
+	Disconnected                            // the connection was lost or could not be made, it is retried with backoff
+	Resyncing                               // an update was missed, the stream reconnects for a fresh snapshot
+)
+
+func (e ConnectionStateEnum) String() string {
+	return []string{"Connecting", "Connected", "Disconnected", "Resyncing"}[e]
+}
+
+// MarshalText encodes the connection state by name for websocket clients.
+func (e ConnectionStateEnum) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *ConnectionStateEnum) UnmarshalText(text []byte) error {
+	for _, state := range []ConnectionStateEnum{Connecting, Connected, Disconnected, Resyncing} {
+		if state.String() == string(text) {
+			*e = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown connection state %s", text)
 }
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+var one = decimal.NewFromInt64(1)
+
+// FxProvider prices one quote currency in another, so books quoted in different currencies can be compared.
+type FxProvider interface {
+	GetRate(ctx context.Context, from string, to string) (rate FxRate, err error)
+}
+
+// FxRate converts From into To. Selling a unit of From yields Bid of To and buying one costs Ask,
+// each before Fee.
+type FxRate struct {
+	From   string
+	To     string
+	Bid    decimal.Decimal
+	Ask    decimal.Decimal
+	Fee    decimal.Decimal // charged as a fraction of every conversion
+	Source string          // exchange whose order book priced the rate, or Static
+}
+
+// Invert returns the rate converting To into From.
+func (rate FxRate) Invert() FxRate {
+	return FxRate{
+		From:   rate.To,
+		To:     rate.From,
+		Bid:    Quo(one, rate.Ask, DefaultScale),
+		Ask:    Quo(one, rate.Bid, DefaultScale),
+		Fee:    rate.Fee,
+		Source: rate.Source,
+	}
+}
+
+// GetConversionCost returns how much of To the spread and fee cost over the mid rate, for an amount
+// of To paid on a converted book's asks (buying) or received from its bids (selling).
+func (rate FxRate) GetConversionCost(amount decimal.Decimal, buying bool) decimal.Decimal {
+	mid := Quo(rate.Bid.Add(rate.Ask), decimal.NewFromInt64(2), DefaultScale)
+	if buying {
+		return amount.Sub(Quo(amount.Mul(mid), rate.Ask.Mul(one.Add(rate.Fee)), DefaultScale))
+	}
+	return Quo(amount.Mul(mid), rate.Bid.Mul(one.Sub(rate.Fee)), DefaultScale).Sub(amount)
+}
+
+// ConvertOrderBook prices the order book's quote currency in the rate's To currency, as pair. Asks
+// include buying the quote currency to pay with and bids selling the proceeds, so an analysis of the
+// converted book includes the conversion cost.
+func (rate FxRate) ConvertOrderBook(orderBook OrderBook, pair string) OrderBook {
+	converted := OrderBook{
+		Exchange: orderBook.Exchange,
+		Pair:     pair,
+		Symbol:   orderBook.Pair,
+		Asks:     make([]PriceLevel, 0, len(orderBook.Asks)),
+		Bids:     make([]PriceLevel, 0, len(orderBook.Bids)),
+
+		FetchedAt:         orderBook.FetchedAt,
+		ExchangeTimestamp: orderBook.ExchangeTimestamp,
+	}
+	askRate := rate.Ask.Mul(one.Add(rate.Fee))
+	bidRate := rate.Bid.Mul(one.Sub(rate.Fee))
+	for _, ask := range orderBook.Asks {
+		converted.Asks = append(converted.Asks, PriceLevel{Price: RoundUp(ask.Price.Mul(askRate), DefaultScale), Volume: ask.Volume})
+	}
+	for _, bid := range orderBook.Bids {
+		converted.Bids = append(converted.Bids, PriceLevel{Price: RoundDown(bid.Price.Mul(bidRate), DefaultScale), Volume: bid.Volume})
+	}
+	return converted
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePair(t *testing.T) {
+	for symbol, want := range map[string]Pair{
+		"SOLMYR":  {Symbol: "SOLMYR", Base: "SOL", Quote: "MYR"},
+		"SOLUSDT": {Symbol: "SOLUSDT", Base: "SOL", Quote: "USDT"},
+		"USDTMYR": {Symbol: "USDTMYR", Base: "USDT", Quote: "MYR"},
+	} {
+		if got, err := ParsePair(symbol); err != nil || got != want {
+			t.Errorf("ParsePair(%s) = %+v, %v; want %+v", symbol, got, err, want)
+		}
+	}
+	if _, err := ParsePair("MYR"); err == nil {
+		t.Errorf("expected an error for a symbol without a base currency")
+	}
+}
+
+func TestConvertOrderBook(t *testing.T) {
+	rate := FxRate{From: "USDT", To: "MYR", Bid: dec("4.4"), Ask: dec("4.5"), Fee: dec("0.01")}
+	orderBook := OrderBook{
+		Exchange: MXGlobal,
+		Pair:     "SOLUSDT",
+		Asks:     []PriceLevel{{Price: dec("100"), Volume: dec("2")}},
+		Bids:     []PriceLevel{{Price: dec("99"), Volume: dec("3")}},
+
+		FetchedAt: time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC),
+	}
+
+	converted := rate.ConvertOrderBook(orderBook, "SOLMYR")
+	if converted.Pair != "SOLMYR" || converted.Symbol != "SOLUSDT" || converted.Exchange != MXGlobal || !converted.FetchedAt.Equal(orderBook.FetchedAt) {
+		t.Fatalf("unexpected converted book %+v", converted)
+	}
+	// Buying 100 USDT costs 450 MYR plus the 1% fee, selling 99 USDT yields 435.6 MYR less the fee
+	if converted.Asks[0].Price.Cmp(dec("454.5")) != 0 || converted.Asks[0].Volume.Cmp(dec("2")) != 0 {
+		t.Errorf("unexpected converted ask %+v", converted.Asks[0])
+	}
+	if converted.Bids[0].Price.Cmp(dec("431.244")) != 0 || converted.Bids[0].Volume.Cmp(dec("3")) != 0 {
+		t.Errorf("unexpected converted bid %+v", converted.Bids[0])
+	}
+
+	// At a 4.45 mid, 454.5 MYR spent on the asks would have cost 445
+	if cost := rate.GetConversionCost(dec("454.5"), true); Round(cost, 2).Cmp(dec("9.5")) != 0 {
+		t.Errorf("expected buying conversion cost 9.5, got %v", cost)
+	}
+}
+
+func TestInvertRate(t *testing.T) {
+	inverse := FxRate{From: "USDT", To: "MYR", Bid: dec("4"), Ask: dec("5")}.Invert()
+	if inverse.From != "MYR" || inverse.To != "USDT" || inverse.Bid.Cmp(dec("0.2")) != 0 || inverse.Ask.Cmp(dec("0.25")) != 0 {
+		t.Errorf("unexpected inverse %+v", inverse)
+	}
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+// Notifier delivers opportunity alerts to a messaging service.
+type Notifier interface {
+	GetName() string
+	Notify(ctx context.Context, alert Alert) error
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "fmt"
+
+type NotifierTypeEnum int
+
+const (
+	Discord NotifierTypeEnum = iota
+	Telegram
+	Slack
+	Webhook
+)
+
+func (e NotifierTypeEnum) String() string {
+	return []string{"Discord", "Telegram", "Slack", "Webhook"}[e]
+}
+
+// ParseNotifierType returns the notifier type with the given name.
+func ParseNotifierType(name string) (NotifierTypeEnum, error) {
+	for _, notifierType := range []NotifierTypeEnum{Discord, Telegram, Slack, Webhook} {
+		if notifierType.String() == name {
+			return notifierType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown notifier type %s", name)
+}
@@ -1,13 +1,71 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
 type PriceLevel struct {
-	Price  float32
-	Volume float32
+	Price  decimal.Decimal
+	Volume decimal.Decimal
 }
 
 type OrderBook struct {
-	Exchange ExchangeEnum
-	Pair     string
-	Bids     []PriceLevel
-	Asks     []PriceLevel
+	Exchange          ExchangeEnum
+	Pair              string
+	Symbol            string `json:",omitempty"` // pair traded on the exchange when its prices were converted into Pair's quote currency, e.g. SOLUSDT
+	Bids              []PriceLevel
+	Asks              []PriceLevel
+	FetchedAt         time.Time // when the book, or the stream message last updating it, was received
+	ExchangeTimestamp time.Time // when the exchange reports the book was taken, zero when it does not
+	AliveAt           time.Time // when the stream maintaining the book was last known alive, so the book still current, zero for fetched books
+}
+
+// Clone returns a deep copy of the order book so it can be handed to other goroutines
+// while the original keeps being updated.
+func (orderBook OrderBook) Clone() OrderBook {
+	orderBook.Bids = append([]PriceLevel(nil), orderBook.Bids...)
+	orderBook.Asks = append([]PriceLevel(nil), orderBook.Asks...)
+	return orderBook
+}
+
+// GetTimestamp returns when the book was last known current. A streamed book is current for as long
+// as its stream is alive, even when nothing changed since the exchange took it; otherwise it is the
+// exchange's timestamp when it reports one, or when the book was received. It is zero for a book
+// carrying none of them.
+func (orderBook OrderBook) GetTimestamp() time.Time {
+	if !orderBook.AliveAt.IsZero() {
+		return orderBook.AliveAt
+	}
+	if !orderBook.ExchangeTimestamp.IsZero() {
+		return orderBook.ExchangeTimestamp
+	}
+	return orderBook.FetchedAt
+}
+
+// GetAge returns how old the book is at now, zero for a book without a timestamp.
+func (orderBook OrderBook) GetAge(now time.Time) time.Duration {
+	timestamp := orderBook.GetTimestamp()
+	if timestamp.IsZero() {
+		return 0
+	}
+	return max(now.Sub(timestamp), 0)
+}
+
+// IsStale reports whether the book is older than maxAge at now. A maxAge of 0 disables the check.
+func (orderBook OrderBook) IsStale(now time.Time, maxAge time.Duration) bool {
+	return maxAge > 0 && orderBook.GetAge(now) > maxAge
+}
+
+// GetSkew returns how far apart the two books were taken, zero when either has no timestamp.
+func GetSkew(a OrderBook, b OrderBook) time.Duration {
+	if a.GetTimestamp().IsZero() || b.GetTimestamp().IsZero() {
+		return 0
+	}
+	skew := a.GetTimestamp().Sub(b.GetTimestamp())
+	if skew < 0 {
+		return -skew
+	}
+	return skew
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderBookAge(t *testing.T) {
+	now := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	fetched := OrderBook{FetchedAt: now.Add(-3 * time.Second)}
+	if age := fetched.GetAge(now); age != 3*time.Second {
+		t.Errorf("expected the age since the book was fetched, got %v", age)
+	}
+
+	// The exchange's timestamp wins over the time the book was received
+	reported := OrderBook{FetchedAt: now.Add(-time.Second), ExchangeTimestamp: now.Add(-2 * time.Minute)}
+	if age := reported.GetAge(now); age != 2*time.Minute {
+		t.Errorf("expected the age since the exchange timestamp, got %v", age)
+	}
+	if !reported.IsStale(now, time.Minute) || reported.IsStale(now, 3*time.Minute) || reported.IsStale(now, 0) {
+		t.Error("expected the book stale only beyond a non zero max age")
+	}
+
+	// A quiet streamed book is as old as the last sign of life of its stream, not as its last change
+	streamed := OrderBook{FetchedAt: now.Add(-5 * time.Minute), ExchangeTimestamp: now.Add(-5 * time.Minute), AliveAt: now.Add(-2 * time.Second)}
+	if age := streamed.GetAge(now); age != 2*time.Second {
+		t.Errorf("expected the age since the stream was last alive, got %v", age)
+	}
+
+	// A clock ahead of ours does not make the age negative
+	ahead := OrderBook{ExchangeTimestamp: now.Add(time.Second)}
+	if age := ahead.GetAge(now); age != 0 {
+		t.Errorf("expected a zero age, got %v", age)
+	}
+
+	if (OrderBook{}).IsStale(now, time.Second) {
+		t.Error("expected a book without a timestamp never to be stale")
+	}
+}
+
+func TestGetSkew(t *testing.T) {
+	now := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	luno := OrderBook{Exchange: Luno, FetchedAt: now, ExchangeTimestamp: now.Add(-90 * time.Second)}
+	hata := OrderBook{Exchange: Hata, FetchedAt: now.Add(-500 * time.Millisecond)}
+
+	if skew := GetSkew(luno, hata); skew != 89500*time.Millisecond {
+		t.Errorf("expected 89.5s of skew, got %v", skew)
+	}
+	if GetSkew(hata, luno) != GetSkew(luno, hata) {
+		t.Error("expected the skew to be symmetric")
+	}
+	if skew := GetSkew(luno, OrderBook{Exchange: MXGlobal}); skew != 0 {
+		t.Errorf("expected no skew against a book without a timestamp, got %v", skew)
+	}
+}
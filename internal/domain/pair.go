@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pair is a market symbol split into the currency traded and the currency it is priced in.
+type Pair struct {
+	Symbol string // e.g. SOLMYR
+	Base   string // SOL
+	Quote  string // MYR
+}
+
+// Quote currencies recognised when splitting pair names such as SOLMYR into base and quote.
+var QuoteCurrencies = []string{"MYR", "USDT", "USDC", "BTC"}
+
+// ParsePair splits a symbol such as SOLMYR or SOLUSDT into its base and quote currencies.
+func ParsePair(symbol string) (pair Pair, err error) {
+	for _, quote := range QuoteCurrencies {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return Pair{Symbol: symbol, Base: strings.TrimSuffix(symbol, quote), Quote: quote}, nil
+		}
+	}
+	return pair, fmt.Errorf("unable to determine base and quote currency of pair %s", symbol)
+}
+
+// NewPair returns the pair trading base for quote.
+func NewPair(base string, quote string) Pair {
+	return Pair{Symbol: base + quote, Base: base, Quote: quote}
+}
+
+// SplitPair splits a pair name such as SOLMYR into its base (SOL) and quote (MYR) currencies.
+func SplitPair(symbol string) (base string, quote string, err error) {
+	pair, err := ParsePair(symbol)
+	return pair.Base, pair.Quote, err
+}
+
+func (pair Pair) String() string {
+	return pair.Symbol
+}
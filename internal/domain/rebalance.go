@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// RebalanceTransfer is a crypto transfer moving a currency from an exchange holding more than its
+// target allocation to one holding less.
+type RebalanceTransfer struct {
+	Currency     string
+	Pair         string // pair whose base currency is transferred, as the exchange transfer calls are made per pair
+	From         string
+	To           string
+	Amount       decimal.Decimal // withdrawn from From
+	Fee          decimal.Decimal // transfer fee in Currency
+	Received     decimal.Decimal // credited on To
+	WithdrawalId string          `json:",omitempty"` // set once the transfer was simulated
+}
+
+// RebalancePlan is the set of transfers proposed to restore the target allocations.
+type RebalancePlan struct {
+	CreatedAt time.Time
+	Balances  Balances // balances the plan was made from
+	Transfers []RebalanceTransfer
+	Skipped   []string // reasons a currency could not be fully rebalanced
+}
+
+// GetTotalFee returns the transfer fees of the plan per currency.
+func (plan *RebalancePlan) GetTotalFee() map[string]decimal.Decimal {
+	fees := make(map[string]decimal.Decimal)
+	for _, transfer := range plan.Transfers {
+		if fee, ok := fees[transfer.Currency]; ok {
+			fees[transfer.Currency] = fee.Add(transfer.Fee)
+		} else {
+			fees[transfer.Currency] = transfer.Fee
+		}
+	}
+	return fees
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// Trader is implemented by exchanges that can place orders and move funds.
+type Trader interface {
+	PlaceOrder(ctx context.Context, request OrderRequest) (order Order, err error)
+	CancelOrder(ctx context.Context, orderId string) (err error)
+	GetOrder(ctx context.Context, orderId string) (order Order, err error)
+	GetBalances(ctx context.Context) (balances map[string]decimal.Decimal, err error)
+	Withdraw(ctx context.Context, pair string, address string, amount decimal.Decimal) (withdrawalId string, err error) // withdraws the pair's base currency
+}
+
+// Balances holds the funds available on each exchange, exchange => currency => amount.
+type Balances map[string]map[string]decimal.Decimal
+
+// Get returns the amount of the currency held on the exchange, zero when unknown.
+func (balances Balances) Get(exchange string, currency string) decimal.Decimal {
+	if amount, ok := balances[exchange][currency]; ok {
+		return amount
+	}
+	return decimal.Zero()
+}
+
+// LoadBalances reads the balances of every exchange that can trade. Exchanges that cannot, or whose
+// balances fail to load, use their fallback balances instead; the load failures are joined into err.
+func LoadBalances(ctx context.Context, exchanges map[string]Exchanger, fallback Balances) (balances Balances, err error) {
+	balances = make(Balances, len(exchanges))
+	for name, exchange := range exchanges {
+		balances[name] = fallback[name]
+
+		trader, ok := exchange.(Trader)
+		if !ok {
+			continue
+		}
+		exchangeBalances, balanceErr := trader.GetBalances(ctx)
+		if balanceErr != nil {
+			err = errors.Join(err, fmt.Errorf("balances on %s: %w", name, balanceErr))
+			continue
+		}
+		balances[name] = exchangeBalances
+	}
+	return balances, err
+}
+
+// TradingExchanger is an exchange that provides both market data and order execution.
+type TradingExchanger interface {
+	Exchanger
+	Trader
+}
+
+type OrderRequest struct {
+	Pair   string
+	Side   OrderSideEnum
+	Type   OrderTypeEnum
+	Price  decimal.Decimal // limit price, ignored for market orders
+	Volume decimal.Decimal // in base currency
+}
+
+type Order struct {
+	Id           string
+	Exchange     string
+	Pair         string
+	Side         OrderSideEnum
+	Type         OrderTypeEnum
+	Price        decimal.Decimal
+	Volume       decimal.Decimal
+	FilledVolume decimal.Decimal // in base currency
+	FilledAmount decimal.Decimal // in quote currency, before fees
+	Fee          decimal.Decimal // in quote currency
+	Status       OrderStatusEnum
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// GetAveragePrice returns the volume weighted price of the filled part of the order.
+func (order *Order) GetAveragePrice() decimal.Decimal {
+	if order.FilledVolume.Sign() == 0 {
+		return decimal.Zero()
+	}
+	return Quo(order.FilledAmount, order.FilledVolume, DefaultScale)
+}
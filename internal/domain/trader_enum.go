@@ -0,0 +1,37 @@
+package domain
+
+type OrderSideEnum int
+
+const (
+	Buy OrderSideEnum = iota
+	Sell
+)
+
+func (e OrderSideEnum) String() string {
+	return []string{"Buy", "Sell"}[e]
+}
+
+type OrderTypeEnum int
+
+const (
+	Limit OrderTypeEnum = iota
+	Market
+)
+
+func (e OrderTypeEnum) String() string {
+	return []string{"Limit", "Market"}[e]
+}
+
+type OrderStatusEnum int
+
+const (
+	Open OrderStatusEnum = iota
+	PartiallyFilled
+	Filled
+	Cancelled
+	Rejected
+)
+
+func (e OrderStatusEnum) String() string {
+	return []string{"Open", "PartiallyFilled", "Filled", "Cancelled", "Rejected"}[e]
+}
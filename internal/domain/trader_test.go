@@ -0,0 +1,16 @@
+package domain
+
+import "testing"
+
+func TestBalancesGet(t *testing.T) {
+	balances := Balances{"Luno": {"MYR": dec("100")}}
+	if got := balances.Get("Luno", "MYR"); got.Cmp(dec("100")) != 0 {
+		t.Errorf("Get(Luno, MYR) = %s; want 100", got)
+	}
+	if got := balances.Get("Luno", "SOL"); got.Sign() != 0 {
+		t.Errorf("Get(Luno, SOL) = %s; want 0", got)
+	}
+	if got := Balances(nil).Get("Hata", "MYR"); got.Sign() != 0 {
+		t.Errorf("Get on nil balances = %s; want 0", got)
+	}
+}
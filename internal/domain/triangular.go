@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// TriangularOpportunity is a cycle of three trades on a single exchange that starts and ends in the
+// same currency, such as MYR -> USDT -> SOL -> MYR.
+type TriangularOpportunity struct {
+	Exchange         string
+	Path             []string // currencies in trade order, the start currency first and last
+	Legs             []TriangularLeg
+	StartAmount      decimal.Decimal // start currency spent on the first leg
+	EndAmount        decimal.Decimal // start currency received from the last leg, after fees
+	NetProfit        decimal.Decimal // in the start currency
+	ReturnPercentage decimal.Decimal
+	Profitable       bool
+	DetectedAt       time.Time
+}
+
+// TriangularLeg converts From into To by trading Pair as a taker, walking the order book.
+type TriangularLeg struct {
+	Pair   string
+	Side   OrderSideEnum
+	From   string
+	To     string
+	Input  decimal.Decimal // From spent
+	Output decimal.Decimal // To received, after the taker fee
+	Fee    decimal.Decimal // in To
+	Price  decimal.Decimal // volume weighted price of the matched levels
+	Orders []PriceLevel    // matched order book levels
+}
+
+// GetRoute returns the cycle as a readable route, e.g. MYR>USDT>SOL>MYR.
+func (opportunity *TriangularOpportunity) GetRoute() string {
+	return strings.Join(opportunity.Path, ">")
+}
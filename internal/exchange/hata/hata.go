@@ -1,35 +1,54 @@
 package hata
 
 import (
-	"cmp"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
 	"net/http"
 	"net/url"
 	"slices"
+	"sync"
+	"time"
 
 	"github.com/coder/websocket"
+	"github.com/luno/luno-go/decimal"
+	"go.uber.org/zap"
 )
 
 type HataExchange struct {
-	apiBaseUrl       string
-	websocketBaseUrl string
-	apiKeyId         string
-	apiKeySecret     string
-	state            domain.ExchangeState
+	apiBaseUrl          string
+	websocketBaseUrl    string
+	apiKeyId            string
+	apiKeySecret        string
+	httpClient          *http.Client
+	states              map[string]*HataExchangeState
+	statesMutex         sync.Mutex
+	connectionStates    chan domain.ConnectionState
+	reconnectMinBackoff time.Duration
+	reconnectMaxBackoff time.Duration
+	keepaliveInterval   time.Duration
+}
+
+type HataExchangeState struct {
+	domain.ExchangeState
+	asks     map[string]domain.PriceLevel // normalized price => level
+	bids     map[string]domain.PriceLevel // normalized price => level
+	hasBook  bool                         // snapshot received on the current connection
+	isActive bool                         // a stream goroutine is running for the pair
 }
 
 type HataOrderBookPriceFeed struct {
-	Price  float32 `json:"price,string"`
-	Volume float32 `json:"qty,string"`
+	Price  decimal.Decimal `json:"price"`
+	Volume decimal.Decimal `json:"qty"`
 }
 
 type HataOrderBookResponse struct {
@@ -40,18 +59,49 @@ type HataOrderBookResponse struct {
 	Status string `json:"status"`
 }
 
+type HataWebsocketSubscribeRequest struct {
+	Action   string `json:"action"`
+	Channel  string `json:"channel"`
+	PairName string `json:"pair_name"`
+}
+
+type HataWebsocketOrderBookMessage struct {
+	Channel  string `json:"channel"`
+	Type     string `json:"type"` // snapshot or update
+	PairName string `json:"pair_name"`
+	Data     struct {
+		Asks []HataOrderBookPriceFeed `json:"asks"`
+		Bids []HataOrderBookPriceFeed `json:"bids"`
+	} `json:"data"`
+}
+
 const hataApiBaseUrl = "https://my-api.hata.io"
 const hataWebsocketBaseUrl = "wss://my-api.hata.io/orderbook/ws"
+const hataOrderBookPath = "/orderbook/api/orderbook"
+const hataReconnectMinBackoff = 1 * time.Second
+const hataReconnectMaxBackoff = 30 * time.Second
+const hataKeepaliveInterval = 20 * time.Second
+
+// errInvalidFeed is returned by readOrderBookFeed for a message that could not be applied to the book,
+// which is then rebuilt from the snapshot of a new connection.
+var errInvalidFeed = errors.New("invalid Hata order book feed")
 
 var Logger = logger.Get()
+var StateLogger = logger.GetStateLogger()
 var ScrapingLogger = logger.GetScrapingLogger()
 
-func CreateClient(id string, secret string) *HataExchange {
+func CreateClient(id string, secret string, options httpclient.Options) *HataExchange {
 	exchange := HataExchange{
-		apiBaseUrl:       hataApiBaseUrl,
-		websocketBaseUrl: hataWebsocketBaseUrl,
-		apiKeyId:         id,
-		apiKeySecret:     secret,
+		apiBaseUrl:          hataApiBaseUrl,
+		websocketBaseUrl:    hataWebsocketBaseUrl,
+		apiKeyId:            id,
+		apiKeySecret:        secret,
+		httpClient:          httpclient.NewClient(domain.Hata.String(), options),
+		states:              make(map[string]*HataExchangeState),
+		connectionStates:    make(chan domain.ConnectionState, 64),
+		reconnectMinBackoff: hataReconnectMinBackoff,
+		reconnectMaxBackoff: hataReconnectMaxBackoff,
+		keepaliveInterval:   hataKeepaliveInterval,
 	}
 
 	return &exchange
@@ -61,7 +111,7 @@ func (exchange *HataExchange) GetName() string {
 	return domain.Hata.String()
 }
 
-func (exchange *HataExchange) GetTransferFee(pair string, address string, amount float32) (fee float32, err error) {
+func (exchange *HataExchange) GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (fee decimal.Decimal, err error) {
 	Config := config.GetConfig()
 	withdrawFee := Config.Exchange[domain.Hata.String()].Crypto
 
@@ -69,10 +119,10 @@ func (exchange *HataExchange) GetTransferFee(pair string, address string, amount
 		return fee.WithdrawFee, nil
 	}
 
-	return -1, nil
+	return decimal.NewFromInt64(-1), nil
 }
 
-func (exchange *HataExchange) GetWithdrawMin(pair string) (min float32, err error) {
+func (exchange *HataExchange) GetWithdrawMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
 	Config := config.GetConfig()
 	withdrawFee := Config.Exchange[domain.Hata.String()].Crypto
 
@@ -80,10 +130,10 @@ func (exchange *HataExchange) GetWithdrawMin(pair string) (min float32, err erro
 		return fee.WithdrawMinAmount, nil
 	}
 
-	return 0, nil
+	return decimal.Zero(), nil
 }
 
-func (exchange *HataExchange) GetDepositMin(pair string) (min float32, err error) {
+func (exchange *HataExchange) GetDepositMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
 	Config := config.GetConfig()
 	depositFee := Config.Exchange[domain.Hata.String()].Crypto
 
@@ -91,17 +141,17 @@ func (exchange *HataExchange) GetDepositMin(pair string) (min float32, err error
 		return fee.DepositMinAmount, nil
 	}
 
-	return 0, nil
+	return decimal.Zero(), nil
 }
 
-func (exchange *HataExchange) GetDepositAddress(pair string) (address string, err error) {
+func (exchange *HataExchange) GetDepositAddress(ctx context.Context, pair string) (address string, err error) {
 	Config := config.GetConfig()
 	depositAddress := Config.Exchange[domain.Hata.String()].Crypto[pair].Address
 
 	return depositAddress, nil
 }
 
-func (exchange *HataExchange) GetCurrentOrderBook(pair string) (output domain.OrderBook, err error) {
+func (exchange *HataExchange) GetCurrentOrderBook(ctx context.Context, pair string) (output domain.OrderBook, err error) {
 	params := url.Values{}
 	params.Set("pair_name", pair)
 	queryString := params.Encode()
@@ -110,7 +160,7 @@ func (exchange *HataExchange) GetCurrentOrderBook(pair string) (output domain.Or
 	hmac.Write([]byte(queryString))
 	signature := hex.EncodeToString(hmac.Sum(nil))
 
-	req, err := http.NewRequestWithContext(context.Background(), "GET", exchange.apiBaseUrl+"/orderbook/api/orderbook?"+queryString, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", exchange.apiBaseUrl+hataOrderBookPath+"?"+queryString, nil)
 	if err != nil {
 		Logger.Error("Error creating request: " + err.Error())
 		return
@@ -120,8 +170,7 @@ func (exchange *HataExchange) GetCurrentOrderBook(pair string) (output domain.Or
 
 	Logger.Info("Getting Hata order book for pair: " + pair)
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := exchange.httpClient.Do(req)
 	if err != nil {
 		Logger.Error("Error sending request: " + err.Error())
 		return
@@ -133,44 +182,53 @@ func (exchange *HataExchange) GetCurrentOrderBook(pair string) (output domain.Or
 		Logger.Error("Error reading response body: " + err.Error())
 		return
 	} else {
-		ScrapingLogger.Info(string(respBody))
+		ScrapingLogger.Info(string(respBody), zap.String("exchange", exchange.GetName()), zap.String("pair", pair), zap.String("endpoint", hataOrderBookPath))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return output, fmt.Errorf("Hata %s returned status %d: %s", hataOrderBookPath, resp.StatusCode, string(respBody))
+	}
+
+	output, err = ParseOrderBookResponse(pair, respBody)
+	if err != nil {
+		Logger.Error("Error parsing response body: " + err.Error())
+		return
 	}
+	output.FetchedAt = time.Now()
+
+	Logger.Info(fmt.Sprintf("[%s] Ask: [{%s %s}] [{%s %s}] => Bid: [{%s %s}] [{%s %s}]", pair,
+		output.Asks[len(output.Asks)-1].Price,
+		output.Asks[len(output.Asks)-1].Volume,
+		output.Asks[0].Price,
+		output.Asks[0].Volume,
+		output.Bids[0].Price,
+		output.Bids[0].Volume,
+		output.Bids[len(output.Bids)-1].Price,
+		output.Bids[len(output.Bids)-1].Volume,
+	))
+
+	return output, nil
+}
 
+// ParseOrderBookResponse converts a raw Hata order book response, as returned by the REST API and
+// recorded in the scraping log, into an order book sorted best price first.
+func ParseOrderBookResponse(pair string, respBody []byte) (output domain.OrderBook, err error) {
 	var respData HataOrderBookResponse
 	err = json.Unmarshal(respBody, &respData)
 	if err != nil {
-		Logger.Error("Error unmarshalling response body: " + err.Error())
-		return
+		return output, err
 	}
 
 	asks := respData.Data.Asks
 	bids := respData.Data.Bids
-
-	// Sort asks by price in ascending order (lowest first)
-	slices.SortFunc(asks, func(a, b HataOrderBookPriceFeed) int {
-		return cmp.Compare(a.Price, b.Price)
-	})
-
-	// Sort bids by price in descending order (highest first)
-	slices.SortFunc(bids, func(a, b HataOrderBookPriceFeed) int {
-		return cmp.Compare(b.Price, a.Price)
-	})
-
-	Logger.Info(fmt.Sprintf("[%s] Ask: [{%f %f}] [{%f %f}] => Bid: [{%f %f}] [{%f %f}]", pair,
-		asks[len(asks)-1].Price,
-		asks[len(asks)-1].Volume,
-		asks[0].Price,
-		asks[0].Volume,
-		bids[0].Price,
-		bids[0].Volume,
-		bids[len(bids)-1].Price,
-		bids[len(bids)-1].Volume,
-	))
+	if len(asks) == 0 || len(bids) == 0 {
+		return output, fmt.Errorf("empty Hata order book for pair %s", pair)
+	}
 
 	output.Pair = pair
 	output.Exchange = domain.Hata
-	output.Asks = make([]domain.PriceLevel, 0)
-	output.Bids = make([]domain.PriceLevel, 0)
+	output.Asks = make([]domain.PriceLevel, 0, len(asks))
+	output.Bids = make([]domain.PriceLevel, 0, len(bids))
 
 	for _, ask := range asks {
 		output.Asks = append(output.Asks, domain.PriceLevel{
@@ -187,44 +245,350 @@ func (exchange *HataExchange) GetCurrentOrderBook(pair string) (output domain.Or
 
 	// Sort asks by price in ascending order (lowest first)
 	slices.SortFunc(output.Asks, func(a, b domain.PriceLevel) int {
-		return cmp.Compare(a.Price, b.Price)
+		return a.Price.Cmp(b.Price)
 	})
 
 	// Sort bids by price in descending order (highest first)
 	slices.SortFunc(output.Bids, func(a, b domain.PriceLevel) int {
-		return cmp.Compare(b.Price, a.Price)
+		return b.Price.Cmp(a.Price)
 	})
 
 	return output, nil
 }
 
+// SubscribeSocket connects to the Hata order book stream for the pair and keeps a local book
+// in the exchange state, publishing every change to ExchangeState.Updates. The stream is supervised
+// until ctx is cancelled: the first connection and every reconnection after the stream drops are
+// dialed with exponential backoff, so a pair whose first dial fails is still streamed once Hata is
+// reachable again. Every change of the connection's state is sent to GetConnectionStates.
 func (exchange *HataExchange) SubscribeSocket(ctx context.Context, pair string) (err error) {
-	// Create message channel and store it in the exchange struct
+	Logger.Info("Subscribing to Hata websocket for pair: " + pair)
+
+	state := exchange.getOrCreateState(pair)
+
+	state.Mutex.Lock()
+	if state.isActive {
+		state.Mutex.Unlock()
+		return nil
+	}
+	state.isActive = true
+	state.Mutex.Unlock()
+
+	go exchange.superviseSocket(ctx, pair)
+
+	return nil
+}
+
+// superviseSocket connects and reads the connection until it fails, then reconnects until ctx is cancelled.
+func (exchange *HataExchange) superviseSocket(ctx context.Context, pair string) {
+	state := exchange.getOrCreateState(pair)
+	defer func() {
+		state.Mutex.Lock()
+		state.isActive = false
+		state.Mutex.Unlock()
+	}()
+
+	backoff := exchange.reconnectMinBackoff
+	for {
+		exchange.publishConnectionState(pair, domain.Connecting, nil)
+		c, err := exchange.connect(ctx, pair)
+		if err != nil {
+			if ctx.Err() != nil {
+				exchange.publishConnectionState(pair, domain.Disconnected, nil)
+				return
+			}
+			Logger.Error("Failed to connect Hata websocket for pair " + pair + ": " + err.Error())
+			exchange.publishConnectionState(pair, domain.Disconnected, err)
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, exchange.reconnectMaxBackoff)
+			continue
+		}
+		exchange.publishConnectionState(pair, domain.Connected, nil)
+		backoff = exchange.reconnectMinBackoff
+
+		err = exchange.readOrderBookFeed(ctx, c, pair)
+		c.CloseNow()
+		if ctx.Err() != nil {
+			Logger.Info("Received interrupt signal. Closed Hata websocket connection for pair: " + pair)
+			exchange.publishConnectionState(pair, domain.Disconnected, nil)
+			return
+		}
+
+		if errors.Is(err, errInvalidFeed) {
+			Logger.Error("Hata websocket for pair " + pair + " sent an invalid update, resyncing: " + err.Error())
+			exchange.publishConnectionState(pair, domain.Resyncing, err)
+		} else {
+			Logger.Error("Hata websocket for pair " + pair + " disconnected: " + err.Error())
+			exchange.publishConnectionState(pair, domain.Disconnected, err)
+		}
+
+		// The next connection starts with a fresh snapshot
+		exchange.resetState(pair)
+
+		if !sleep(ctx, backoff) {
+			return
+		}
+	}
+}
+
+// sleep waits for duration, reporting false when ctx is cancelled first.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
+
+// GetOrderBookUpdates returns the channel on which the streamed order book of the pair is published.
+// SubscribeSocket must be called for the pair first.
+func (exchange *HataExchange) GetOrderBookUpdates(pair string) (updates <-chan *domain.OrderBook, err error) {
+	state := exchange.GetState(pair)
+	if state == nil {
+		return nil, fmt.Errorf("pair %s is not subscribed on %s", pair, exchange.GetName())
+	}
+
+	return state.Updates, nil
+}
+
+// GetState returns the streaming state of the pair, or nil when the pair is not subscribed.
+func (exchange *HataExchange) GetState(pair string) *HataExchangeState {
+	exchange.statesMutex.Lock()
+	defer exchange.statesMutex.Unlock()
+
+	return exchange.states[pair]
+}
+
+func (exchange *HataExchange) getOrCreateState(pair string) *HataExchangeState {
+	exchange.statesMutex.Lock()
+	defer exchange.statesMutex.Unlock()
+
+	if exchange.states[pair] == nil {
+		exchange.states[pair] = &HataExchangeState{
+			ExchangeState: domain.ExchangeState{
+				OrderBook: &domain.OrderBook{Exchange: domain.Hata, Pair: pair},
+				Updates:   make(chan *domain.OrderBook, 1),
+				Stop:      make(chan bool),
+			},
+			asks: make(map[string]domain.PriceLevel),
+			bids: make(map[string]domain.PriceLevel),
+		}
+	}
+
+	return exchange.states[pair]
+}
 
+func (exchange *HataExchange) connect(ctx context.Context, pair string) (*websocket.Conn, error) {
 	c, _, err := websocket.Dial(ctx, exchange.websocketBaseUrl, nil)
 	if err != nil {
-		return err
+		Logger.Error("Failed to dial Hata websocket: " + err.Error())
+		return nil, err
+	}
+	c.SetReadLimit(-1) //Disable read limit
+
+	subscribeMessage, err := json.Marshal(HataWebsocketSubscribeRequest{
+		Action:   "subscribe",
+		Channel:  "orderbook",
+		PairName: pair,
+	})
+	if err != nil {
+		c.CloseNow()
+		return nil, err
+	}
+
+	err = c.Write(ctx, websocket.MessageText, subscribeMessage)
+	if err != nil {
+		Logger.Error("Failed to send subscribe message to Hata websocket: " + err.Error())
+		c.CloseNow()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// readOrderBookFeed applies the connection's messages to the book until the connection fails or a
+// message cannot be applied. Every frame received shows the stream alive, and the connection is
+// pinged every keepaliveInterval so a quiet book stays current.
+func (exchange *HataExchange) readOrderBookFeed(ctx context.Context, c *websocket.Conn, pair string) error {
+	keepaliveCtx, stopKeepalive := context.WithCancel(ctx)
+	defer stopKeepalive()
+	go exchange.keepalive(keepaliveCtx, c, pair)
+
+	for {
+		messageType, message, err := c.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if messageType != websocket.MessageText {
+			Logger.Error("Received unknown message type from Hata websocket: " + messageType.String())
+			exchange.markAlive(pair)
+			continue
+		}
+
+		err = exchange.processOrderBookFeed(message, pair)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidFeed, err)
+		}
 	}
-	defer c.CloseNow()
+}
 
-	// Start goroutine to read messages
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				c.Close(websocket.StatusNormalClosure, "")
+// keepalive pings the connection every keepaliveInterval, closing it when a ping goes unanswered so
+// the reader reconnects.
+func (exchange *HataExchange) keepalive(ctx context.Context, c *websocket.Conn, pair string) {
+	ticker := time.NewTicker(exchange.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, exchange.keepaliveInterval)
+			err := c.Ping(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				Logger.Error("Hata websocket for pair " + pair + " did not answer ping: " + err.Error())
+				c.CloseNow()
 				return
-			default:
-				// messageType, message, err := c.Read(ctx)
-				// if err != nil {
-				// 	return
-				// }
-				// if messageType == websocket.MessageText || messageType == websocket.MessageBinary {
-				// 	// exchange.messageChannel <- message
-				// }
+			}
+			if err == nil {
+				exchange.markAlive(pair)
 			}
 		}
-	}()
+	}
+}
+
+// markAlive republishes the book as still current after a frame that did not change it or an answered
+// ping. A quiet pair gets no updates, so without it the book would age as if the stream were down.
+func (exchange *HataExchange) markAlive(pair string) {
+	state := exchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	if !state.hasBook {
+		return
+	}
+	state.OrderBook.AliveAt = time.Now()
+	state.PublishUpdate()
+}
+
+// GetConnectionStates returns the channel receiving every change of state of the order book streams.
+// States are dropped while the channel is full.
+func (exchange *HataExchange) GetConnectionStates() <-chan domain.ConnectionState {
+	return exchange.connectionStates
+}
+
+func (exchange *HataExchange) publishConnectionState(pair string, connectionState domain.ConnectionStateEnum, err error) {
+	state := domain.ConnectionState{Exchange: exchange.GetName(), Pair: pair, State: connectionState, At: time.Now()}
+	if err != nil {
+		state.Error = err.Error()
+	}
+	select {
+	case exchange.connectionStates <- state:
+	default:
+	}
+}
+
+func (exchange *HataExchange) processOrderBookFeed(feedString []byte, pair string) error {
+	var feedMessage HataWebsocketOrderBookMessage
+	err := json.Unmarshal(feedString, &feedMessage)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal Hata order book feed: %v", err)
+	}
+
+	if feedMessage.Channel != "orderbook" || (feedMessage.PairName != "" && feedMessage.PairName != pair) {
+		exchange.markAlive(pair)
+		return nil
+	}
+
+	state := exchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	switch feedMessage.Type {
+	case "snapshot":
+		clear(state.asks)
+		clear(state.bids)
+		state.hasBook = true
+	case "update":
+		if !state.hasBook {
+			// Deltas are meaningless until the snapshot for this connection has been applied
+			return nil
+		}
+	default:
+		if state.hasBook {
+			state.OrderBook.AliveAt = time.Now()
+			state.PublishUpdate()
+		}
+		return nil
+	}
+
+	applyPriceLevels(state.asks, feedMessage.Data.Asks)
+	applyPriceLevels(state.bids, feedMessage.Data.Bids)
+
+	now := time.Now()
+	state.OrderBook = &domain.OrderBook{
+		Exchange: domain.Hata,
+		Pair:     pair,
+		Asks:     sortedPriceLevels(state.asks, false),
+		Bids:     sortedPriceLevels(state.bids, true),
+
+		FetchedAt: now,
+		AliveAt:   now,
+	}
+	StateLogger.Info("Current internal state for pair: " + pair + " is: " + fmt.Sprintf("%v", state.OrderBook))
+
+	state.PublishUpdate()
 
 	return nil
 }
+
+func (exchange *HataExchange) resetState(pair string) {
+	state := exchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	clear(state.asks)
+	clear(state.bids)
+	state.hasBook = false
+
+	// The book of the dropped connection is no longer current: nothing is served from it until the
+	// next snapshot, and an update of it not yet received is discarded
+	state.OrderBook = &domain.OrderBook{Exchange: domain.Hata, Pair: pair}
+	select {
+	case <-state.Updates:
+	default:
+	}
+}
+
+// applyPriceLevels sets the absolute volume of each level, removing levels with zero volume.
+// Levels are keyed by the normalized price so that 1040 and 1040.00 are the same level.
+func applyPriceLevels(levels map[string]domain.PriceLevel, feeds []HataOrderBookPriceFeed) {
+	for _, feed := range feeds {
+		key := domain.Normalize(feed.Price).String()
+		if feed.Volume.Sign() <= 0 {
+			delete(levels, key)
+		} else {
+			levels[key] = domain.PriceLevel{Price: feed.Price, Volume: feed.Volume}
+		}
+	}
+}
+
+func sortedPriceLevels(levels map[string]domain.PriceLevel, descending bool) []domain.PriceLevel {
+	output := make([]domain.PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		output = append(output, level)
+	}
+
+	slices.SortFunc(output, func(a, b domain.PriceLevel) int {
+		if descending {
+			return b.Price.Cmp(a.Price)
+		}
+		return a.Price.Cmp(b.Price)
+	})
+
+	return output
+}
@@ -0,0 +1,266 @@
+package hata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/luno/luno-go/decimal"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
+)
+
+func newTestStreamServer(t *testing.T, sessions [][]string) *HataExchange {
+	connections := make(chan int, len(sessions))
+	for i := range sessions {
+		connections <- i
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to accept websocket: %v", err)
+			return
+		}
+		defer c.CloseNow()
+
+		var session int
+		select {
+		case session = <-connections:
+		default:
+			// No more scripted sessions: keep the connection open until the client leaves
+			c.Read(r.Context())
+			return
+		}
+
+		_, message, err := c.Read(r.Context())
+		if err != nil {
+			return
+		}
+		var subscribe HataWebsocketSubscribeRequest
+		if err := json.Unmarshal(message, &subscribe); err != nil || subscribe.PairName != "SOLMYR" {
+			t.Errorf("unexpected subscribe message %s", string(message))
+		}
+
+		for _, message := range sessions[session] {
+			if err := c.Write(r.Context(), websocket.MessageText, []byte(message)); err != nil {
+				return
+			}
+		}
+		if session < len(sessions)-1 {
+			// Drop the connection to force a reconnect
+			return
+		}
+		c.Read(r.Context())
+	}))
+	t.Cleanup(server.Close)
+
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	exchange.websocketBaseUrl = "ws" + strings.TrimPrefix(server.URL, "http")
+	return exchange
+}
+
+func waitForBook(t *testing.T, updates <-chan *domain.OrderBook, check func(*domain.OrderBook) bool) *domain.OrderBook {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case orderBook := <-updates:
+			if check(orderBook) {
+				return orderBook
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for order book update")
+		}
+	}
+}
+
+func TestSubscribeSocketAppliesSnapshotAndDeltas(t *testing.T) {
+	exchange := newTestStreamServer(t, [][]string{{
+		`{"channel":"orderbook","type":"update","pair_name":"SOLMYR","data":{"asks":[{"price":"1","qty":"1"}]}}`,
+		`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{
+			"asks":[{"price":"1042","qty":"1"},{"price":"1040","qty":"2"}],
+			"bids":[{"price":"1038","qty":"3"},{"price":"1039","qty":"1"}]}}`,
+		`{"channel":"orderbook","type":"update","pair_name":"SOLMYR","data":{
+			"asks":[{"price":"1040","qty":"0"},{"price":"1041","qty":"0.5"}],
+			"bids":[{"price":"1039.5","qty":"4"}]}}`,
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	orderBook := waitForBook(t, exchange.GetState("SOLMYR").Updates, func(orderBook *domain.OrderBook) bool {
+		return len(orderBook.Bids) == 3
+	})
+
+	expectedAsks := []domain.PriceLevel{level("1041", "0.5"), level("1042", "1")}
+	expectedBids := []domain.PriceLevel{level("1039.5", "4"), level("1039", "1"), level("1038", "3")}
+	if !equalLevels(orderBook.Asks, expectedAsks) {
+		t.Errorf("expected asks %v; got %v", expectedAsks, orderBook.Asks)
+	}
+	if !equalLevels(orderBook.Bids, expectedBids) {
+		t.Errorf("expected bids %v; got %v", expectedBids, orderBook.Bids)
+	}
+}
+
+func TestSubscribeSocketReconnectsAndResyncs(t *testing.T) {
+	exchange := newTestStreamServer(t, [][]string{
+		{`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1040","qty":"1"}],"bids":[{"price":"1039","qty":"1"}]}}`},
+		{`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1050","qty":"2"}],"bids":[{"price":"1049","qty":"2"}]}}`},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	orderBook := waitForBook(t, exchange.GetState("SOLMYR").Updates, func(orderBook *domain.OrderBook) bool {
+		return len(orderBook.Asks) > 0 && orderBook.Asks[0].Price.Cmp(level("1050", "0").Price) == 0
+	})
+
+	if len(orderBook.Asks) != 1 || len(orderBook.Bids) != 1 {
+		t.Errorf("expected book from the new snapshot only; got asks %v bids %v", orderBook.Asks, orderBook.Bids)
+	}
+}
+
+func TestSubscribeSocketRetriesFirstDialAndReportsStates(t *testing.T) {
+	var dials atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dials.Add(1) == 1 {
+			// Hata is unreachable when the stream starts
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		if _, _, err := c.Read(r.Context()); err != nil {
+			return
+		}
+		c.Write(r.Context(), websocket.MessageText, []byte(`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1040","qty":"1"}],"bids":[{"price":"1039","qty":"1"}]}}`))
+		// A frame that does not change the book still shows the stream alive
+		time.Sleep(10 * time.Millisecond)
+		c.Write(r.Context(), websocket.MessageText, []byte(`{"channel":"heartbeat"}`))
+		c.Read(r.Context())
+	}))
+	t.Cleanup(server.Close)
+
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	exchange.websocketBaseUrl = "ws" + strings.TrimPrefix(server.URL, "http")
+	exchange.reconnectMinBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	orderBook := waitForBook(t, exchange.GetState("SOLMYR").Updates, func(orderBook *domain.OrderBook) bool {
+		return orderBook.AliveAt.After(orderBook.FetchedAt)
+	})
+	if len(orderBook.Asks) != 1 || len(orderBook.Bids) != 1 {
+		t.Errorf("expected the book kept alive unchanged; got asks %v bids %v", orderBook.Asks, orderBook.Bids)
+	}
+
+	expected := []domain.ConnectionStateEnum{domain.Connecting, domain.Disconnected, domain.Connecting, domain.Connected}
+	for i, want := range expected {
+		select {
+		case state := <-exchange.GetConnectionStates():
+			if state.State != want || state.Exchange != "Hata" || state.Pair != "SOLMYR" {
+				t.Errorf("expected state %d to be %v; got %+v", i, want, state)
+			}
+			if want == domain.Disconnected && state.Error == "" {
+				t.Errorf("expected the failed dial reported; got %+v", state)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for connection state %v", want)
+		}
+	}
+}
+
+func TestInvalidFeedResyncs(t *testing.T) {
+	exchange := newTestStreamServer(t, [][]string{
+		{
+			`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1040","qty":"1"}],"bids":[{"price":"1039","qty":"1"}]}}`,
+			`{"channel":"orderbook","type":"update","pair_name":"SOLMYR","data":{"asks":[{"price":"x"}]}}`,
+		},
+		{`{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1050","qty":"2"}],"bids":[{"price":"1049","qty":"2"}]}}`},
+	})
+	exchange.reconnectMinBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-exchange.GetConnectionStates():
+			if state.State == domain.Resyncing {
+				return
+			}
+			if state.State == domain.Disconnected {
+				t.Fatalf("expected the invalid update to resync rather than disconnect; got %+v", state)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for the stream to resync")
+		}
+	}
+}
+
+func TestResetStateDropsTheBook(t *testing.T) {
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	snapshot := `{"channel":"orderbook","type":"snapshot","pair_name":"SOLMYR","data":{"asks":[{"price":"1040","qty":"1"}],"bids":[{"price":"1039","qty":"1"}]}}`
+	if err := exchange.processOrderBookFeed([]byte(snapshot), "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exchange.resetState("SOLMYR")
+
+	state := exchange.GetState("SOLMYR")
+	if len(state.OrderBook.Asks) != 0 || len(state.OrderBook.Bids) != 0 {
+		t.Errorf("expected the book of the dropped connection cleared; got %+v", state.OrderBook)
+	}
+	select {
+	case orderBook := <-state.Updates:
+		t.Errorf("expected the pending update discarded; got %+v", orderBook)
+	default:
+	}
+}
+
+func equalLevels(a []domain.PriceLevel, b []domain.PriceLevel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Price.Cmp(b[i].Price) != 0 || a[i].Volume.Cmp(b[i].Volume) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func level(price string, volume string) domain.PriceLevel {
+	p, _ := decimal.NewFromString(price)
+	v, _ := decimal.NewFromString(volume)
+	return domain.PriceLevel{Price: p, Volume: v}
+}
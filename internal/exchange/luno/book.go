@@ -0,0 +1,206 @@
+package luno
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"math/rand"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// lunoOrder is an order resting in the Luno order book feed.
+type lunoOrder struct {
+	price  decimal.Decimal
+	volume decimal.Decimal
+	ask    bool
+}
+
+// bookSideMaxHeight bounds the skip list towers. With a quarter of the nodes promoted per level it
+// keeps operations logarithmic up to about 4^12 price levels, far beyond any Luno book.
+const bookSideMaxHeight = 12
+
+// levelNode is a price level in a bookSide skip list, linked to the next node at each of its heights.
+type levelNode struct {
+	level domain.PriceLevel
+	next  []*levelNode
+}
+
+// bookSide holds one side of the book as price levels sorted best price first, each aggregating
+// the volume of every order resting at its price. The levels form a skip list, so creating or
+// removing a level anywhere in a deep book takes O(log n), while the best price stays at the front.
+type bookSide struct {
+	head       levelNode // sentinel before the best level
+	height     int       // tallest tower in use
+	descending bool      // bids, highest price first
+}
+
+func newBookSide(descending bool) bookSide {
+	return bookSide{head: levelNode{next: make([]*levelNode, bookSideMaxHeight)}, height: 1, descending: descending}
+}
+
+// before reports whether price a sorts ahead of price b on this side.
+func (side *bookSide) before(a decimal.Decimal, b decimal.Decimal) bool {
+	if side.descending {
+		return a.Cmp(b) > 0
+	}
+	return a.Cmp(b) < 0
+}
+
+// search returns the node at price, or nil, and fills path with the last node before price at every
+// height, where a new level would be linked in.
+func (side *bookSide) search(price decimal.Decimal, path *[bookSideMaxHeight]*levelNode) *levelNode {
+	node := &side.head
+	for height := side.height - 1; height >= 0; height-- {
+		for node.next[height] != nil && side.before(node.next[height].level.Price, price) {
+			node = node.next[height]
+		}
+		path[height] = node
+	}
+	if next := node.next[0]; next != nil && next.level.Price.Cmp(price) == 0 {
+		return next
+	}
+	return nil
+}
+
+func (side *bookSide) add(price decimal.Decimal, volume decimal.Decimal) {
+	var path [bookSideMaxHeight]*levelNode
+	if node := side.search(price, &path); node != nil {
+		node.level.Volume = node.level.Volume.Add(volume)
+		return
+	}
+
+	height := 1
+	for height < bookSideMaxHeight && rand.Intn(4) == 0 {
+		height++
+	}
+	for ; side.height < height; side.height++ {
+		path[side.height] = &side.head
+	}
+
+	node := &levelNode{level: domain.PriceLevel{Price: price, Volume: volume}, next: make([]*levelNode, height)}
+	for i := 0; i < height; i++ {
+		node.next[i] = path[i].next[i]
+		path[i].next[i] = node
+	}
+}
+
+// remove takes volume off the level at price, dropping the level once it is empty.
+func (side *bookSide) remove(price decimal.Decimal, volume decimal.Decimal) {
+	var path [bookSideMaxHeight]*levelNode
+	node := side.search(price, &path)
+	if node == nil {
+		return
+	}
+	node.level.Volume = node.level.Volume.Sub(volume)
+	if node.level.Volume.Sign() > 0 {
+		return
+	}
+
+	for i := range node.next {
+		path[i].next[i] = node.next[i]
+	}
+	for side.height > 1 && side.head.next[side.height-1] == nil {
+		side.height--
+	}
+}
+
+// best returns the best price level.
+func (side *bookSide) best() (level domain.PriceLevel, ok bool) {
+	if side.head.next[0] == nil {
+		return level, false
+	}
+	return side.head.next[0].level, true
+}
+
+// levels returns a copy of every level, best price first.
+func (side *bookSide) levels() []domain.PriceLevel {
+	levels := make([]domain.PriceLevel, 0)
+	for node := side.head.next[0]; node != nil; node = node.next[0] {
+		levels = append(levels, node.level)
+	}
+	return levels
+}
+
+// depth returns a copy of the levels within maxPriceDiff, a fraction of the best price, of the best level.
+func (side *bookSide) depth(maxPriceDiff decimal.Decimal) []domain.PriceLevel {
+	levels := make([]domain.PriceLevel, 0)
+	first := side.head.next[0]
+	for node := first; node != nil; node = node.next[0] {
+		priceDiff := node.level.Price.Sub(first.level.Price)
+		if side.descending {
+			priceDiff = priceDiff.Neg()
+		}
+		if node != first && !withinPriceDiff(first.level.Price, priceDiff, maxPriceDiff) {
+			break
+		}
+		levels = append(levels, node.level)
+	}
+	return levels
+}
+
+// lunoBook is the order book rebuilt from the Luno feed: every resting order indexed by id, and
+// both sides aggregated into sorted price levels, so creates, trades and deletes find their order
+// directly and the best prices are always the first levels.
+type lunoBook struct {
+	orders map[string]*lunoOrder
+	asks   bookSide
+	bids   bookSide
+}
+
+func newLunoBook() *lunoBook {
+	return &lunoBook{
+		orders: make(map[string]*lunoOrder),
+		asks:   newBookSide(false),
+		bids:   newBookSide(true),
+	}
+}
+
+func (book *lunoBook) side(ask bool) *bookSide {
+	if ask {
+		return &book.asks
+	}
+	return &book.bids
+}
+
+// addOrder adds a resting order. An order already in the book is replaced.
+func (book *lunoBook) addOrder(id string, ask bool, price decimal.Decimal, volume decimal.Decimal) {
+	book.removeOrder(id)
+	if volume.Sign() <= 0 {
+		return
+	}
+	book.orders[id] = &lunoOrder{price: price, volume: volume, ask: ask}
+	book.side(ask).add(price, volume)
+}
+
+// removeOrder removes the order, reporting whether it was in the book.
+func (book *lunoBook) removeOrder(id string) bool {
+	order, ok := book.orders[id]
+	if !ok {
+		return false
+	}
+	delete(book.orders, id)
+	book.side(order.ask).remove(order.price, order.volume)
+	return true
+}
+
+// trade fills volume of a resting order, removing it once fully filled. It reports whether the
+// order was in the book.
+func (book *lunoBook) trade(id string, volume decimal.Decimal) bool {
+	order, ok := book.orders[id]
+	if !ok {
+		return false
+	}
+	if order.volume.Cmp(volume) <= 0 {
+		return book.removeOrder(id)
+	}
+	order.volume = order.volume.Sub(volume)
+	book.side(order.ask).remove(order.price, volume)
+	return true
+}
+
+func (book *lunoBook) bestAsk() (level domain.PriceLevel, ok bool) {
+	return book.asks.best()
+}
+
+func (book *lunoBook) bestBid() (level domain.PriceLevel, ok bool) {
+	return book.bids.best()
+}
@@ -0,0 +1,171 @@
+package luno
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func assertLevels(t *testing.T, side string, got []domain.PriceLevel, want ...string) {
+	t.Helper()
+	if len(got) != len(want)/2 {
+		t.Fatalf("%s: expected %d levels, got %v", side, len(want)/2, got)
+	}
+	for i, level := range got {
+		if level.Price.Cmp(dec(want[2*i])) != 0 || level.Volume.Cmp(dec(want[2*i+1])) != 0 {
+			t.Fatalf("%s: expected level %d at %s x %s, got %v", side, i, want[2*i], want[2*i+1], got)
+		}
+	}
+}
+
+func TestLunoBookKeepsLevelsSorted(t *testing.T) {
+	book := newLunoBook()
+	book.addOrder("a1", true, dec("1042"), dec("1"))
+	book.addOrder("a2", true, dec("1040"), dec("2"))
+	book.addOrder("a3", true, dec("1041"), dec("0.5"))
+	book.addOrder("a4", true, dec("1040.00"), dec("1"))
+	book.addOrder("b1", false, dec("1030"), dec("1"))
+	book.addOrder("b2", false, dec("1035"), dec("3"))
+
+	// Orders at the same price aggregate into one level, whatever their scale
+	assertLevels(t, "asks", book.asks.levels(), "1040", "3", "1041", "0.5", "1042", "1")
+	assertLevels(t, "bids", book.bids.levels(), "1035", "3", "1030", "1")
+
+	if ask, ok := book.bestAsk(); !ok || ask.Price.Cmp(dec("1040")) != 0 {
+		t.Errorf("expected the best ask at 1040, got %v", ask)
+	}
+	if bid, ok := book.bestBid(); !ok || bid.Price.Cmp(dec("1035")) != 0 {
+		t.Errorf("expected the best bid at 1035, got %v", bid)
+	}
+
+	// Partial and full fills take volume off the order's level
+	book.trade("a2", dec("0.5"))
+	assertLevels(t, "asks after a partial fill", book.asks.levels(), "1040", "2.5", "1041", "0.5", "1042", "1")
+	book.trade("a2", dec("1.5"))
+	book.trade("a4", dec("1"))
+	assertLevels(t, "asks after full fills", book.asks.levels(), "1041", "0.5", "1042", "1")
+	if _, ok := book.orders["a2"]; ok {
+		t.Error("expected a fully filled order to leave the book")
+	}
+
+	if !book.removeOrder("b2") || book.removeOrder("b2") {
+		t.Error("expected the order removed once")
+	}
+	assertLevels(t, "bids after delete", book.bids.levels(), "1030", "1")
+
+	if book.trade("unknown", dec("1")) {
+		t.Error("expected a trade on an unknown order to be ignored")
+	}
+}
+
+func TestBookSideStaysSortedUnderChurn(t *testing.T) {
+	side := newBookSide(true)
+	volumes := make(map[string]int64) // price => volume expected at the level
+	for i := int64(0); i < 5000; i++ {
+		price := decimal.NewFromInt64((i * 7919) % 1000)
+		if i%3 == 2 {
+			side.remove(price, decimal.NewFromInt64(1))
+			if volumes[price.String()] > 0 {
+				volumes[price.String()]--
+			}
+			continue
+		}
+		side.add(price, decimal.NewFromInt64(1))
+		volumes[price.String()]++
+	}
+
+	levels := side.levels()
+	count := 0
+	for _, volume := range volumes {
+		if volume > 0 {
+			count++
+		}
+	}
+	if len(levels) != count {
+		t.Fatalf("expected %d levels, got %d", count, len(levels))
+	}
+	for i, level := range levels {
+		if i > 0 && level.Price.Cmp(levels[i-1].Price) >= 0 {
+			t.Fatalf("expected bids highest first, got %v after %v", level.Price, levels[i-1].Price)
+		}
+		if want := volumes[level.Price.String()]; level.Volume.Cmp(decimal.NewFromInt64(want)) != 0 {
+			t.Fatalf("expected %d at %v, got %v", want, level.Price, level.Volume)
+		}
+	}
+	if best, ok := side.best(); !ok || best.Price.Cmp(levels[0].Price) != 0 {
+		t.Errorf("expected the best bid at %v, got %v", levels[0].Price, best)
+	}
+}
+
+func TestBookSideDepth(t *testing.T) {
+	book := newLunoBook()
+	book.addOrder("a1", true, dec("100"), dec("1"))
+	book.addOrder("a2", true, dec("101"), dec("1"))
+	book.addOrder("a3", true, dec("103"), dec("1"))
+	book.addOrder("b1", false, dec("99"), dec("1"))
+	book.addOrder("b2", false, dec("97.5"), dec("1"))
+	book.addOrder("b3", false, dec("96"), dec("1"))
+
+	assertLevels(t, "asks within 2%", book.asks.depth(dec("0.02")), "100", "1", "101", "1")
+	assertLevels(t, "bids within 2%", book.bids.depth(dec("0.02")), "99", "1", "97.5", "1")
+	assertLevels(t, "asks within 0%", book.asks.depth(decimal.Zero()), "100", "1")
+
+	// The depth is a copy the caller may hand to other goroutines
+	depth := book.asks.depth(dec("0.05"))
+	depth[0].Volume = dec("9")
+	if book.asks.levels()[0].Volume.Cmp(dec("1")) != 0 {
+		t.Error("expected the depth to be a copy of the levels")
+	}
+}
+
+func TestProcessFeedUpdateKeepsBestPrices(t *testing.T) {
+	exchange := &LunoExchange{states: make(map[string]*LunoExchangeState)}
+	state := exchange.getOrCreateState("SOLMYR")
+	maxPriceDiff := dec("0.1")
+
+	snapshot := &LunoOrderBookFeedSnapshot{
+		Asks: []LunoOrderBookPriceFeed{{Id: "a1", Price: dec("1040"), Volume: dec("1")}},
+		Bids: []LunoOrderBookPriceFeed{{Id: "b1", Price: dec("1030"), Volume: dec("1")}},
+	}
+	if err := exchange.processFeedSnapshot(snapshot, "SOLMYR", maxPriceDiff); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new ask below the best one becomes the best ask rather than being appended
+	update := &LunoOrderBookFeedMessage{CreateUpdate: &LunoOrderBookFeedCreateUpdate{OrderId: "a2", Type: "ASK", Price: dec("1038"), Volume: dec("2")}}
+	if err := exchange.processFeedUpdate(update, "SOLMYR", maxPriceDiff); err != nil {
+		t.Fatal(err)
+	}
+	update = &LunoOrderBookFeedMessage{CreateUpdate: &LunoOrderBookFeedCreateUpdate{OrderId: "b2", Type: "BID", Price: dec("1035"), Volume: dec("1")}}
+	if err := exchange.processFeedUpdate(update, "SOLMYR", maxPriceDiff); err != nil {
+		t.Fatal(err)
+	}
+
+	assertLevels(t, "asks", state.OrderBook.Asks, "1038", "2", "1040", "1")
+	assertLevels(t, "bids", state.OrderBook.Bids, "1035", "1", "1030", "1")
+	if price, volume, err := exchange.GetLowestAskPrice("SOLMYR"); err != nil || price.Cmp(dec("1038")) != 0 || volume.Cmp(dec("2")) != 0 {
+		t.Errorf("expected the lowest ask at 1038 x 2, got %v x %v (%v)", price, volume, err)
+	}
+
+	update = &LunoOrderBookFeedMessage{
+		TradeUpdates: []LunoOrderBookFeedTradeUpdate{{MakerOrderId: "a2", Base: dec("2")}},
+		DeleteUpdate: &LunoOrderBookFeedDeleteUpdate{OrderId: "b2"},
+	}
+	if err := exchange.processFeedUpdate(update, "SOLMYR", maxPriceDiff); err != nil {
+		t.Fatal(err)
+	}
+	assertLevels(t, "asks after trade", state.OrderBook.Asks, "1040", "1")
+	assertLevels(t, "bids after delete", state.OrderBook.Bids, "1030", "1")
+	if price, _, err := exchange.GetHighestBidPrice("SOLMYR"); err != nil || price.Cmp(dec("1030")) != 0 {
+		t.Errorf("expected the highest bid at 1030, got %v (%v)", price, err)
+	}
+}
@@ -1,47 +1,62 @@
 package luno
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
 	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/coder/websocket"
 	"github.com/luno/luno-go"
 	"github.com/luno/luno-go/decimal"
+	"go.uber.org/zap"
 )
 
 type LunoExchange struct {
-	lunoClient       luno.Client
-	websocketBaseUrl string
-	apiKeyId         string
-	apiKeySecret     string
-	states           map[string]*LunoExchangeState
+	lunoClient          luno.Client
+	websocketBaseUrl    string
+	apiKeyId            string
+	apiKeySecret        string
+	states              map[string]*LunoExchangeState
+	statesMutex         sync.Mutex
+	connectionStates    chan domain.ConnectionState
+	maxPriceDiff        func(pair string) decimal.Decimal // fraction of the best price beyond which streamed levels are left out
+	reconnectMinBackoff time.Duration
+	reconnectMaxBackoff time.Duration
+	keepaliveInterval   time.Duration
+	idleTimeout         time.Duration
 }
 
 type LunoExchangeState struct {
 	domain.ExchangeState
-	AsksOrderbook   []LunoOrderBookPriceFeed
-	BidsOrderbook   []LunoOrderBookPriceFeed
 	CurrentSequence int
+	HasSnapshot     bool
+	isActive        bool      // a stream goroutine is running for the pair
+	book            *lunoBook // every resting order of the feed, OrderBook only holds the levels near the best prices
 }
 
 const lunoWebsocketBaseUrl = "wss://ws.luno.com/api/1/stream/"
+const lunoReconnectMinBackoff = 1 * time.Second
+const lunoReconnectMaxBackoff = 30 * time.Second
+const lunoKeepaliveInterval = 20 * time.Second
+const lunoIdleTimeout = 30 * time.Second
 
 var Logger = logger.Get()
 var StateLogger = logger.GetStateLogger()
 var ScrapingLogger = logger.GetScrapingLogger()
 
-func CreateClient(id string, secret string) *LunoExchange {
+func CreateClient(id string, secret string, options httpclient.Options) *LunoExchange {
 	lunoClient := luno.NewClient()
 	lunoClient.SetAuth(id, secret)
+	lunoClient.SetHTTPClient(httpclient.NewClient(domain.Luno.String(), options))
 
 	Logger.Info("Luno client created")
 
@@ -51,6 +66,14 @@ func CreateClient(id string, secret string) *LunoExchange {
 		apiKeyId:         id,
 		apiKeySecret:     secret,
 		states:           make(map[string]*LunoExchangeState),
+		connectionStates: make(chan domain.ConnectionState, 64),
+		maxPriceDiff: func(pair string) decimal.Decimal {
+			return config.GetConfig().Market[pair].MaxPriceDiff
+		},
+		reconnectMinBackoff: lunoReconnectMinBackoff,
+		reconnectMaxBackoff: lunoReconnectMaxBackoff,
+		keepaliveInterval:   lunoKeepaliveInterval,
+		idleTimeout:         lunoIdleTimeout,
 	}
 }
 
@@ -58,40 +81,43 @@ func (lunoExchange *LunoExchange) GetName() string {
 	return domain.Luno.String()
 }
 
-func (lunoExchange *LunoExchange) GetTransferFee(pair string, address string, amount float32) (fee float32, err error) {
-	res, err := lunoExchange.lunoClient.SendFee(context.Background(), &luno.SendFeeRequest{
+func (lunoExchange *LunoExchange) GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (fee decimal.Decimal, err error) {
+	parsedPair, err := domain.ParsePair(pair)
+	if err != nil {
+		return fee, err
+	}
+
+	res, err := lunoExchange.lunoClient.SendFee(ctx, &luno.SendFeeRequest{
 		Address:  address,
-		Currency: strings.Replace(pair, "MYR", "", -1),
-		Amount:   decimal.NewFromFloat64(float64(amount), 8),
+		Currency: parsedPair.Base,
+		Amount:   amount,
 	})
 	if err != nil {
 		Logger.Error("Failed to get Luno transfer fee: " + err.Error())
-		return 0, err
+		return fee, err
 	}
 
-	Logger.Info("Luno transfer fee for currency " + res.Currency + " pair: " + pair + " amount: " + fmt.Sprintf("%v", amount) + " is: " + fmt.Sprintf("%v", res.Fee.Float64()))
-	return float32(res.Fee.Float64()), nil
+	Logger.Info("Luno transfer fee for currency " + res.Currency + " pair: " + pair + " amount: " + amount.String() + " is: " + res.Fee.String())
+	return res.Fee, nil
 }
 
-func (lunoExchange *LunoExchange) GetWithdrawMin(pair string) (min float32, err error) {
-	return 0, nil
+func (lunoExchange *LunoExchange) GetWithdrawMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
+	return decimal.Zero(), nil
 }
 
-func (lunoExchange *LunoExchange) GetDepositMin(pair string) (min float32, err error) {
-	return 0, nil
+func (lunoExchange *LunoExchange) GetDepositMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
+	return decimal.Zero(), nil
 }
 
-func (lunoExchange *LunoExchange) GetDepositAddress(pair string) (address string, err error) {
+func (lunoExchange *LunoExchange) GetDepositAddress(ctx context.Context, pair string) (address string, err error) {
 	Config := config.GetConfig()
 	depositAddress := Config.Exchange[domain.Luno.String()].Crypto[pair].Address
 
 	return depositAddress, nil
 }
 
-func (lunoExchange *LunoExchange) GetCurrentOrderBook(pair string) (output domain.OrderBook, err error) {
+func (lunoExchange *LunoExchange) GetCurrentOrderBook(ctx context.Context, pair string) (output domain.OrderBook, err error) {
 	req := luno.GetOrderBookRequest{Pair: pair}
-	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(10*time.Second))
-	defer cancel()
 
 	Logger.Info("Getting Luno order book for pair: " + req.Pair)
 
@@ -105,7 +131,11 @@ func (lunoExchange *LunoExchange) GetCurrentOrderBook(pair string) (output domai
 	if err != nil {
 		Logger.Error("Failed to marshal Luno response: " + err.Error())
 	} else {
-		ScrapingLogger.Info(string(jsonBytes))
+		ScrapingLogger.Info(string(jsonBytes), zap.String("exchange", lunoExchange.GetName()), zap.String("pair", pair), zap.String("endpoint", "orderbook"))
+	}
+
+	if len(res.Asks) == 0 || len(res.Bids) == 0 {
+		return output, fmt.Errorf("empty Luno order book for pair %s", pair)
 	}
 
 	Logger.Info(fmt.Sprintf("[%s] Ask: [{%f %f}] [{%f %f}] => Bid: [{%f %f}] [{%f %f}]", pair,
@@ -119,89 +149,272 @@ func (lunoExchange *LunoExchange) GetCurrentOrderBook(pair string) (output domai
 		res.Bids[len(res.Bids)-1].Volume.Float64(),
 	))
 
+	output = toOrderBook(pair, res)
+	output.FetchedAt = time.Now()
+	return output, nil
+}
+
+// ParseOrderBookResponse converts a raw Luno order book response, as recorded in the scraping log,
+// into an order book.
+func ParseOrderBookResponse(pair string, respBody []byte) (output domain.OrderBook, err error) {
+	var res luno.GetOrderBookResponse
+	err = json.Unmarshal(respBody, &res)
+	if err != nil {
+		return output, err
+	}
+	if len(res.Asks) == 0 || len(res.Bids) == 0 {
+		return output, fmt.Errorf("empty Luno order book for pair %s", pair)
+	}
+
+	return toOrderBook(pair, &res), nil
+}
+
+func toOrderBook(pair string, res *luno.GetOrderBookResponse) (output domain.OrderBook) {
 	output.Pair = pair
 	output.Exchange = domain.Luno
-	output.Asks = make([]domain.PriceLevel, 0)
-	output.Bids = make([]domain.PriceLevel, 0)
+	output.ExchangeTimestamp = fromUnixMilli(res.Timestamp)
+	output.Asks = make([]domain.PriceLevel, 0, len(res.Asks))
+	output.Bids = make([]domain.PriceLevel, 0, len(res.Bids))
 
 	for _, ask := range res.Asks {
 		output.Asks = append(output.Asks, domain.PriceLevel{
-			Price:  float32(ask.Price.Float64()),
-			Volume: float32(ask.Volume.Float64()),
+			Price:  ask.Price,
+			Volume: ask.Volume,
 		})
 	}
 	for _, bid := range res.Bids {
 		output.Bids = append(output.Bids, domain.PriceLevel{
-			Price:  float32(bid.Price.Float64()),
-			Volume: float32(bid.Volume.Float64()),
+			Price:  bid.Price,
+			Volume: bid.Volume,
 		})
 	}
 
-	return output, nil
+	return output
 }
 
+// SubscribeSocket connects to the Luno order book stream for the pair and keeps the book in the
+// exchange state, publishing every change to ExchangeState.Updates. The first connection is made
+// synchronously so dial errors are returned to the caller; afterwards the stream is supervised
+// until ctx is cancelled: it reconnects with exponential backoff when the connection drops or stays
+// idle, and resyncs from a fresh snapshot when an update is missed. Every change of the connection's
+// state is sent to GetConnectionStates.
 func (lunoExchange *LunoExchange) SubscribeSocket(ctx context.Context, pair string) (err error) {
 	Logger.Info("Subscribing to Luno websocket for pair: " + pair)
 
+	state := lunoExchange.getOrCreateState(pair)
+
+	state.Mutex.Lock()
+	if state.isActive {
+		state.Mutex.Unlock()
+		return nil
+	}
+	state.isActive = true
+	state.Mutex.Unlock()
+
+	lunoExchange.publishConnectionState(pair, domain.Connecting, nil)
+	c, err := lunoExchange.connect(ctx, pair)
+	if err != nil {
+		state.Mutex.Lock()
+		state.isActive = false
+		state.Mutex.Unlock()
+		lunoExchange.publishConnectionState(pair, domain.Disconnected, err)
+		return err
+	}
+	lunoExchange.publishConnectionState(pair, domain.Connected, nil)
+
+	go lunoExchange.superviseSocket(ctx, c, pair)
+
+	return nil
+}
+
+// superviseSocket reads the connection until it fails, then reconnects until ctx is cancelled.
+func (lunoExchange *LunoExchange) superviseSocket(ctx context.Context, c *websocket.Conn, pair string) {
+	state := lunoExchange.getOrCreateState(pair)
+	defer func() {
+		state.Mutex.Lock()
+		state.isActive = false
+		state.Mutex.Unlock()
+	}()
+
+	backoff := lunoExchange.reconnectMinBackoff
+	for {
+		if c != nil {
+			err := lunoExchange.readOrderBookFeed(ctx, c, pair)
+			c.CloseNow()
+			if ctx.Err() != nil {
+				Logger.Info("Received interrupt signal. Closed Luno websocket connection for pair: " + pair)
+				lunoExchange.publishConnectionState(pair, domain.Disconnected, nil)
+				return
+			}
+
+			var sequenceErr *SequenceIncorrectError
+			if errors.As(err, &sequenceErr) {
+				Logger.Error("Luno websocket for pair " + pair + " missed an update, resyncing: " + err.Error())
+				lunoExchange.publishConnectionState(pair, domain.Resyncing, err)
+			} else {
+				Logger.Error("Luno websocket for pair " + pair + " disconnected: " + err.Error())
+				lunoExchange.publishConnectionState(pair, domain.Disconnected, err)
+			}
+			backoff = lunoExchange.reconnectMinBackoff
+		}
+
+		// The next connection starts with a fresh snapshot
+		lunoExchange.resetState(pair)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		var err error
+		lunoExchange.publishConnectionState(pair, domain.Connecting, nil)
+		c, err = lunoExchange.connect(ctx, pair)
+		if err != nil {
+			Logger.Error("Failed to reconnect Luno websocket for pair " + pair + ": " + err.Error())
+			lunoExchange.publishConnectionState(pair, domain.Disconnected, err)
+			backoff = min(backoff*2, lunoExchange.reconnectMaxBackoff)
+			continue
+		}
+		lunoExchange.publishConnectionState(pair, domain.Connected, nil)
+	}
+}
+
+func (lunoExchange *LunoExchange) connect(ctx context.Context, pair string) (*websocket.Conn, error) {
 	c, _, err := websocket.Dial(ctx, lunoExchange.websocketBaseUrl+pair, nil)
-	c.SetReadLimit(-1) //Disable read limit
 	if err != nil {
 		Logger.Error("Failed to dial Luno websocket: " + err.Error())
-		return err
+		return nil, err
 	}
+	c.SetReadLimit(-1) //Disable read limit
 
 	err = lunoExchange.sendAuthenticationMessage(ctx, c)
 	if err != nil {
-		return err
+		c.CloseNow()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// readOrderBookFeed applies the connection's messages to the book until the connection fails, stays
+// idle for idleTimeout or an update is missed. Luno sends an empty keepalive message every few
+// seconds, and the connection is pinged every keepaliveInterval.
+func (lunoExchange *LunoExchange) readOrderBookFeed(ctx context.Context, c *websocket.Conn, pair string) error {
+	keepaliveCtx, stopKeepalive := context.WithCancel(ctx)
+	defer stopKeepalive()
+	go lunoExchange.keepalive(keepaliveCtx, c, pair)
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, lunoExchange.idleTimeout)
+		messageType, message, err := c.Read(readCtx)
+		idle := errors.Is(readCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err != nil {
+			if idle && ctx.Err() == nil {
+				return fmt.Errorf("no message for %v: %w", lunoExchange.idleTimeout, err)
+			}
+			return err
+		}
+		if messageType != websocket.MessageText {
+			Logger.Error("Received unknown message type from Luno websocket: " + strconv.Itoa(int(messageType)))
+			continue
+		}
+		if isKeepalive(message) {
+			lunoExchange.markAlive(pair)
+			continue
+		}
+
+		Logger.Info("Received message from Luno websocket. Message: " + string(message))
+		err = lunoExchange.processOrderBookFeed(ctx, message, pair)
+		if err != nil {
+			var sequenceErr *SequenceIncorrectError
+			if errors.As(err, &sequenceErr) {
+				return err
+			}
+			Logger.Error("Failed to process Luno order book feed: " + err.Error())
+		}
 	}
+}
 
-	// Start goroutine to read messages
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				Logger.Info("Received interrupt signal. Closing Luno websocket connection.")
-				c.Close(websocket.StatusNormalClosure, "")
+// keepalive pings the connection every keepaliveInterval, closing it when a ping goes unanswered so
+// the reader reconnects.
+func (lunoExchange *LunoExchange) keepalive(ctx context.Context, c *websocket.Conn, pair string) {
+	ticker := time.NewTicker(lunoExchange.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, lunoExchange.keepaliveInterval)
+			err := c.Ping(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				Logger.Error("Luno websocket for pair " + pair + " did not answer ping: " + err.Error())
+				c.CloseNow()
 				return
-			default:
-				messageType, message, err := c.Read(ctx)
-				if err != nil {
-					Logger.Error("Failed to read message from Luno websocket: " + err.Error())
-					return
-				}
-				if messageType == websocket.MessageText {
-					Logger.Info("Received message from Luno websocket. Message: " + string(message))
-					err := lunoExchange.processOrderBookFeed(ctx, message, pair)
-					if err != nil {
-						if errors.As(err, &SequenceIncorrectError{}) {
-							Logger.Error("Sequence number mismatch. Expected: " + fmt.Sprintf("%d", err.(*SequenceIncorrectError).ExpectedSequence) + ", got: " + fmt.Sprintf("%d", err.(*SequenceIncorrectError).ActualSequence))
-							lunoExchange.resubscribeSocket(ctx, c, pair)
-						} else {
-							Logger.Error("Failed to process Luno order book feed: " + err.Error())
-						}
-					}
-				} else {
-					Logger.Error("Received unknown message type from Luno websocket: " + strconv.Itoa(int(messageType)))
-				}
+			}
+			if err == nil {
+				lunoExchange.markAlive(pair)
 			}
 		}
-	}()
+	}
+}
 
-	return
+// markAlive republishes the book as still current after a keepalive message or an answered ping. A
+// quiet pair gets no updates, so without it the book would age as if the stream were down.
+func (lunoExchange *LunoExchange) markAlive(pair string) {
+	state := lunoExchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	if !state.HasSnapshot {
+		return
+	}
+	state.OrderBook.AliveAt = time.Now()
+	state.PublishUpdate()
 }
 
-func (lunoExchange *LunoExchange) resubscribeSocket(ctx context.Context, c *websocket.Conn, pair string) (err error) {
-	Logger.Info("Resubscribing to Luno websocket for pair: " + pair)
+func isKeepalive(message []byte) bool {
+	message = bytes.TrimSpace(message)
+	return len(message) == 0 || string(message) == `""`
+}
+
+func (lunoExchange *LunoExchange) resetState(pair string) {
+	state := lunoExchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
 
-	// Close current connection
-	c.Close(websocket.StatusNormalClosure, "")
+	state.HasSnapshot = false
+	state.CurrentSequence = 0
+	state.book = newLunoBook()
+
+	// The book of the dropped connection is no longer current: nothing is served from it until the
+	// next snapshot, and an update of it not yet received is discarded
+	state.OrderBook = &domain.OrderBook{Exchange: domain.Luno, Pair: pair}
+	select {
+	case <-state.Updates:
+	default:
+	}
+}
 
-	// Reset state
-	lunoExchange.states[pair].Mutex.Lock()
-	defer lunoExchange.states[pair].Mutex.Unlock()
-	lunoExchange.states[pair] = nil
+// GetConnectionStates returns the channel receiving every change of state of the order book streams.
+// States are dropped while the channel is full.
+func (lunoExchange *LunoExchange) GetConnectionStates() <-chan domain.ConnectionState {
+	return lunoExchange.connectionStates
+}
 
-	return lunoExchange.SubscribeSocket(ctx, pair)
+func (lunoExchange *LunoExchange) publishConnectionState(pair string, connectionState domain.ConnectionStateEnum, err error) {
+	state := domain.ConnectionState{Exchange: lunoExchange.GetName(), Pair: pair, State: connectionState, At: time.Now()}
+	if err != nil {
+		state.Error = err.Error()
+	}
+	select {
+	case lunoExchange.connectionStates <- state:
+	default:
+	}
 }
 
 func (lunoExchange *LunoExchange) sendAuthenticationMessage(ctx context.Context, c *websocket.Conn) error {
@@ -227,54 +440,45 @@ func (lunoExchange *LunoExchange) sendAuthenticationMessage(ctx context.Context,
 }
 
 func (lunoExchange *LunoExchange) processOrderBookFeed(ctx context.Context, feedString []byte, pair string) error {
-	config := config.GetConfig()
-	maxPriceDiff := config.Market[pair].MaxPriceDiff
+	maxPriceDiff := lunoExchange.maxPriceDiff(pair)
 
-	if lunoExchange.states[pair] == nil {
+	state := lunoExchange.getOrCreateState(pair)
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	if !state.HasSnapshot {
+		// The first message on a connection is the order book snapshot
 		var feedSnapshot *LunoOrderBookFeedSnapshot
 		err := json.Unmarshal(feedString, &feedSnapshot)
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal Luno order book feed snapshot: %v", err)
 		}
-
-		lunoExchange.states[pair] = &LunoExchangeState{
-			ExchangeState: domain.ExchangeState{
-				OrderBook: &domain.OrderBook{Pair: pair},
-				Updates:   make(chan *domain.OrderBook),
-				Stop:      make(chan bool),
-				Mutex:     sync.Mutex{},
-			},
-			AsksOrderbook:   make([]LunoOrderBookPriceFeed, 0),
-			BidsOrderbook:   make([]LunoOrderBookPriceFeed, 0),
-			CurrentSequence: feedSnapshot.Sequence,
+		if feedSnapshot == nil {
+			return nil
 		}
 
-		if feedSnapshot != nil {
-			err := lunoExchange.processFeedSnapshot(feedSnapshot, pair, maxPriceDiff)
-			StateLogger.Info("Current internal state for pair: " + pair + " is: " + fmt.Sprintf("%v", lunoExchange.states[pair]))
-			if err != nil {
-				return err
-			}
-		}
+		state.CurrentSequence = feedSnapshot.Sequence
+		state.HasSnapshot = true
+
+		err = lunoExchange.processFeedSnapshot(feedSnapshot, pair, maxPriceDiff)
+		StateLogger.Info("Current internal state for pair: " + pair + " is: " + fmt.Sprintf("%v", state))
+		return err
 	}
 
 	var feedMessage *LunoOrderBookFeedMessage
-	err2 := json.Unmarshal(feedString, &feedMessage)
-	if err2 != nil {
-		return fmt.Errorf("failed to unmarshal Luno order book feed: %v", err2)
+	err := json.Unmarshal(feedString, &feedMessage)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal Luno order book feed: %v", err)
 	}
 
 	if feedMessage != nil {
-		lunoExchange.states[pair].Mutex.Lock()
-		defer lunoExchange.states[pair].Mutex.Unlock()
-
 		err := lunoExchange.ProcessSequenceNumber(pair, feedMessage.Sequence)
 		if err != nil {
 			return err
 		}
 
 		err = lunoExchange.processFeedUpdate(feedMessage, pair, maxPriceDiff)
-		StateLogger.Info("Current internal state for pair: " + pair + " is: " + fmt.Sprintf("%v", lunoExchange.states[pair]))
+		StateLogger.Info("Current internal state for pair: " + pair + " is: " + fmt.Sprintf("%v", state))
 		if err != nil {
 			return err
 		}
@@ -283,185 +487,116 @@ func (lunoExchange *LunoExchange) processOrderBookFeed(ctx context.Context, feed
 	return nil
 }
 
-func (lunoExchange *LunoExchange) processFeedSnapshot(feedSnapshot *LunoOrderBookFeedSnapshot, pair string, maxPriceDiff float32) error {
-	// Process asks
-	asks := make([]domain.PriceLevel, 0)
-	StateLogger.Info("Feed snapshot for pair: " + pair + " is: " + fmt.Sprintf("%v", feedSnapshot))
-	lowestAskPrice := feedSnapshot.Asks[0].Price // First ask is lowest
-	for _, ask := range feedSnapshot.Asks {
-		// Only include asks within maxPriceDiff of lowest ask
-		priceDiff := (ask.Price - lowestAskPrice) / lowestAskPrice
-		if priceDiff <= maxPriceDiff {
-			asks = append(asks, domain.PriceLevel{
-				Price:  ask.Price,
-				Volume: ask.Volume,
-			})
-			lunoExchange.states[pair].AsksOrderbook = append(lunoExchange.states[pair].AsksOrderbook, ask)
-		}
+// GetOrderBookUpdates returns the channel on which the streamed order book of the pair is published.
+// SubscribeSocket must be called for the pair first.
+func (lunoExchange *LunoExchange) GetOrderBookUpdates(pair string) (updates <-chan *domain.OrderBook, err error) {
+	state := lunoExchange.getState(pair)
+	if state == nil {
+		return nil, fmt.Errorf("pair %s is not subscribed on %s", pair, lunoExchange.GetName())
 	}
 
-	// Process bids
-	bids := make([]domain.PriceLevel, 0)
-	highestBidPrice := feedSnapshot.Bids[0].Price // First bid is highest
-	for _, bid := range feedSnapshot.Bids {
-		// Only include bids within maxPriceDiff of highest bid
-		priceDiff := (highestBidPrice - bid.Price) / highestBidPrice
-		if priceDiff <= maxPriceDiff {
-			bids = append(bids, domain.PriceLevel{
-				Price:  bid.Price,
-				Volume: bid.Volume,
-			})
-			lunoExchange.states[pair].BidsOrderbook = append(lunoExchange.states[pair].BidsOrderbook, bid)
+	return state.Updates, nil
+}
+
+func (lunoExchange *LunoExchange) getState(pair string) *LunoExchangeState {
+	lunoExchange.statesMutex.Lock()
+	defer lunoExchange.statesMutex.Unlock()
+
+	return lunoExchange.states[pair]
+}
+
+func (lunoExchange *LunoExchange) getOrCreateState(pair string) *LunoExchangeState {
+	lunoExchange.statesMutex.Lock()
+	defer lunoExchange.statesMutex.Unlock()
+
+	if lunoExchange.states[pair] == nil {
+		lunoExchange.states[pair] = &LunoExchangeState{
+			ExchangeState: domain.ExchangeState{
+				OrderBook: &domain.OrderBook{Exchange: domain.Luno, Pair: pair},
+				Updates:   make(chan *domain.OrderBook, 1),
+				Stop:      make(chan bool),
+			},
+			book: newLunoBook(),
 		}
 	}
 
-	lunoExchange.states[pair].OrderBook = &domain.OrderBook{
-		Pair: pair,
-		Asks: asks,
-		Bids: bids,
-	}
+	return lunoExchange.states[pair]
+}
 
-	// Send update to subscribers
-	select {
-	case lunoExchange.states[pair].Updates <- lunoExchange.states[pair].OrderBook:
-	default:
+// processFeedSnapshot replaces the book with the snapshot's orders.
+func (lunoExchange *LunoExchange) processFeedSnapshot(feedSnapshot *LunoOrderBookFeedSnapshot, pair string, maxPriceDiff decimal.Decimal) error {
+	state := lunoExchange.getState(pair)
+	StateLogger.Info("Feed snapshot for pair: " + pair + " is: " + fmt.Sprintf("%v", feedSnapshot))
+
+	state.book = newLunoBook()
+	for _, ask := range feedSnapshot.Asks {
+		state.book.addOrder(ask.Id, true, ask.Price, ask.Volume)
+	}
+	for _, bid := range feedSnapshot.Bids {
+		state.book.addOrder(bid.Id, false, bid.Price, bid.Volume)
 	}
+
+	state.publishBook(pair, maxPriceDiff, int64(feedSnapshot.Timestamp))
 	return nil
 }
 
-func (lunoExchange *LunoExchange) processFeedUpdate(feedMessage *LunoOrderBookFeedMessage, pair string, maxPriceDiff float32) error {
-	// Process trade events
-	if feedMessage.TradeUpdates != nil {
-		for _, trade := range feedMessage.TradeUpdates {
-			// Find and update the maker order
-			found := false
-
-			// Check asks first
-			for i, ask := range lunoExchange.states[pair].AsksOrderbook {
-				if ask.Id == trade.MakerOrderId {
-					newVolume := ask.Volume - trade.Base
-					if newVolume <= 0 {
-						// Remove from both AsksOrderbook and OrderBook.Asks
-						lunoExchange.states[pair].AsksOrderbook = append(
-							lunoExchange.states[pair].AsksOrderbook[:i],
-							lunoExchange.states[pair].AsksOrderbook[i+1:]...)
-						lunoExchange.states[pair].OrderBook.Asks = append(
-							lunoExchange.states[pair].OrderBook.Asks[:i],
-							lunoExchange.states[pair].OrderBook.Asks[i+1:]...)
-					} else {
-						// Update volume in both places
-						lunoExchange.states[pair].AsksOrderbook[i].Volume = newVolume
-						lunoExchange.states[pair].OrderBook.Asks[i].Volume = newVolume
-					}
-					found = true
-					break
-				}
-			}
+// processFeedUpdate applies the trades, create and delete of a feed message to the book.
+func (lunoExchange *LunoExchange) processFeedUpdate(feedMessage *LunoOrderBookFeedMessage, pair string, maxPriceDiff decimal.Decimal) error {
+	state := lunoExchange.getState(pair)
 
-			// If not found in asks, check bids
-			if !found {
-				for i, bid := range lunoExchange.states[pair].BidsOrderbook {
-					if bid.Id == trade.MakerOrderId {
-						newVolume := bid.Volume - trade.Base
-						if newVolume <= 0 {
-							lunoExchange.states[pair].BidsOrderbook = append(
-								lunoExchange.states[pair].BidsOrderbook[:i],
-								lunoExchange.states[pair].BidsOrderbook[i+1:]...)
-							lunoExchange.states[pair].OrderBook.Bids = append(
-								lunoExchange.states[pair].OrderBook.Bids[:i],
-								lunoExchange.states[pair].OrderBook.Bids[i+1:]...)
-						} else {
-							lunoExchange.states[pair].BidsOrderbook[i].Volume = newVolume
-							lunoExchange.states[pair].OrderBook.Bids[i].Volume = newVolume
-						}
-						break
-					}
-				}
-			}
-		}
+	// Trades fill the maker order
+	for _, trade := range feedMessage.TradeUpdates {
+		state.book.trade(trade.MakerOrderId, trade.Base)
 	}
 
-	// Process create events
 	if feedMessage.CreateUpdate != nil {
-		newOrder := LunoOrderBookPriceFeed{
-			Id:     feedMessage.CreateUpdate.OrderId,
-			Price:  feedMessage.CreateUpdate.Price,
-			Volume: feedMessage.CreateUpdate.Volume,
-		}
-
-		newPriceLevel := domain.PriceLevel{
-			Price:  feedMessage.CreateUpdate.Price,
-			Volume: feedMessage.CreateUpdate.Volume,
-		}
-
-		if feedMessage.CreateUpdate.Type == "ASK" {
-			// Check if the new order is within maxPriceDiff
-			if len(lunoExchange.states[pair].AsksOrderbook) > 0 {
-				lowestAskPrice := lunoExchange.states[pair].AsksOrderbook[0].Price
-				priceDiff := (newOrder.Price - lowestAskPrice) / lowestAskPrice
-				if priceDiff <= maxPriceDiff {
-					lunoExchange.states[pair].AsksOrderbook = append(
-						lunoExchange.states[pair].AsksOrderbook, newOrder)
-					lunoExchange.states[pair].OrderBook.Asks = append(
-						lunoExchange.states[pair].OrderBook.Asks, newPriceLevel)
-				}
-			}
-		} else {
-			// Check if the new order is within maxPriceDiff
-			if len(lunoExchange.states[pair].BidsOrderbook) > 0 {
-				highestBidPrice := lunoExchange.states[pair].BidsOrderbook[0].Price
-				priceDiff := (highestBidPrice - newOrder.Price) / highestBidPrice
-				if priceDiff <= maxPriceDiff {
-					lunoExchange.states[pair].BidsOrderbook = append(
-						lunoExchange.states[pair].BidsOrderbook, newOrder)
-					lunoExchange.states[pair].OrderBook.Bids = append(
-						lunoExchange.states[pair].OrderBook.Bids, newPriceLevel)
-				}
-			}
-		}
+		state.book.addOrder(feedMessage.CreateUpdate.OrderId, feedMessage.CreateUpdate.Type == "ASK", feedMessage.CreateUpdate.Price, feedMessage.CreateUpdate.Volume)
 	}
 
-	// Process delete events
 	if feedMessage.DeleteUpdate != nil {
-		// Try to find and remove from asks
-		for i, ask := range lunoExchange.states[pair].AsksOrderbook {
-			if ask.Id == feedMessage.DeleteUpdate.OrderId {
-				lunoExchange.states[pair].AsksOrderbook = append(
-					lunoExchange.states[pair].AsksOrderbook[:i],
-					lunoExchange.states[pair].AsksOrderbook[i+1:]...)
-				lunoExchange.states[pair].OrderBook.Asks = append(
-					lunoExchange.states[pair].OrderBook.Asks[:i],
-					lunoExchange.states[pair].OrderBook.Asks[i+1:]...)
-				break
-			}
-		}
-		// Try to find and remove from bids
-		for i, bid := range lunoExchange.states[pair].BidsOrderbook {
-			if bid.Id == feedMessage.DeleteUpdate.OrderId {
-				lunoExchange.states[pair].BidsOrderbook = append(
-					lunoExchange.states[pair].BidsOrderbook[:i],
-					lunoExchange.states[pair].BidsOrderbook[i+1:]...)
-				lunoExchange.states[pair].OrderBook.Bids = append(
-					lunoExchange.states[pair].OrderBook.Bids[:i],
-					lunoExchange.states[pair].OrderBook.Bids[i+1:]...)
-				break
-			}
-		}
+		state.book.removeOrder(feedMessage.DeleteUpdate.OrderId)
 	}
 
-	// Send update to subscribers
-	select {
-	case lunoExchange.states[pair].Updates <- lunoExchange.states[pair].OrderBook:
-	default:
+	state.publishBook(pair, maxPriceDiff, int64(feedMessage.Timestamp))
+	return nil
+}
+
+// publishBook rebuilds the order book from the levels within maxPriceDiff of the best prices and
+// sends it to subscribers. Callers must hold Mutex.
+func (state *LunoExchangeState) publishBook(pair string, maxPriceDiff decimal.Decimal, timestamp int64) {
+	now := time.Now()
+	state.OrderBook = &domain.OrderBook{
+		Exchange:          domain.Luno,
+		Pair:              pair,
+		Asks:              state.book.asks.depth(maxPriceDiff),
+		Bids:              state.book.bids.depth(maxPriceDiff),
+		FetchedAt:         now,
+		ExchangeTimestamp: fromUnixMilli(timestamp),
+		AliveAt:           now,
 	}
 
-	return nil
+	state.PublishUpdate()
+}
+
+// fromUnixMilli converts a Luno timestamp in milliseconds, zero when it is missing.
+func fromUnixMilli(milliseconds int64) time.Time {
+	if milliseconds <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(milliseconds)
+}
+
+// withinPriceDiff reports whether a price priceDiff worse than the best price is within maxPriceDiff,
+// a fraction of the best price.
+func withinPriceDiff(bestPrice decimal.Decimal, priceDiff decimal.Decimal, maxPriceDiff decimal.Decimal) bool {
+	return priceDiff.Cmp(bestPrice.Mul(maxPriceDiff)) <= 0
 }
 
 func (lunoExchange *LunoExchange) ProcessSequenceNumber(pair string, sequence int) error {
-	previousSequence := lunoExchange.states[pair].CurrentSequence
+	state := lunoExchange.getState(pair)
+	previousSequence := state.CurrentSequence
 	if sequence == previousSequence+1 {
-		lunoExchange.states[pair].CurrentSequence = sequence
+		state.CurrentSequence = sequence
 	} else {
 		return &SequenceIncorrectError{
 			ExpectedSequence: previousSequence + 1,
@@ -0,0 +1,298 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/luno/luno-go/decimal"
+)
+
+// testSession is what the test server sends on one connection. The connection is dropped once the
+// messages are sent, unless hold is set or it is the last session.
+type testSession struct {
+	messages []string
+	hold     bool
+}
+
+func newTestStreamServer(t *testing.T, sessions ...testSession) (*LunoExchange, *int32) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to accept websocket: %v", err)
+			return
+		}
+		defer c.CloseNow()
+
+		session := int(atomic.AddInt32(&connections, 1)) - 1
+		if r.URL.Path != "/SOLMYR" {
+			t.Errorf("unexpected stream path %s", r.URL.Path)
+		}
+
+		_, message, err := c.Read(r.Context())
+		if err != nil {
+			return
+		}
+		var auth LunoWebsocketAuthenticationRequest
+		if err := json.Unmarshal(message, &auth); err != nil || auth.ApiKeyId != "key" || auth.ApiKeySecret != "secret" {
+			t.Errorf("unexpected authentication message %s", string(message))
+		}
+
+		if session >= len(sessions) {
+			// No more scripted sessions: keep the connection open until the client leaves
+			c.Read(r.Context())
+			return
+		}
+		for _, message := range sessions[session].messages {
+			if err := c.Write(r.Context(), websocket.MessageText, []byte(message)); err != nil {
+				return
+			}
+		}
+		if sessions[session].hold || session == len(sessions)-1 {
+			c.Read(r.Context())
+		}
+		// Otherwise drop the connection to force a reconnect
+	}))
+	t.Cleanup(server.Close)
+
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	exchange.websocketBaseUrl = "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	exchange.maxPriceDiff = func(pair string) decimal.Decimal { return dec("0.1") }
+	exchange.reconnectMinBackoff = 10 * time.Millisecond
+	exchange.reconnectMaxBackoff = 50 * time.Millisecond
+	exchange.keepaliveInterval = time.Hour
+	exchange.idleTimeout = 5 * time.Second
+	return exchange, &connections
+}
+
+func snapshot(sequence int, ask string, bid string) string {
+	return `{"sequence":"` + strconv.Itoa(sequence) + `","asks":[{"id":"a` + ask + `","price":"` + ask + `","volume":"1"}],` +
+		`"bids":[{"id":"b` + bid + `","price":"` + bid + `","volume":"1"}],"status":"ACTIVE","timestamp":1730448000000}`
+}
+
+func createAsk(sequence int, id string, price string) string {
+	return `{"sequence":"` + strconv.Itoa(sequence) + `","create_update":{"order_id":"` + id + `","type":"ASK","price":"` + price + `","volume":"2"},"timestamp":1730448001000}`
+}
+
+func waitForBook(t *testing.T, updates <-chan *domain.OrderBook, check func(*domain.OrderBook) bool) *domain.OrderBook {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case orderBook := <-updates:
+			if check(orderBook) {
+				return orderBook
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for order book update")
+		}
+	}
+}
+
+func waitForState(t *testing.T, states <-chan domain.ConnectionState, want domain.ConnectionStateEnum) domain.ConnectionState {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-states:
+			if state.State == want {
+				return state
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for connection state %v", want)
+		}
+	}
+}
+
+func bestAsk(price string) func(*domain.OrderBook) bool {
+	return func(orderBook *domain.OrderBook) bool {
+		return len(orderBook.Asks) > 0 && orderBook.Asks[0].Price.Cmp(dec(price)) == 0
+	}
+}
+
+func TestSubscribeSocketAppliesSnapshotAndUpdates(t *testing.T) {
+	exchange, _ := newTestStreamServer(t, testSession{messages: []string{
+		snapshot(1, "1040", "1030"),
+		`""`,
+		createAsk(2, "a2", "1038"),
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForState(t, exchange.GetConnectionStates(), domain.Connected)
+
+	updates, err := exchange.GetOrderBookUpdates("SOLMYR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	orderBook := waitForBook(t, updates, bestAsk("1038"))
+
+	assertLevels(t, "asks", orderBook.Asks, "1038", "2", "1040", "1")
+	assertLevels(t, "bids", orderBook.Bids, "1030", "1")
+	if !orderBook.ExchangeTimestamp.Equal(time.UnixMilli(1730448001000)) || orderBook.FetchedAt.IsZero() {
+		t.Errorf("expected the update's timestamps; got %v and %v", orderBook.ExchangeTimestamp, orderBook.FetchedAt)
+	}
+
+	// Subscribing again keeps the running stream
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSubscribeSocketKeepaliveRefreshesQuietBook(t *testing.T) {
+	exchange, _ := newTestStreamServer(t, testSession{messages: []string{snapshot(1, "1040", "1030"), `""`}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The keepalive republishes the unchanged book as current
+	updates, _ := exchange.GetOrderBookUpdates("SOLMYR")
+	orderBook := waitForBook(t, updates, func(orderBook *domain.OrderBook) bool {
+		return orderBook.AliveAt.After(orderBook.FetchedAt)
+	})
+	assertLevels(t, "asks", orderBook.Asks, "1040", "1")
+	if !orderBook.ExchangeTimestamp.Equal(time.UnixMilli(1730448000000)) {
+		t.Errorf("expected the snapshot's timestamp kept; got %v", orderBook.ExchangeTimestamp)
+	}
+}
+
+func TestSubscribeSocketResyncsOnSequenceGap(t *testing.T) {
+	exchange, connections := newTestStreamServer(t,
+		testSession{messages: []string{snapshot(1, "1040", "1030"), createAsk(3, "a2", "1038")}, hold: true},
+		testSession{messages: []string{snapshot(7, "1050", "1045"), createAsk(8, "a3", "1049")}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state := waitForState(t, exchange.GetConnectionStates(), domain.Resyncing)
+	if !strings.Contains(state.Error, "Expected: 2, got: 3") {
+		t.Errorf("expected the sequence gap as the resync reason; got %q", state.Error)
+	}
+
+	updates, _ := exchange.GetOrderBookUpdates("SOLMYR")
+	orderBook := waitForBook(t, updates, bestAsk("1049"))
+
+	// The book is rebuilt from the new snapshot, nothing from the first connection is left
+	assertLevels(t, "asks", orderBook.Asks, "1049", "2", "1050", "1")
+	assertLevels(t, "bids", orderBook.Bids, "1045", "1")
+	if n := atomic.LoadInt32(connections); n != 2 {
+		t.Errorf("expected 2 connections; got %d", n)
+	}
+}
+
+func TestSubscribeSocketReconnectsAfterDisconnect(t *testing.T) {
+	exchange, _ := newTestStreamServer(t,
+		testSession{messages: []string{snapshot(1, "1040", "1030")}},
+		testSession{messages: []string{snapshot(1, "1060", "1055")}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	states := exchange.GetConnectionStates()
+	waitForState(t, states, domain.Connected)
+	if state := waitForState(t, states, domain.Disconnected); state.Error == "" || state.Exchange != "Luno" || state.Pair != "SOLMYR" {
+		t.Errorf("expected the disconnect with its error; got %+v", state)
+	}
+	waitForState(t, states, domain.Connected)
+
+	updates, _ := exchange.GetOrderBookUpdates("SOLMYR")
+	waitForBook(t, updates, bestAsk("1060"))
+
+	// Cancelling stops the stream and reports it disconnected without an error
+	cancel()
+	if state := waitForState(t, states, domain.Disconnected); state.Error != "" {
+		t.Errorf("expected a clean disconnect; got %+v", state)
+	}
+}
+
+func TestResetStateDropsTheBook(t *testing.T) {
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	exchange.maxPriceDiff = func(pair string) decimal.Decimal { return dec("0.1") }
+	if err := exchange.processOrderBookFeed(context.Background(), []byte(snapshot(1, "1040", "1030")), "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exchange.resetState("SOLMYR")
+
+	state := exchange.getState("SOLMYR")
+	if len(state.OrderBook.Asks) != 0 || len(state.OrderBook.Bids) != 0 {
+		t.Errorf("expected the book of the dropped connection cleared; got %+v", state.OrderBook)
+	}
+	if _, _, err := exchange.GetLowestAskPrice("SOLMYR"); err == nil {
+		t.Error("expected no ask served until the next snapshot")
+	}
+	select {
+	case orderBook := <-state.Updates:
+		t.Errorf("expected the pending update discarded; got %+v", orderBook)
+	default:
+	}
+}
+
+func TestSubscribeSocketReconnectsWhenIdle(t *testing.T) {
+	exchange, connections := newTestStreamServer(t,
+		testSession{messages: []string{snapshot(1, "1040", "1030")}, hold: true},
+		testSession{messages: []string{snapshot(1, "1070", "1065")}},
+	)
+	exchange.idleTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := exchange.SubscribeSocket(ctx, "SOLMYR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if state := waitForState(t, exchange.GetConnectionStates(), domain.Disconnected); !strings.Contains(state.Error, "no message for") {
+		t.Errorf("expected the idle timeout as the disconnect reason; got %q", state.Error)
+	}
+	updates, _ := exchange.GetOrderBookUpdates("SOLMYR")
+	waitForBook(t, updates, bestAsk("1070"))
+	if n := atomic.LoadInt32(connections); n < 2 {
+		t.Errorf("expected a reconnect; got %d connections", n)
+	}
+}
+
+func TestSubscribeSocketReturnsDialError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	exchange := CreateClient("key", "secret", httpclient.DefaultOptions)
+	exchange.websocketBaseUrl = "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+
+	if err := exchange.SubscribeSocket(context.Background(), "SOLMYR"); err == nil {
+		t.Fatal("expected the dial error")
+	}
+	if state := waitForState(t, exchange.GetConnectionStates(), domain.Disconnected); state.Error == "" {
+		t.Errorf("expected the dial error reported; got %+v", state)
+	}
+}
@@ -1,6 +1,10 @@
 package luno
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/luno/luno-go/decimal"
+)
 
 type SequenceIncorrectError struct {
 	ExpectedSequence int
@@ -25,9 +29,9 @@ type LunoOrderBookFeedSnapshot struct {
 }
 
 type LunoOrderBookPriceFeed struct {
-	Id     string  `json:"id"`
-	Price  float32 `json:"price,string"`
-	Volume float32 `json:"volume,string"`
+	Id     string          `json:"id"`
+	Price  decimal.Decimal `json:"price"`
+	Volume decimal.Decimal `json:"volume"`
 }
 
 type LunoOrderBookFeedMessage struct {
@@ -40,18 +44,18 @@ type LunoOrderBookFeedMessage struct {
 }
 
 type LunoOrderBookFeedTradeUpdate struct {
-	Sequence     int     `json:"sequence"`
-	Base         float32 `json:"base,string"`
-	Counter      float32 `json:"counter,string"`
-	MakerOrderId string  `json:"maker_order_id"`
-	TakerOrderId string  `json:"taker_order_id"`
+	Sequence     int             `json:"sequence"`
+	Base         decimal.Decimal `json:"base"`
+	Counter      decimal.Decimal `json:"counter"`
+	MakerOrderId string          `json:"maker_order_id"`
+	TakerOrderId string          `json:"taker_order_id"`
 }
 
 type LunoOrderBookFeedCreateUpdate struct {
-	OrderId string  `json:"order_id"`
-	Type    string  `json:"type"`
-	Price   float32 `json:"price,string"`
-	Volume  float32 `json:"volume,string"`
+	OrderId string          `json:"order_id"`
+	Type    string          `json:"type"`
+	Price   decimal.Decimal `json:"price"`
+	Volume  decimal.Decimal `json:"volume"`
 }
 
 type LunoOrderBookFeedDeleteUpdate struct {
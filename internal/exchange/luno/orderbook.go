@@ -1,111 +1,106 @@
 package luno
 
-import "fmt"
+import (
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
 
-func (lunoExchange *LunoExchange) GetLowestAskPrice(pair string) (price float32, size float32, err error) {
-	if state, ok := lunoExchange.states[pair]; ok {
+	"github.com/luno/luno-go/decimal"
+)
+
+func (lunoExchange *LunoExchange) GetLowestAskPrice(pair string) (price decimal.Decimal, size decimal.Decimal, err error) {
+	if state := lunoExchange.getState(pair); state != nil {
 		state.Mutex.Lock()
 		defer state.Mutex.Unlock()
 
-		if state.OrderBook != nil && len(state.OrderBook.Asks) > 0 {
-			// Return lowest ask price (first ask in sorted order)
-			lowestAsk := state.OrderBook.Asks[0]
+		if lowestAsk, ok := state.book.bestAsk(); ok {
 			return lowestAsk.Price, lowestAsk.Volume, nil
 		}
-		return 0, 0, fmt.Errorf("no asks available in order book for pair %s", pair)
+		return price, size, fmt.Errorf("no asks available in order book for pair %s", pair)
 	}
-	return 0, 0, fmt.Errorf("no state found for pair %s", pair)
+	return price, size, fmt.Errorf("no state found for pair %s", pair)
 }
 
-func (lunoExchange *LunoExchange) GetLowestAskPriceByVolume(pair string, volume float32, exactMatch bool) (price float32, totalVolume float32, err error) {
-	if state, ok := lunoExchange.states[pair]; ok {
+func (lunoExchange *LunoExchange) GetLowestAskPriceByVolume(pair string, volume decimal.Decimal, exactMatch bool) (price decimal.Decimal, totalVolume decimal.Decimal, err error) {
+	if state := lunoExchange.getState(pair); state != nil {
 		state.Mutex.Lock()
 		defer state.Mutex.Unlock()
 
 		if state.OrderBook != nil && len(state.OrderBook.Asks) > 0 {
 			// Accumulate volume until we reach target
-			var accumulatedVolume float32
-			var weightedPrice float32
+			accumulatedVolume := decimal.Zero()
+			weightedPrice := decimal.Zero()
 
 			for _, ask := range state.OrderBook.Asks {
-				remaining := volume - accumulatedVolume
-				if remaining <= 0 {
+				remaining := volume.Sub(accumulatedVolume)
+				if remaining.Sign() <= 0 {
 					break
 				}
 
-				volumeToAdd := ask.Volume
-				if volumeToAdd > remaining {
-					volumeToAdd = remaining
-				}
+				volumeToAdd := domain.MinDecimal(ask.Volume, remaining)
 
-				weightedPrice += ask.Price * volumeToAdd
-				accumulatedVolume += volumeToAdd
+				weightedPrice = weightedPrice.Add(ask.Price.Mul(volumeToAdd))
+				accumulatedVolume = accumulatedVolume.Add(volumeToAdd)
 			}
 
-			if exactMatch && accumulatedVolume < volume {
-				return 0, 0, fmt.Errorf("insufficient volume in order book for pair %s", pair)
+			if exactMatch && accumulatedVolume.Cmp(volume) < 0 {
+				return price, totalVolume, fmt.Errorf("insufficient volume in order book for pair %s", pair)
 			}
 
-			if accumulatedVolume > 0 {
-				return weightedPrice / accumulatedVolume, accumulatedVolume, nil
+			if accumulatedVolume.Sign() > 0 {
+				return domain.Quo(weightedPrice, accumulatedVolume, domain.DefaultScale), accumulatedVolume, nil
 			}
-			return 0, 0, fmt.Errorf("no volume available in order book for pair %s", pair)
+			return price, totalVolume, fmt.Errorf("no volume available in order book for pair %s", pair)
 		}
-		return 0, 0, fmt.Errorf("no asks available in order book for pair %s", pair)
+		return price, totalVolume, fmt.Errorf("no asks available in order book for pair %s", pair)
 	}
-	return 0, 0, fmt.Errorf("no state found for pair %s", pair)
+	return price, totalVolume, fmt.Errorf("no state found for pair %s", pair)
 }
 
-func (lunoExchange *LunoExchange) GetHighestBidPrice(pair string) (price float32, size float32, err error) {
-	if state, ok := lunoExchange.states[pair]; ok {
+func (lunoExchange *LunoExchange) GetHighestBidPrice(pair string) (price decimal.Decimal, size decimal.Decimal, err error) {
+	if state := lunoExchange.getState(pair); state != nil {
 		state.Mutex.Lock()
 		defer state.Mutex.Unlock()
 
-		if state.OrderBook != nil && len(state.OrderBook.Bids) > 0 {
-			// Return highest bid price (first bid in sorted order)
-			highestBid := state.OrderBook.Bids[0]
+		if highestBid, ok := state.book.bestBid(); ok {
 			return highestBid.Price, highestBid.Volume, nil
 		}
-		return 0, 0, fmt.Errorf("no bids available in order book for pair %s", pair)
+		return price, size, fmt.Errorf("no bids available in order book for pair %s", pair)
 	}
-	return 0, 0, fmt.Errorf("no state found for pair %s", pair)
+	return price, size, fmt.Errorf("no state found for pair %s", pair)
 }
 
-func (lunoExchange *LunoExchange) GetHighestBidPriceByVolume(pair string, volume float32, exactMatch bool) (price float32, totalVolume float32, err error) {
-	if state, ok := lunoExchange.states[pair]; ok {
+func (lunoExchange *LunoExchange) GetHighestBidPriceByVolume(pair string, volume decimal.Decimal, exactMatch bool) (price decimal.Decimal, totalVolume decimal.Decimal, err error) {
+	if state := lunoExchange.getState(pair); state != nil {
 		state.Mutex.Lock()
 		defer state.Mutex.Unlock()
 
 		if state.OrderBook != nil && len(state.OrderBook.Bids) > 0 {
 			// Accumulate volume until we reach target
-			var accumulatedVolume float32
-			var weightedPrice float32
+			accumulatedVolume := decimal.Zero()
+			weightedPrice := decimal.Zero()
 
 			for _, bid := range state.OrderBook.Bids {
-				remaining := volume - accumulatedVolume
-				if remaining <= 0 {
+				remaining := volume.Sub(accumulatedVolume)
+				if remaining.Sign() <= 0 {
 					break
 				}
 
-				volumeToAdd := bid.Volume
-				if volumeToAdd > remaining {
-					volumeToAdd = remaining
-				}
+				volumeToAdd := domain.MinDecimal(bid.Volume, remaining)
 
-				weightedPrice += bid.Price * volumeToAdd
-				accumulatedVolume += volumeToAdd
+				weightedPrice = weightedPrice.Add(bid.Price.Mul(volumeToAdd))
+				accumulatedVolume = accumulatedVolume.Add(volumeToAdd)
 			}
 
-			if exactMatch && accumulatedVolume < volume {
-				return 0, 0, fmt.Errorf("insufficient volume in order book for pair %s", pair)
+			if exactMatch && accumulatedVolume.Cmp(volume) < 0 {
+				return price, totalVolume, fmt.Errorf("insufficient volume in order book for pair %s", pair)
 			}
 
-			if accumulatedVolume > 0 {
-				return weightedPrice / accumulatedVolume, accumulatedVolume, nil
+			if accumulatedVolume.Sign() > 0 {
+				return domain.Quo(weightedPrice, accumulatedVolume, domain.DefaultScale), accumulatedVolume, nil
 			}
-			return 0, 0, fmt.Errorf("no volume available in order book for pair %s", pair)
+			return price, totalVolume, fmt.Errorf("no volume available in order book for pair %s", pair)
 		}
-		return 0, 0, fmt.Errorf("no bids available in order book for pair %s", pair)
+		return price, totalVolume, fmt.Errorf("no bids available in order book for pair %s", pair)
 	}
-	return 0, 0, fmt.Errorf("no state found for pair %s", pair)
+	return price, totalVolume, fmt.Errorf("no state found for pair %s", pair)
 }
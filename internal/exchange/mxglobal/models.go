@@ -0,0 +1,48 @@
+package mxglobal
+
+import "github.com/luno/luno-go/decimal"
+
+type MXGlobalOrderBookPriceFeed struct {
+	Price  decimal.Decimal `json:"price"`
+	Volume decimal.Decimal `json:"quantity"`
+}
+
+type MXGlobalOrderBookResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Asks []MXGlobalOrderBookPriceFeed `json:"asks"`
+		Bids []MXGlobalOrderBookPriceFeed `json:"bids"`
+	} `json:"data"`
+	Message string `json:"msg"`
+}
+
+type MXGlobalCoinChain struct {
+	Chain             string          `json:"chain"`
+	Precision         int             `json:"precision"`
+	Fee               decimal.Decimal `json:"fee"`
+	IsWithdrawEnabled bool            `json:"is_withdraw_enabled"`
+	IsDepositEnabled  bool            `json:"is_deposit_enabled"`
+	WithdrawLimitMin  decimal.Decimal `json:"withdraw_limit_min"`
+	WithdrawLimitMax  decimal.Decimal `json:"withdraw_limit_max"`
+}
+
+type MXGlobalCoinListResponse struct {
+	Code int `json:"code"`
+	Data []struct {
+		Currency string              `json:"currency"`
+		Coins    []MXGlobalCoinChain `json:"coins"`
+	} `json:"data"`
+	Message string `json:"msg"`
+}
+
+type MXGlobalDepositAddressResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Currency string `json:"currency"`
+		Chains   []struct {
+			Chain   string `json:"chain"`
+			Address string `json:"address"`
+		} `json:"chains"`
+	} `json:"data"`
+	Message string `json:"msg"`
+}
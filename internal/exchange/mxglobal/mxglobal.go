@@ -0,0 +1,339 @@
+package mxglobal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+	"go.uber.org/zap"
+)
+
+type MXGlobalExchange struct {
+	apiBaseUrl   string
+	apiKeyId     string
+	apiKeySecret string
+	httpClient   *http.Client
+	networks     map[string]string // pair => preferred withdraw/deposit chain
+}
+
+const mxglobalApiBaseUrl = "https://www.mxglobal.com.my"
+const mxglobalOrderBookPath = "/open/api/v2/market/depth"
+const mxglobalOrderBookDepth = 100
+
+var Logger = logger.Get()
+var ScrapingLogger = logger.GetScrapingLogger()
+
+func CreateClient(id string, secret string, options httpclient.Options) *MXGlobalExchange {
+	Config := config.GetConfig()
+
+	networks := make(map[string]string)
+	for pair, crypto := range Config.Exchange[domain.MXGlobal.String()].Crypto {
+		networks[pair] = crypto.Network
+	}
+
+	return &MXGlobalExchange{
+		apiBaseUrl:   mxglobalApiBaseUrl,
+		apiKeyId:     id,
+		apiKeySecret: secret,
+		httpClient:   httpclient.NewClient(domain.MXGlobal.String(), options),
+		networks:     networks,
+	}
+}
+
+func (exchange *MXGlobalExchange) GetName() string {
+	return domain.MXGlobal.String()
+}
+
+// ToSymbol maps a pair name such as SOLMYR to the MXGlobal market symbol SOL_MYR.
+func ToSymbol(pair string) (string, error) {
+	base, quote, err := domain.SplitPair(pair)
+	if err != nil {
+		return "", err
+	}
+	return base + "_" + quote, nil
+}
+
+func (exchange *MXGlobalExchange) GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (fee decimal.Decimal, err error) {
+	chain, err := exchange.getCoinChain(ctx, pair, false)
+	if err != nil {
+		return fee, err
+	}
+
+	return chain.Fee, nil
+}
+
+func (exchange *MXGlobalExchange) GetWithdrawMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
+	chain, err := exchange.getCoinChain(ctx, pair, false)
+	if err != nil {
+		return min, err
+	}
+
+	return chain.WithdrawLimitMin, nil
+}
+
+func (exchange *MXGlobalExchange) GetDepositMin(ctx context.Context, pair string) (min decimal.Decimal, err error) {
+	// A route whose deposits are suspended cannot be executed, whatever its minimum
+	if _, err := exchange.getCoinChain(ctx, pair, true); err != nil {
+		return min, err
+	}
+
+	Config := config.GetConfig()
+	depositFee := Config.Exchange[domain.MXGlobal.String()].Crypto
+
+	if fee, exists := depositFee[pair]; exists {
+		return fee.DepositMinAmount, nil
+	}
+
+	return decimal.Zero(), nil
+}
+
+func (exchange *MXGlobalExchange) GetDepositAddress(ctx context.Context, pair string) (address string, err error) {
+	currency, _, err := domain.SplitPair(pair)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("currency", currency)
+
+	var respData MXGlobalDepositAddressResponse
+	err = exchange.getJson(ctx, pair, "/open/api/v2/asset/deposit/address/list", params, true, &respData)
+	if err != nil {
+		return "", err
+	}
+
+	network := exchange.networks[pair]
+	for _, chain := range respData.Data.Chains {
+		if network == "" || strings.EqualFold(chain.Chain, network) {
+			return chain.Address, nil
+		}
+	}
+
+	return "", fmt.Errorf("no MXGlobal deposit address found for %s on chain %q", currency, network)
+}
+
+func (exchange *MXGlobalExchange) GetCurrentOrderBook(ctx context.Context, pair string) (output domain.OrderBook, err error) {
+	symbol, err := ToSymbol(pair)
+	if err != nil {
+		return output, err
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("depth", strconv.Itoa(mxglobalOrderBookDepth))
+
+	Logger.Info("Getting MXGlobal order book for pair: " + pair)
+
+	respBody, err := exchange.sendRequest(ctx, pair, mxglobalOrderBookPath, params, false)
+	if err != nil {
+		return output, err
+	}
+
+	output, err = ParseOrderBookResponse(pair, respBody)
+	if err != nil {
+		return output, err
+	}
+	output.FetchedAt = time.Now()
+
+	Logger.Info(fmt.Sprintf("[%s] Ask: [{%s %s}] [{%s %s}] => Bid: [{%s %s}] [{%s %s}]", pair,
+		output.Asks[len(output.Asks)-1].Price,
+		output.Asks[len(output.Asks)-1].Volume,
+		output.Asks[0].Price,
+		output.Asks[0].Volume,
+		output.Bids[0].Price,
+		output.Bids[0].Volume,
+		output.Bids[len(output.Bids)-1].Price,
+		output.Bids[len(output.Bids)-1].Volume,
+	))
+
+	return output, nil
+}
+
+// ParseOrderBookResponse converts a raw MXGlobal depth response, as returned by the REST API and
+// recorded in the scraping log, into an order book sorted best price first.
+func ParseOrderBookResponse(pair string, respBody []byte) (output domain.OrderBook, err error) {
+	var respData MXGlobalOrderBookResponse
+	err = json.Unmarshal(respBody, &respData)
+	if err != nil {
+		return output, err
+	}
+
+	asks := respData.Data.Asks
+	bids := respData.Data.Bids
+	if len(asks) == 0 || len(bids) == 0 {
+		return output, fmt.Errorf("empty MXGlobal order book for pair %s", pair)
+	}
+
+	output.Pair = pair
+	output.Exchange = domain.MXGlobal
+	output.Asks = make([]domain.PriceLevel, 0, len(asks))
+	output.Bids = make([]domain.PriceLevel, 0, len(bids))
+
+	for _, ask := range asks {
+		output.Asks = append(output.Asks, domain.PriceLevel{
+			Price:  ask.Price,
+			Volume: ask.Volume,
+		})
+	}
+	for _, bid := range bids {
+		output.Bids = append(output.Bids, domain.PriceLevel{
+			Price:  bid.Price,
+			Volume: bid.Volume,
+		})
+	}
+
+	// Sort asks by price in ascending order (lowest first)
+	slices.SortFunc(output.Asks, func(a, b domain.PriceLevel) int {
+		return a.Price.Cmp(b.Price)
+	})
+
+	// Sort bids by price in descending order (highest first)
+	slices.SortFunc(output.Bids, func(a, b domain.PriceLevel) int {
+		return b.Price.Cmp(a.Price)
+	})
+
+	return output, nil
+}
+
+func (exchange *MXGlobalExchange) SubscribeSocket(ctx context.Context, pair string) (err error) {
+	return errors.New("websocket order book stream is not supported for " + exchange.GetName())
+}
+
+func (exchange *MXGlobalExchange) GetOrderBookUpdates(pair string) (updates <-chan *domain.OrderBook, err error) {
+	return nil, errors.New("websocket order book stream is not supported for " + exchange.GetName())
+}
+
+// getCoinChain returns the withdraw/deposit settings of the chain configured for the pair's base currency,
+// falling back to the first chain listed by MXGlobal when none is configured. Chains with withdrawals
+// suspended, or deposits when deposit is set, are skipped, since no transfer over them can be executed.
+func (exchange *MXGlobalExchange) getCoinChain(ctx context.Context, pair string, deposit bool) (chain MXGlobalCoinChain, err error) {
+	currency, _, err := domain.SplitPair(pair)
+	if err != nil {
+		return chain, err
+	}
+
+	params := url.Values{}
+	params.Set("currency", currency)
+
+	var respData MXGlobalCoinListResponse
+	err = exchange.getJson(ctx, pair, "/open/api/v2/market/coin/list", params, false, &respData)
+	if err != nil {
+		return chain, err
+	}
+
+	operation := "withdrawals"
+	if deposit {
+		operation = "deposits"
+	}
+	network := exchange.networks[pair]
+	for _, coin := range respData.Data {
+		if coin.Currency != currency {
+			continue
+		}
+		for _, chain := range coin.Coins {
+			if network != "" && !strings.EqualFold(chain.Chain, network) {
+				continue
+			}
+			if (deposit && !chain.IsDepositEnabled) || (!deposit && !chain.IsWithdrawEnabled) {
+				Logger.Info("Skipping MXGlobal " + currency + " chain " + chain.Chain + ", " + operation + " are suspended")
+				continue
+			}
+			return chain, nil
+		}
+	}
+
+	return chain, fmt.Errorf("no MXGlobal chain open for %s of %s on chain %q", operation, currency, network)
+}
+
+// sendRequest performs a GET request against the MXGlobal API and returns the response body once
+// the response code has been checked. Signed requests carry the ApiKey, Request-Time and Signature
+// headers, where the signature is HMAC-SHA256(secret, apiKey + requestTime + queryString).
+func (exchange *MXGlobalExchange) sendRequest(ctx context.Context, pair string, path string, params url.Values, signed bool) (respBody []byte, err error) {
+	queryString := params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", exchange.apiBaseUrl+path+"?"+queryString, nil)
+	if err != nil {
+		Logger.Error("Error creating request: " + err.Error())
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if signed {
+		requestTime := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		req.Header.Set("ApiKey", exchange.apiKeyId)
+		req.Header.Set("Request-Time", requestTime)
+		req.Header.Set("Signature", exchange.sign(requestTime, queryString))
+	}
+
+	resp, err := exchange.httpClient.Do(req)
+	if err != nil {
+		Logger.Error("Error sending request: " + err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		Logger.Error("Error reading response body: " + err.Error())
+		return nil, err
+	} else {
+		ScrapingLogger.Info(string(respBody), zap.String("exchange", exchange.GetName()), zap.String("pair", pair), zap.String("endpoint", path))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("MXGlobal %s returned status %d: %s", path, resp.StatusCode, string(respBody))
+	}
+
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"msg"`
+	}
+	err = json.Unmarshal(respBody, &status)
+	if err != nil {
+		Logger.Error("Error unmarshalling response body: " + err.Error())
+		return nil, err
+	}
+	if status.Code != http.StatusOK {
+		return nil, fmt.Errorf("MXGlobal %s returned code %d: %s", path, status.Code, status.Message)
+	}
+
+	return respBody, nil
+}
+
+// getJson performs a GET request and decodes the response into output.
+func (exchange *MXGlobalExchange) getJson(ctx context.Context, pair string, path string, params url.Values, signed bool, output any) error {
+	respBody, err := exchange.sendRequest(ctx, pair, path, params, signed)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(respBody, output)
+	if err != nil {
+		Logger.Error("Error unmarshalling response body: " + err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (exchange *MXGlobalExchange) sign(requestTime string, queryString string) string {
+	hmac := hmac.New(sha256.New, []byte(exchange.apiKeySecret))
+	hmac.Write([]byte(exchange.apiKeyId + requestTime + queryString))
+	return hex.EncodeToString(hmac.Sum(nil))
+}
@@ -0,0 +1,165 @@
+package mxglobal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func newTestExchange(t *testing.T, handler http.HandlerFunc) *MXGlobalExchange {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &MXGlobalExchange{
+		apiBaseUrl:   server.URL,
+		apiKeyId:     "key",
+		apiKeySecret: "secret",
+		httpClient:   server.Client(),
+		networks:     map[string]string{"SOLMYR": "SOL"},
+	}
+}
+
+func TestToSymbol(t *testing.T) {
+	cases := map[string]string{
+		"SOLMYR":  "SOL_MYR",
+		"AVAXMYR": "AVAX_MYR",
+		"SOLUSDT": "SOL_USDT",
+		"XLMBTC":  "XLM_BTC",
+	}
+	for pair, expected := range cases {
+		symbol, err := ToSymbol(pair)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", pair, err)
+		}
+		if symbol != expected {
+			t.Errorf("expected %s to map to %s; got %s", pair, expected, symbol)
+		}
+	}
+
+	if _, err := ToSymbol("MYR"); err == nil {
+		t.Errorf("expected error for pair without base currency")
+	}
+}
+
+func TestGetCurrentOrderBook(t *testing.T) {
+	exchange := newTestExchange(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/open/api/v2/market/depth" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("symbol") != "SOL_MYR" {
+			t.Errorf("expected symbol SOL_MYR; got %s", r.URL.Query().Get("symbol"))
+		}
+
+		// The depth endpoint is public, the credentials are not sent with it
+		if r.Header.Get("ApiKey") != "" || r.Header.Get("Signature") != "" {
+			t.Errorf("expected the order book request unsigned")
+		}
+
+		w.Write([]byte(`{"code":200,"data":{
+			"asks":[{"price":"1042.5","quantity":"2"},{"price":"1040","quantity":"1.5"}],
+			"bids":[{"price":"1038","quantity":"3"},{"price":"1039","quantity":"0.5"}]}}`))
+	})
+
+	orderbook, err := exchange.GetCurrentOrderBook(context.Background(), "SOLMYR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if orderbook.Pair != "SOLMYR" || orderbook.Exchange.String() != "MXGlobal" {
+		t.Errorf("unexpected order book identity %s on %s", orderbook.Pair, orderbook.Exchange)
+	}
+	if len(orderbook.Asks) != 2 || orderbook.Asks[0].Price.String() != "1040" || orderbook.Asks[0].Volume.String() != "1.5" {
+		t.Errorf("expected asks sorted lowest first; got %v", orderbook.Asks)
+	}
+	if len(orderbook.Bids) != 2 || orderbook.Bids[0].Price.String() != "1039" || orderbook.Bids[0].Volume.String() != "0.5" {
+		t.Errorf("expected bids sorted highest first; got %v", orderbook.Bids)
+	}
+}
+
+func TestGetCurrentOrderBookErrorCode(t *testing.T) {
+	exchange := newTestExchange(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":400,"msg":"invalid symbol"}`))
+	})
+
+	if _, err := exchange.GetCurrentOrderBook(context.Background(), "SOLMYR"); err == nil {
+		t.Errorf("expected error for non-200 response code")
+	}
+}
+
+func TestTransferFeeAndWithdrawMin(t *testing.T) {
+	exchange := newTestExchange(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/open/api/v2/market/coin/list" || r.URL.Query().Get("currency") != "SOL" {
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+		w.Write([]byte(`{"code":200,"data":[{"currency":"SOL","coins":[
+			{"chain":"BEP20","fee":0.5,"withdraw_limit_min":"1","is_withdraw_enabled":true,"is_deposit_enabled":true},
+			{"chain":"SOL","fee":0.008,"withdraw_limit_min":"0.05","is_withdraw_enabled":true,"is_deposit_enabled":true}]}]}`))
+	})
+
+	fee, err := exchange.GetTransferFee(context.Background(), "SOLMYR", "address", decimal.NewFromInt64(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fee.String() != "0.008" {
+		t.Errorf("expected fee of configured SOL chain 0.008; got %v", fee)
+	}
+
+	min, err := exchange.GetWithdrawMin(context.Background(), "SOLMYR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if min.String() != "0.05" {
+		t.Errorf("expected withdraw minimum 0.05; got %v", min)
+	}
+}
+
+func TestSuspendedChains(t *testing.T) {
+	exchange := newTestExchange(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":200,"data":[{"currency":"SOL","coins":[
+			{"chain":"BEP20","fee":0.5,"withdraw_limit_min":"1","is_withdraw_enabled":true,"is_deposit_enabled":true},
+			{"chain":"SOL","fee":0.008,"withdraw_limit_min":"0.05","is_withdraw_enabled":false,"is_deposit_enabled":false}]}]}`))
+	})
+
+	// The configured SOL chain is suspended, the BEP20 chain is not used in its place
+	if fee, err := exchange.GetTransferFee(context.Background(), "SOLMYR", "address", decimal.NewFromInt64(1)); err == nil {
+		t.Errorf("expected an error for suspended withdrawals; got fee %v", fee)
+	}
+	if min, err := exchange.GetWithdrawMin(context.Background(), "SOLMYR"); err == nil {
+		t.Errorf("expected an error for suspended withdrawals; got minimum %v", min)
+	}
+	if min, err := exchange.GetDepositMin(context.Background(), "SOLMYR"); err == nil {
+		t.Errorf("expected an error for suspended deposits; got minimum %v", min)
+	}
+
+	// Without a configured chain the first open one is used
+	exchange.networks = nil
+	fee, err := exchange.GetTransferFee(context.Background(), "SOLMYR", "address", decimal.NewFromInt64(1))
+	if err != nil || fee.String() != "0.5" {
+		t.Errorf("expected the fee of the open BEP20 chain 0.5; got %v (%v)", fee, err)
+	}
+}
+
+func TestGetDepositAddress(t *testing.T) {
+	exchange := newTestExchange(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/open/api/v2/asset/deposit/address/list" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Signature") == "" {
+			t.Errorf("expected deposit address request to be signed")
+		}
+		w.Write([]byte(`{"code":200,"data":{"currency":"SOL","chains":[
+			{"chain":"BEP20","address":"0xabc"},
+			{"chain":"SOL","address":"So1anaAddress"}]}}`))
+	})
+
+	address, err := exchange.GetDepositAddress(context.Background(), "SOLMYR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if address != "So1anaAddress" {
+		t.Errorf("expected SOL chain address; got %s", address)
+	}
+}
@@ -0,0 +1,346 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// PaperExchange wraps a live exchange and simulates order execution against its current order book.
+// Market data calls are delegated to the wrapped exchange; balances, orders and withdrawals only
+// exist in memory.
+type PaperExchange struct {
+	domain.Exchanger
+	takerFee    decimal.Decimal
+	mutex       sync.Mutex
+	balances    map[string]decimal.Decimal // currency => available amount
+	orders      map[string]*paperOrder
+	nextOrderId int
+}
+
+type paperOrder struct {
+	domain.Order
+	reserved decimal.Decimal // funds held for the unfilled part of a resting limit order
+}
+
+var Logger = logger.Get()
+
+var one = decimal.NewFromInt64(1)
+
+// Deposit addresses of paper exchanges, so withdrawals can be routed to the receiving exchange.
+var registry = struct {
+	sync.Mutex
+	exchanges map[string]*PaperExchange
+}{exchanges: make(map[string]*PaperExchange)}
+
+func CreateClient(exchange domain.Exchanger, takerFee decimal.Decimal, balances map[string]decimal.Decimal) *PaperExchange {
+	paperExchange := &PaperExchange{
+		Exchanger: exchange,
+		takerFee:  takerFee,
+		balances:  make(map[string]decimal.Decimal),
+		orders:    make(map[string]*paperOrder),
+	}
+	for currency, amount := range balances {
+		paperExchange.balances[currency] = amount
+	}
+
+	registry.Lock()
+	registry.exchanges[exchange.GetName()] = paperExchange
+	registry.Unlock()
+
+	Logger.Info("Paper trading enabled for " + exchange.GetName())
+
+	return paperExchange
+}
+
+// GetDepositAddress returns a simulated address that routes paper withdrawals to this exchange.
+func (exchange *PaperExchange) GetDepositAddress(ctx context.Context, pair string) (address string, err error) {
+	return "paper:" + exchange.GetName(), nil
+}
+
+func (exchange *PaperExchange) GetBalances(ctx context.Context) (balances map[string]decimal.Decimal, err error) {
+	exchange.mutex.Lock()
+	defer exchange.mutex.Unlock()
+
+	balances = make(map[string]decimal.Decimal, len(exchange.balances))
+	for currency, amount := range exchange.balances {
+		balances[currency] = amount
+	}
+	return balances, nil
+}
+
+func (exchange *PaperExchange) PlaceOrder(ctx context.Context, request domain.OrderRequest) (order domain.Order, err error) {
+	base, quote, err := domain.SplitPair(request.Pair)
+	if err != nil {
+		return order, err
+	}
+	if request.Volume.Sign() <= 0 {
+		return order, fmt.Errorf("order volume must be positive, got %v", request.Volume)
+	}
+	if request.Type == domain.Limit && request.Price.Sign() <= 0 {
+		return order, fmt.Errorf("limit order price must be positive, got %v", request.Price)
+	}
+
+	orderbook, err := exchange.GetCurrentOrderBook(ctx, request.Pair)
+	if err != nil {
+		return order, err
+	}
+
+	exchange.mutex.Lock()
+	defer exchange.mutex.Unlock()
+
+	exchange.nextOrderId++
+	now := time.Now()
+	placed := &paperOrder{Order: domain.Order{
+		Id:           exchange.GetName() + "-paper-" + strconv.Itoa(exchange.nextOrderId),
+		Exchange:     exchange.GetName(),
+		Pair:         request.Pair,
+		Side:         request.Side,
+		Type:         request.Type,
+		Price:        request.Price,
+		Volume:       request.Volume,
+		Status:       domain.Open,
+		FilledVolume: decimal.Zero(),
+		FilledAmount: decimal.Zero(),
+		Fee:          decimal.Zero(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}}
+
+	exchange.match(placed, orderbook, base, quote)
+
+	if placed.Type == domain.Market {
+		// Market orders never rest on the book, the unfilled part is cancelled
+		if placed.FilledVolume.Sign() == 0 {
+			placed.Status = domain.Rejected
+		} else if placed.Status != domain.Filled {
+			placed.Status = domain.Cancelled
+		}
+	} else if placed.Status != domain.Filled {
+		// Hold funds for the resting part of the limit order
+		remaining := placed.Volume.Sub(placed.FilledVolume)
+		if placed.Side == domain.Buy {
+			placed.reserved = remaining.Mul(placed.Price).Mul(one.Add(exchange.takerFee))
+			if exchange.balances[quote].Cmp(placed.reserved) < 0 {
+				placed.reserved = decimal.Zero()
+				placed.Status = rejectedOrCancelled(&placed.Order)
+			} else {
+				exchange.balances[quote] = exchange.balances[quote].Sub(placed.reserved)
+			}
+		} else {
+			placed.reserved = remaining
+			if exchange.balances[base].Cmp(placed.reserved) < 0 {
+				placed.reserved = decimal.Zero()
+				placed.Status = rejectedOrCancelled(&placed.Order)
+			} else {
+				exchange.balances[base] = exchange.balances[base].Sub(placed.reserved)
+			}
+		}
+	}
+
+	exchange.orders[placed.Id] = placed
+	Logger.Info(fmt.Sprintf("Paper %s %s %s order %s on %s: filled %v of %v, status %s", placed.Type, placed.Side, placed.Pair, placed.Id, placed.Exchange, placed.FilledVolume, placed.Volume, placed.Status))
+
+	if placed.Status == domain.Rejected {
+		return placed.Order, fmt.Errorf("paper order %s rejected: no liquidity or insufficient balance", placed.Id)
+	}
+
+	return placed.Order, nil
+}
+
+func (exchange *PaperExchange) CancelOrder(ctx context.Context, orderId string) (err error) {
+	exchange.mutex.Lock()
+	defer exchange.mutex.Unlock()
+
+	order, ok := exchange.orders[orderId]
+	if !ok {
+		return fmt.Errorf("paper order %s not found", orderId)
+	}
+	if order.Status != domain.Open && order.Status != domain.PartiallyFilled {
+		return fmt.Errorf("paper order %s is already %s", orderId, order.Status)
+	}
+
+	base, quote, err := domain.SplitPair(order.Pair)
+	if err != nil {
+		return err
+	}
+	if order.Side == domain.Buy {
+		exchange.balances[quote] = exchange.balances[quote].Add(order.reserved)
+	} else {
+		exchange.balances[base] = exchange.balances[base].Add(order.reserved)
+	}
+	order.reserved = decimal.Zero()
+	order.Status = domain.Cancelled
+	order.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// GetOrder returns the order, first matching any resting limit order against the latest order book.
+func (exchange *PaperExchange) GetOrder(ctx context.Context, orderId string) (order domain.Order, err error) {
+	exchange.mutex.Lock()
+	placed, ok := exchange.orders[orderId]
+	if !ok {
+		exchange.mutex.Unlock()
+		return order, fmt.Errorf("paper order %s not found", orderId)
+	}
+	isResting := placed.Status == domain.Open || placed.Status == domain.PartiallyFilled
+	pair := placed.Pair
+	exchange.mutex.Unlock()
+
+	if !isResting {
+		return placed.Order, nil
+	}
+
+	orderbook, err := exchange.GetCurrentOrderBook(ctx, pair)
+	if err != nil {
+		return order, err
+	}
+	base, quote, err := domain.SplitPair(pair)
+	if err != nil {
+		return order, err
+	}
+
+	exchange.mutex.Lock()
+	defer exchange.mutex.Unlock()
+
+	if placed.Status == domain.Open || placed.Status == domain.PartiallyFilled {
+		exchange.match(placed, orderbook, base, quote)
+	}
+
+	return placed.Order, nil
+}
+
+// Withdraw moves the pair's base currency to the paper exchange owning the address, deducting
+// the wrapped exchange's transfer fee from the amount received.
+func (exchange *PaperExchange) Withdraw(ctx context.Context, pair string, address string, amount decimal.Decimal) (withdrawalId string, err error) {
+	currency, _, err := domain.SplitPair(pair)
+	if err != nil {
+		return "", err
+	}
+
+	var destination *PaperExchange
+	registry.Lock()
+	for _, paperExchange := range registry.exchanges {
+		if paperAddress, _ := paperExchange.GetDepositAddress(ctx, pair); paperAddress == address {
+			destination = paperExchange
+		}
+	}
+	registry.Unlock()
+	if destination == nil {
+		return "", fmt.Errorf("no paper exchange owns deposit address %s", address)
+	}
+
+	fee := decimal.Zero()
+	transferFee, err := exchange.Exchanger.GetTransferFee(ctx, pair, address, amount)
+	if err == nil && transferFee.Sign() > 0 {
+		fee = transferFee
+	}
+
+	exchange.mutex.Lock()
+	if exchange.balances[currency].Cmp(amount) < 0 {
+		exchange.mutex.Unlock()
+		return "", fmt.Errorf("insufficient %s balance on %s: %v < %v", currency, exchange.GetName(), exchange.balances[currency], amount)
+	}
+	exchange.balances[currency] = exchange.balances[currency].Sub(amount)
+	exchange.nextOrderId++
+	withdrawalId = exchange.GetName() + "-paper-withdrawal-" + strconv.Itoa(exchange.nextOrderId)
+	exchange.mutex.Unlock()
+
+	if received := amount.Sub(fee); received.Sign() > 0 {
+		destination.mutex.Lock()
+		destination.balances[currency] = destination.balances[currency].Add(received)
+		destination.mutex.Unlock()
+	}
+
+	Logger.Info(fmt.Sprintf("Paper withdrawal %s: %v %s from %s to %s, fee %v", withdrawalId, amount, currency, exchange.GetName(), destination.GetName(), fee))
+
+	return withdrawalId, nil
+}
+
+// match fills the unfilled part of the order against the order book as a taker, limited by the
+// order's limit price and the available balance. Callers must hold mutex.
+func (exchange *PaperExchange) match(order *paperOrder, orderbook domain.OrderBook, base string, quote string) {
+	levels := orderbook.Asks
+	if order.Side == domain.Sell {
+		levels = orderbook.Bids
+	}
+
+	for _, level := range levels {
+		remaining := order.Volume.Sub(order.FilledVolume)
+		if remaining.Sign() <= 0 {
+			break
+		}
+		if order.Type == domain.Limit {
+			if order.Side == domain.Buy && level.Price.Cmp(order.Price) > 0 {
+				break
+			}
+			if order.Side == domain.Sell && level.Price.Cmp(order.Price) < 0 {
+				break
+			}
+		}
+
+		volume := domain.MinDecimal(remaining, level.Volume)
+		amount := volume.Mul(level.Price)
+		if order.Side == domain.Buy {
+			// Resting orders pay from their reservation first, then from the free balance
+			available := order.reserved.Add(exchange.balances[quote])
+			unitCost := level.Price.Mul(one.Add(exchange.takerFee))
+			if available.Cmp(amount.Mul(one.Add(exchange.takerFee))) < 0 {
+				volume = available.Div(unitCost, domain.DefaultScale) // truncated, never exceeds the balance
+				amount = volume.Mul(level.Price)
+			}
+			if volume.Sign() <= 0 {
+				break
+			}
+			cost := amount.Mul(one.Add(exchange.takerFee))
+			fromReserved := domain.MinDecimal(cost, order.reserved)
+			order.reserved = order.reserved.Sub(fromReserved)
+			exchange.balances[quote] = exchange.balances[quote].Sub(cost.Sub(fromReserved))
+			exchange.balances[base] = exchange.balances[base].Add(volume)
+		} else {
+			available := order.reserved.Add(exchange.balances[base])
+			volume = domain.MinDecimal(volume, available)
+			if volume.Sign() <= 0 {
+				break
+			}
+			amount = volume.Mul(level.Price)
+			fromReserved := domain.MinDecimal(volume, order.reserved)
+			order.reserved = order.reserved.Sub(fromReserved)
+			exchange.balances[base] = exchange.balances[base].Sub(volume.Sub(fromReserved))
+			exchange.balances[quote] = exchange.balances[quote].Add(amount.Mul(one.Sub(exchange.takerFee)))
+		}
+
+		order.FilledVolume = order.FilledVolume.Add(volume)
+		order.FilledAmount = order.FilledAmount.Add(amount)
+		order.Fee = order.Fee.Add(amount.Mul(exchange.takerFee))
+	}
+
+	if order.FilledVolume.Cmp(order.Volume) >= 0 {
+		order.Status = domain.Filled
+		// Release whatever is left of the reservation, e.g. when filled below the limit price
+		if order.Side == domain.Buy {
+			exchange.balances[quote] = exchange.balances[quote].Add(order.reserved)
+		} else {
+			exchange.balances[base] = exchange.balances[base].Add(order.reserved)
+		}
+		order.reserved = decimal.Zero()
+	} else if order.FilledVolume.Sign() > 0 {
+		order.Status = domain.PartiallyFilled
+	}
+	order.UpdatedAt = time.Now()
+}
+
+// rejectedOrCancelled is the final status of an order whose remainder cannot rest on the book.
+func rejectedOrCancelled(order *domain.Order) domain.OrderStatusEnum {
+	if order.FilledVolume.Sign() > 0 {
+		return domain.Cancelled
+	}
+	return domain.Rejected
+}
@@ -0,0 +1,162 @@
+package paper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+)
+
+type stubExchange struct {
+	domain.Exchanger
+	name        string
+	orderbook   domain.OrderBook
+	transferFee decimal.Decimal
+}
+
+func (exchange *stubExchange) GetName() string { return exchange.name }
+
+func (exchange *stubExchange) GetCurrentOrderBook(ctx context.Context, pair string) (domain.OrderBook, error) {
+	return exchange.orderbook, nil
+}
+
+func (exchange *stubExchange) GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (decimal.Decimal, error) {
+	return exchange.transferFee, nil
+}
+
+func newStubExchange(name string) *stubExchange {
+	return &stubExchange{
+		name: name,
+		orderbook: domain.OrderBook{
+			Pair: "SOLMYR",
+			Asks: []domain.PriceLevel{{Price: dec("100"), Volume: dec("1")}, {Price: dec("101"), Volume: dec("2")}},
+			Bids: []domain.PriceLevel{{Price: dec("99"), Volume: dec("1")}, {Price: dec("98"), Volume: dec("2")}},
+		},
+		transferFee: dec("0.01"),
+	}
+}
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func equal(a decimal.Decimal, b string) bool {
+	return a.Cmp(dec(b)) == 0
+}
+
+func TestMarketBuyWalksOrderBook(t *testing.T) {
+	exchange := CreateClient(newStubExchange("PaperA"), dec("0.01"), map[string]decimal.Decimal{"MYR": dec("1000")})
+
+	order, err := exchange.PlaceOrder(context.Background(), domain.OrderRequest{Pair: "SOLMYR", Side: domain.Buy, Type: domain.Market, Volume: dec("2")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if order.Status != domain.Filled || !equal(order.FilledVolume, "2") {
+		t.Fatalf("expected order filled for 2; got %s for %v", order.Status, order.FilledVolume)
+	}
+	if !equal(order.GetAveragePrice(), "100.5") {
+		t.Errorf("expected average price 100.5; got %v", order.GetAveragePrice())
+	}
+
+	balances, _ := exchange.GetBalances(context.Background())
+	if !equal(balances["MYR"], "796.99") || !equal(balances["SOL"], "2") {
+		t.Errorf("unexpected balances after buy: %v", balances)
+	}
+}
+
+func TestMarketOrderLimitedByBalance(t *testing.T) {
+	exchange := CreateClient(newStubExchange("PaperA"), decimal.Zero(), map[string]decimal.Decimal{"MYR": dec("50")})
+
+	order, err := exchange.PlaceOrder(context.Background(), domain.OrderRequest{Pair: "SOLMYR", Side: domain.Buy, Type: domain.Market, Volume: dec("1")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != domain.Cancelled || !equal(order.FilledVolume, "0.5") {
+		t.Errorf("expected partial fill of 0.5 with remainder cancelled; got %s for %v", order.Status, order.FilledVolume)
+	}
+
+	empty := CreateClient(newStubExchange("PaperB"), decimal.Zero(), nil)
+	if _, err := empty.PlaceOrder(context.Background(), domain.OrderRequest{Pair: "SOLMYR", Side: domain.Sell, Type: domain.Market, Volume: dec("1")}); err == nil {
+		t.Errorf("expected rejection when selling without balance")
+	}
+}
+
+func TestLimitOrderRestsAndCancelReleasesFunds(t *testing.T) {
+	exchange := CreateClient(newStubExchange("PaperA"), decimal.Zero(), map[string]decimal.Decimal{"MYR": dec("1000")})
+
+	order, err := exchange.PlaceOrder(context.Background(), domain.OrderRequest{Pair: "SOLMYR", Side: domain.Buy, Type: domain.Limit, Price: dec("100"), Volume: dec("3")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != domain.PartiallyFilled || !equal(order.FilledVolume, "1") {
+		t.Fatalf("expected 1 filled at or below limit price; got %s for %v", order.Status, order.FilledVolume)
+	}
+
+	balances, _ := exchange.GetBalances(context.Background())
+	if !equal(balances["MYR"], "700") {
+		t.Errorf("expected 200 MYR reserved for the resting part; got balance %v", balances["MYR"])
+	}
+
+	if err := exchange.CancelOrder(context.Background(), order.Id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	order, _ = exchange.GetOrder(context.Background(), order.Id)
+	if order.Status != domain.Cancelled {
+		t.Errorf("expected cancelled order; got %s", order.Status)
+	}
+
+	balances, _ = exchange.GetBalances(context.Background())
+	if !equal(balances["MYR"], "900") || !equal(balances["SOL"], "1") {
+		t.Errorf("expected reservation released; got %v", balances)
+	}
+}
+
+func TestRestingLimitOrderFillsOnLaterBook(t *testing.T) {
+	stub := newStubExchange("PaperA")
+	exchange := CreateClient(stub, decimal.Zero(), map[string]decimal.Decimal{"SOL": dec("2")})
+
+	order, err := exchange.PlaceOrder(context.Background(), domain.OrderRequest{Pair: "SOLMYR", Side: domain.Sell, Type: domain.Limit, Price: dec("105"), Volume: dec("2")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != domain.Open {
+		t.Fatalf("expected resting order; got %s", order.Status)
+	}
+
+	stub.orderbook.Bids = []domain.PriceLevel{{Price: dec("106"), Volume: dec("5")}}
+	order, _ = exchange.GetOrder(context.Background(), order.Id)
+	if order.Status != domain.Filled || !equal(order.FilledAmount, "212") {
+		t.Errorf("expected order filled at 106; got %s for %v", order.Status, order.FilledAmount)
+	}
+
+	balances, _ := exchange.GetBalances(context.Background())
+	if !equal(balances["SOL"], "0") || !equal(balances["MYR"], "212") {
+		t.Errorf("unexpected balances after fill: %v", balances)
+	}
+}
+
+func TestWithdrawBetweenPaperExchanges(t *testing.T) {
+	from := CreateClient(newStubExchange("PaperFrom"), decimal.Zero(), map[string]decimal.Decimal{"SOL": dec("1")})
+	to := CreateClient(newStubExchange("PaperTo"), decimal.Zero(), nil)
+
+	address, _ := to.GetDepositAddress(context.Background(), "SOLMYR")
+	if _, err := from.Withdraw(context.Background(), "SOLMYR", address, dec("1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromBalances, _ := from.GetBalances(context.Background())
+	toBalances, _ := to.GetBalances(context.Background())
+	if !equal(fromBalances["SOL"], "0") || !equal(toBalances["SOL"], "0.99") {
+		t.Errorf("expected 1 SOL withdrawn and 0.99 received; got %v and %v", fromBalances, toBalances)
+	}
+
+	if _, err := from.Withdraw(context.Background(), "SOLMYR", address, dec("1")); err == nil {
+		t.Errorf("expected error when withdrawing more than the balance")
+	}
+}
@@ -0,0 +1,147 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
+	"sync"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+var Logger = logger.Get()
+
+// Static is the source of rates taken from the config rather than an order book.
+const Static = "Static"
+
+// How long a rate, or the failure to get one, is reused before the order book is fetched again.
+const cacheDuration = 10 * time.Second
+
+// Provider prices quote currencies from the best bid and ask of an exchange's order book, such as
+// Luno's USDTMYR, falling back to the configured static rates.
+type Provider struct {
+	exchange  domain.Exchanger // nil to use the static rates only
+	bookFee   decimal.Decimal
+	rates     map[string]decimal.Decimal // pair => static mid rate
+	staticFee decimal.Decimal
+	mutex     sync.Mutex
+	cache     map[string]cachedRate   // pair => last lookup
+	pending   map[string]*pendingRate // pair => lookup in flight
+}
+
+type cachedRate struct {
+	rate      domain.FxRate
+	err       error
+	fetchedAt time.Time
+}
+
+// pendingRate is a lookup in flight, which callers asking for the same pair wait on rather than
+// fetching the order book again.
+type pendingRate struct {
+	done chan struct{}
+	cachedRate
+}
+
+// CreateProvider returns the provider configured by Fx. The rate exchange must be one of exchanges.
+func CreateProvider(config *config.Config, exchanges map[string]domain.Exchanger) (provider *Provider, err error) {
+	provider = &Provider{
+		rates:     config.Fx.Rates,
+		staticFee: config.Fx.Fee,
+		cache:     make(map[string]cachedRate),
+		pending:   make(map[string]*pendingRate),
+	}
+	if config.Fx.Exchange != "" {
+		exchange, ok := exchanges[config.Fx.Exchange]
+		if !ok {
+			return nil, fmt.Errorf("fx exchange %s is not enabled", config.Fx.Exchange)
+		}
+		provider.exchange = exchange
+		provider.bookFee = config.Exchange[config.Fx.Exchange].TakerFee
+	}
+	return provider, nil
+}
+
+// GetRate returns the rate converting from into to, from the pair quoting from in to, or else the
+// inverse of the pair quoting to in from.
+func (provider *Provider) GetRate(ctx context.Context, from string, to string) (rate domain.FxRate, err error) {
+	if from == to {
+		return domain.FxRate{From: from, To: to, Bid: decimal.NewFromInt64(1), Ask: decimal.NewFromInt64(1), Fee: decimal.Zero(), Source: Static}, nil
+	}
+
+	rate, err = provider.getPairRate(ctx, domain.NewPair(from, to))
+	if err == nil {
+		return rate, nil
+	}
+	inverse, inverseErr := provider.getPairRate(ctx, domain.NewPair(to, from))
+	if inverseErr == nil {
+		return inverse.Invert(), nil
+	}
+	return rate, fmt.Errorf("no fx rate from %s to %s: %w", from, to, err)
+}
+
+// getPairRate returns the cached rate of the pair, or fetches it. The mutex is not held while the order
+// book is fetched, so a slow exchange only holds back the callers asking for the same pair.
+func (provider *Provider) getPairRate(ctx context.Context, pair domain.Pair) (rate domain.FxRate, err error) {
+	provider.mutex.Lock()
+	if cached, ok := provider.cache[pair.Symbol]; ok && time.Since(cached.fetchedAt) < cacheDuration {
+		provider.mutex.Unlock()
+		return cached.rate, cached.err
+	}
+	if pending, ok := provider.pending[pair.Symbol]; ok {
+		provider.mutex.Unlock()
+		select {
+		case <-pending.done:
+			return pending.rate, pending.err
+		case <-ctx.Done():
+			return rate, ctx.Err()
+		}
+	}
+	pending := &pendingRate{done: make(chan struct{})}
+	provider.pending[pair.Symbol] = pending
+	provider.mutex.Unlock()
+
+	rate, err = provider.fetchPairRate(ctx, pair)
+	pending.cachedRate = cachedRate{rate: rate, err: err, fetchedAt: time.Now()}
+
+	provider.mutex.Lock()
+	provider.cache[pair.Symbol] = pending.cachedRate
+	delete(provider.pending, pair.Symbol)
+	provider.mutex.Unlock()
+	close(pending.done)
+
+	return rate, err
+}
+
+func (provider *Provider) fetchPairRate(ctx context.Context, pair domain.Pair) (rate domain.FxRate, err error) {
+	if provider.exchange != nil {
+		orderbook, bookErr := provider.exchange.GetCurrentOrderBook(ctx, pair.Symbol)
+		if bookErr == nil && len(orderbook.Asks) > 0 && len(orderbook.Bids) > 0 {
+			return domain.FxRate{
+				From:   pair.Base,
+				To:     pair.Quote,
+				Bid:    orderbook.Bids[0].Price,
+				Ask:    orderbook.Asks[0].Price,
+				Fee:    provider.bookFee,
+				Source: provider.exchange.GetName(),
+			}, nil
+		}
+		if bookErr == nil {
+			bookErr = fmt.Errorf("empty %s order book", pair.Symbol)
+		}
+		err = bookErr
+	}
+
+	if static, ok := provider.rates[pair.Symbol]; ok && static.Sign() > 0 {
+		if err != nil {
+			Logger.Info("Using static " + pair.Symbol + " rate: " + err.Error())
+		}
+		return domain.FxRate{From: pair.Base, To: pair.Quote, Bid: static, Ask: static, Fee: provider.staticFee, Source: Static}, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("no %s rate configured", pair.Symbol)
+	}
+	return rate, err
+}
@@ -0,0 +1,167 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+type stubExchange struct {
+	domain.Exchanger
+	orderbooks map[string]domain.OrderBook
+	calls      int
+}
+
+func (exchange *stubExchange) GetName() string { return "Luno" }
+
+func (exchange *stubExchange) GetCurrentOrderBook(ctx context.Context, pair string) (domain.OrderBook, error) {
+	exchange.calls++
+	orderbook, ok := exchange.orderbooks[pair]
+	if !ok {
+		return orderbook, errors.New("unknown pair " + pair)
+	}
+	return orderbook, nil
+}
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func newProvider(t *testing.T, exchange *stubExchange) *Provider {
+	cfg := &config.Config{}
+	cfg.Fx.Rates = map[string]decimal.Decimal{"USDCMYR": dec("4.4")}
+	cfg.Fx.Fee = dec("0.002")
+	exchanges := make(map[string]domain.Exchanger)
+	if exchange != nil {
+		cfg.Fx.Exchange = "Luno"
+		exchanges["Luno"] = exchange
+	}
+	provider, err := CreateProvider(cfg, exchanges)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return provider
+}
+
+func TestRateFromOrderBook(t *testing.T) {
+	exchange := &stubExchange{orderbooks: map[string]domain.OrderBook{
+		"USDTMYR": {
+			Pair: "USDTMYR",
+			Asks: []domain.PriceLevel{{Price: dec("4.5"), Volume: dec("1000")}},
+			Bids: []domain.PriceLevel{{Price: dec("4.4"), Volume: dec("1000")}},
+		},
+	}}
+	provider := newProvider(t, exchange)
+
+	rate, err := provider.GetRate(context.Background(), "USDT", "MYR")
+	if err != nil || rate.Bid.Cmp(dec("4.4")) != 0 || rate.Ask.Cmp(dec("4.5")) != 0 || rate.Source != "Luno" {
+		t.Fatalf("unexpected rate %+v, %v", rate, err)
+	}
+
+	// MYRUSDT is not listed, so the USDTMYR book is inverted; both lookups are cached
+	inverse, err := provider.GetRate(context.Background(), "MYR", "USDT")
+	if err != nil || inverse.From != "MYR" || inverse.Ask.Cmp(domain.Quo(dec("1"), dec("4.4"), domain.DefaultScale)) != 0 {
+		t.Fatalf("unexpected inverse rate %+v, %v", inverse, err)
+	}
+	provider.GetRate(context.Background(), "MYR", "USDT")
+	if exchange.calls != 2 {
+		t.Errorf("expected 2 order book requests, got %d", exchange.calls)
+	}
+}
+
+func TestStaticRate(t *testing.T) {
+	provider := newProvider(t, &stubExchange{})
+
+	rate, err := provider.GetRate(context.Background(), "USDC", "MYR")
+	if err != nil || rate.Bid.Cmp(dec("4.4")) != 0 || rate.Ask.Cmp(dec("4.4")) != 0 || rate.Source != Static || rate.Fee.Cmp(dec("0.002")) != 0 {
+		t.Fatalf("unexpected static rate %+v, %v", rate, err)
+	}
+
+	if _, err := newProvider(t, nil).GetRate(context.Background(), "USDT", "MYR"); err == nil {
+		t.Errorf("expected an error without a book or static rate")
+	}
+	if rate, err := newProvider(t, nil).GetRate(context.Background(), "MYR", "MYR"); err != nil || rate.Bid.Cmp(dec("1")) != 0 {
+		t.Errorf("expected a unit rate for the same currency, got %+v, %v", rate, err)
+	}
+}
+
+type blockingExchange struct {
+	domain.Exchanger
+	started chan string
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (exchange *blockingExchange) GetName() string { return "Luno" }
+
+func (exchange *blockingExchange) GetCurrentOrderBook(ctx context.Context, pair string) (domain.OrderBook, error) {
+	exchange.calls.Add(1)
+	if pair != "USDTMYR" {
+		return domain.OrderBook{}, errors.New("unknown pair " + pair)
+	}
+	exchange.started <- pair
+	<-exchange.release
+	return domain.OrderBook{
+		Pair: pair,
+		Asks: []domain.PriceLevel{{Price: dec("4.5"), Volume: dec("1000")}},
+		Bids: []domain.PriceLevel{{Price: dec("4.4"), Volume: dec("1000")}},
+	}, nil
+}
+
+func TestSlowRateHoldsBackOnlyItsPair(t *testing.T) {
+	exchange := &blockingExchange{started: make(chan string, 1), release: make(chan struct{})}
+	cfg := &config.Config{}
+	cfg.Fx.Exchange = "Luno"
+	cfg.Fx.Rates = map[string]decimal.Decimal{"USDCMYR": dec("4.4")}
+	provider, err := CreateProvider(cfg, map[string]domain.Exchanger{"Luno": exchange})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rates := make(chan domain.FxRate, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			rate, err := provider.GetRate(context.Background(), "USDT", "MYR")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			rates <- rate
+		}()
+	}
+	<-exchange.started
+
+	// The USDTMYR book is still being fetched
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if rate, err := provider.GetRate(context.Background(), "USDC", "MYR"); err != nil || rate.Source != Static {
+			t.Errorf("unexpected static rate %+v, %v", rate, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the USDC rate while the USDT book is fetched")
+	}
+
+	close(exchange.release)
+	for i := 0; i < 2; i++ {
+		if rate := <-rates; rate.Ask.Cmp(dec("4.5")) != 0 {
+			t.Errorf("unexpected rate %+v", rate)
+		}
+	}
+	// One USDTMYR request shared by both callers, and the USDCMYR one
+	if calls := exchange.calls.Load(); calls != 2 {
+		t.Errorf("expected 2 order book requests, got %d", calls)
+	}
+}
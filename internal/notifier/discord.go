@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"context"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/disgo/webhook"
+)
+
+// DiscordNotifier posts opportunities as embeds to a Discord webhook. The webhook client is created
+// once and reused for every alert.
+type DiscordNotifier struct {
+	client webhook.Client
+}
+
+func CreateDiscordNotifier(webhookUrl string, opts ...webhook.ConfigOpt) (*DiscordNotifier, error) {
+	client, err := webhook.NewWithURL(webhookUrl, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &DiscordNotifier{client: client}, nil
+}
+
+func (notifier *DiscordNotifier) GetName() string {
+	return domain.Discord.String()
+}
+
+func (notifier *DiscordNotifier) Notify(ctx context.Context, alert domain.Alert) error {
+	opportunity := alert.Opportunity
+
+	color := 0x00ff00
+	if alert.Type == domain.OpportunityClosed {
+		color = 0x808080
+	}
+
+	embed := discord.NewEmbedBuilder().
+		SetTitle(alertTitle(alert)).
+		SetColor(color).
+		AddField("Buy On", opportunity.BuyOn, true).
+		AddField("Sell On", opportunity.SellOn, true).
+		AddField("Pair", opportunity.Pair, true).
+		AddField("\u200B", "\u200B", false).
+		AddField("Buy Price", opportunity.BuyPrice.String(), true).
+		AddField("Buy Volume", opportunity.BuyVolume.String(), true).
+		AddField("Total Buy Price", opportunity.TotalBuyPrice.String(), true).
+		AddField("Sell Price", opportunity.SellPrice.String(), true).
+		AddField("Sell Volume", opportunity.SellVolume.String(), true).
+		AddField("Total Sell Price", opportunity.TotalSellPrice.String(), true).
+		AddField("\u200B", "\u200B", false).
+		AddField("Buy Fee", opportunity.BuyFee.String(), true).
+		AddField("Sell Fee", opportunity.SellFee.String(), true).
+		AddField("Net Profit", opportunity.NetProfit.String(), true)
+	if alert.Type == domain.OpportunityClosed {
+		embed.
+			AddField("Open For", alert.GetDuration().Round(time.Second).String(), true).
+			AddField("Peak Profit", alert.PeakProfit.String(), true)
+	}
+
+	_, err := notifier.client.CreateEmbeds([]discord.Embed{embed.Build()}, rest.WithCtx(ctx))
+	return err
+}
@@ -0,0 +1,158 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+type route struct {
+	notifier  domain.Notifier
+	pairs     []string // notified pairs, all when empty
+	minProfit decimal.Decimal
+}
+
+// Router sends each opportunity to the notifiers whose routing rules match it.
+type Router struct {
+	routes []route
+}
+
+// CreateRouter creates the notifiers configured in Notifiers, plus a Discord notifier without
+// routing rules for the legacy Discord.WebhookUrl setting.
+func CreateRouter(config *config.Config) (router *Router, err error) {
+	router = &Router{}
+
+	if config.Discord.WebhookUrl != "" {
+		discord, err := CreateDiscordNotifier(config.Discord.WebhookUrl)
+		if err != nil {
+			return nil, err
+		}
+		router.Add(discord, nil, decimal.Zero())
+	}
+
+	for i, notifierConfig := range config.Notifiers {
+		notifierType, err := domain.ParseNotifierType(notifierConfig.Type)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d: %w", i, err)
+		}
+
+		var notifier domain.Notifier
+		switch notifierType {
+		case domain.Discord:
+			notifier, err = CreateDiscordNotifier(notifierConfig.Url)
+		case domain.Telegram:
+			notifier = CreateTelegramNotifier(notifierConfig.BotToken, notifierConfig.ChatId)
+		case domain.Slack:
+			notifier = CreateSlackNotifier(notifierConfig.Url)
+		case domain.Webhook:
+			notifier = CreateWebhookNotifier(notifierConfig.Url, notifierConfig.Secret)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d: %w", i, err)
+		}
+
+		router.Add(notifier, notifierConfig.Pairs, notifierConfig.MinProfit)
+	}
+
+	return router, nil
+}
+
+// Add routes opportunities of the given pairs (all when empty) with at least minProfit net profit
+// to the notifier.
+func (router *Router) Add(notifier domain.Notifier, pairs []string, minProfit decimal.Decimal) {
+	router.routes = append(router.routes, route{notifier: notifier, pairs: pairs, minProfit: minProfit})
+}
+
+func (router *Router) GetName() string {
+	return "Router"
+}
+
+// Notify sends the alert to every matching notifier, continuing past failures, and returns the
+// errors of those that failed.
+func (router *Router) Notify(ctx context.Context, alert domain.Alert) error {
+	errs := make([]error, 0)
+	for _, route := range router.routes {
+		if !route.matches(alert) {
+			continue
+		}
+		if err := route.notifier.Notify(ctx, alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", route.notifier.GetName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matches compares the peak profit with minProfit, so a notifier that received an opportunity's
+// open alert also receives its updates and close.
+func (route route) matches(alert domain.Alert) bool {
+	if len(route.pairs) > 0 && !slices.Contains(route.pairs, alert.Opportunity.Pair) {
+		return false
+	}
+	return alert.PeakProfit.Cmp(route.minProfit) >= 0
+}
+
+// alertTitle names the alert's transition.
+func alertTitle(alert domain.Alert) string {
+	switch alert.Type {
+	case domain.OpportunityUpdated:
+		return "Arbitrage opportunity updated"
+	case domain.OpportunityClosed:
+		return "Arbitrage opportunity closed"
+	default:
+		return "Arbitrage opportunity found"
+	}
+}
+
+// formatAlert renders the alert as plain text for chat services.
+func formatAlert(alert domain.Alert) string {
+	opportunity := alert.Opportunity
+
+	var text strings.Builder
+	text.WriteString(alertTitle(alert) + ": " + opportunity.Pair + "\n")
+	text.WriteString("Buy " + opportunity.BuyVolume.String() + " on " + opportunity.BuyOn + " at " + opportunity.BuyPrice.String() + " (total " + opportunity.TotalBuyPrice.String() + ", fee " + opportunity.BuyFee.String() + ")\n")
+	text.WriteString("Sell " + opportunity.SellVolume.String() + " on " + opportunity.SellOn + " at " + opportunity.SellPrice.String() + " (total " + opportunity.TotalSellPrice.String() + ", fee " + opportunity.SellFee.String() + ")\n")
+	text.WriteString("Net profit: " + opportunity.NetProfit.String())
+	if alert.Type == domain.OpportunityClosed {
+		text.WriteString("\nOpen for " + alert.GetDuration().Round(time.Second).String() + ", peak profit " + alert.PeakProfit.String())
+	}
+	return text.String()
+}
+
+// postJson posts the body and returns the response body, failing on non-2xx statuses.
+func postJson(ctx context.Context, url string, body []byte, headers map[string]string) (responseBody []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseBody, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(responseBody))
+	}
+	return responseBody, nil
+}
@@ -0,0 +1,250 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/disgo/webhook"
+	"github.com/luno/luno-go/decimal"
+)
+
+type request struct {
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+// newStub records the requests it receives and answers them with the given status and body.
+func newStub(t *testing.T, status int, response string) (*httptest.Server, chan request) {
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{path: r.URL.Path, headers: r.Header, body: body}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func testAlert() domain.Alert {
+	return domain.Alert{
+		Type: domain.OpportunityOpened,
+		Opportunity: domain.ArbitrageOpportunity{
+			Pair:      "SOLMYR",
+			BuyOn:     "Hata",
+			SellOn:    "Luno",
+			BuyPrice:  decimal.NewFromInt64(1040),
+			BuyVolume: decimal.NewFromInt64(1),
+			SellPrice: decimal.NewFromInt64(1050),
+			NetProfit: decimal.NewFromInt64(5),
+		},
+		PeakProfit: decimal.NewFromInt64(5),
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	server, requests := newStub(t, http.StatusOK, `{"ok": true}`)
+	notifier := CreateTelegramNotifier("token", "42")
+	notifier.apiBaseUrl = server.URL
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	received := <-requests
+	var message telegramSendMessageRequest
+	if err := json.Unmarshal(received.body, &message); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if received.path != "/bottoken/sendMessage" || message.ChatId != "42" || !strings.Contains(message.Text, "Net profit: 5") {
+		t.Errorf("unexpected request %s %+v", received.path, message)
+	}
+}
+
+func TestTelegramNotifierRejected(t *testing.T) {
+	server, _ := newStub(t, http.StatusOK, `{"ok": false, "description": "chat not found"}`)
+	notifier := CreateTelegramNotifier("token", "42")
+	notifier.apiBaseUrl = server.URL
+
+	if err := notifier.Notify(context.Background(), testAlert()); err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected the telegram error; got %v", err)
+	}
+}
+
+func TestTelegramNotifierRedactsToken(t *testing.T) {
+	server, _ := newStub(t, http.StatusOK, `{"ok": true}`)
+	server.Close()
+	notifier := CreateTelegramNotifier("123:secret", "42")
+	notifier.apiBaseUrl = server.URL
+
+	err := notifier.Notify(context.Background(), testAlert())
+	if err == nil {
+		t.Fatal("expected the connection error")
+	}
+	if strings.Contains(err.Error(), "123:secret") || !strings.Contains(err.Error(), "/bot<redacted>/sendMessage") {
+		t.Errorf("expected the bot token redacted; got %v", err)
+	}
+}
+
+func TestSlackNotifierReportsClose(t *testing.T) {
+	server, requests := newStub(t, http.StatusOK, "ok")
+	alert := testAlert()
+	alert.Type = domain.OpportunityClosed
+	alert.OpenedAt = time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	alert.AlertedAt = alert.OpenedAt.Add(90 * time.Second)
+	alert.PeakProfit = decimal.NewFromInt64(8)
+
+	if err := CreateSlackNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	received := <-requests
+	var message slackMessage
+	if err := json.Unmarshal(received.body, &message); err != nil || !strings.Contains(message.Text, "Arbitrage opportunity closed") || !strings.Contains(message.Text, "Open for 1m30s, peak profit 8") {
+		t.Errorf("unexpected slack message %s", received.body)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server, requests := newStub(t, http.StatusOK, "ok")
+
+	if err := CreateSlackNotifier(server.URL+"/services/hook").Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	received := <-requests
+	var message slackMessage
+	if err := json.Unmarshal(received.body, &message); err != nil || !strings.Contains(message.Text, "Buy 1 on Hata at 1040") {
+		t.Errorf("unexpected slack message %s", received.body)
+	}
+}
+
+func TestWebhookNotifierSignsBody(t *testing.T) {
+	server, requests := newStub(t, http.StatusNoContent, "")
+
+	if err := CreateWebhookNotifier(server.URL, "secret").Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	received := <-requests
+	if signature := received.headers.Get(SignatureHeader); signature != Sign(received.body, "secret") || !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("unexpected signature %q", signature)
+	}
+	var alert domain.Alert
+	if err := json.Unmarshal(received.body, &alert); err != nil || alert.Type != domain.OpportunityOpened || alert.Opportunity.NetProfit.String() != "5" {
+		t.Errorf("unexpected webhook body %s", received.body)
+	}
+}
+
+func TestWebhookNotifierFailsOnErrorStatus(t *testing.T) {
+	server, _ := newStub(t, http.StatusInternalServerError, "boom")
+
+	if err := CreateWebhookNotifier(server.URL, "").Notify(context.Background(), testAlert()); err == nil {
+		t.Error("expected an error for a 500 response")
+	}
+}
+
+func TestDiscordNotifier(t *testing.T) {
+	server, requests := newStub(t, http.StatusOK, `{"id": "1"}`)
+	notifier, err := CreateDiscordNotifier("https://discord.com/api/webhooks/123/token", webhook.WithRestClientConfigOpts(rest.WithURL(server.URL)))
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+
+	if err := notifier.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	received := <-requests
+	if received.path != "/webhooks/123/token" || !strings.Contains(string(received.body), `"Net Profit"`) {
+		t.Errorf("unexpected request %s %s", received.path, received.body)
+	}
+}
+
+type recordingNotifier struct {
+	name     string
+	notified []string
+	err      error
+}
+
+func (notifier *recordingNotifier) GetName() string {
+	return notifier.name
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, alert domain.Alert) error {
+	notifier.notified = append(notifier.notified, alert.Opportunity.Pair)
+	return notifier.err
+}
+
+func TestRouterRoutesByPairAndProfit(t *testing.T) {
+	all := &recordingNotifier{name: "all"}
+	sol := &recordingNotifier{name: "sol"}
+	bigProfit := &recordingNotifier{name: "big", err: errors.New("unavailable")}
+
+	router := &Router{}
+	router.Add(all, nil, decimal.Zero())
+	router.Add(sol, []string{"SOLMYR"}, decimal.Zero())
+	router.Add(bigProfit, nil, decimal.NewFromInt64(10))
+
+	solmyr := testAlert()
+	avaxmyr := testAlert()
+	avaxmyr.Type = domain.OpportunityClosed
+	avaxmyr.Opportunity.Pair = "AVAXMYR"
+	avaxmyr.Opportunity.NetProfit = decimal.NewFromInt64(1)
+	avaxmyr.PeakProfit = decimal.NewFromInt64(20)
+
+	if err := router.Notify(context.Background(), solmyr); err != nil {
+		t.Errorf("expected no error; got %v", err)
+	}
+	if err := router.Notify(context.Background(), avaxmyr); err == nil || !strings.Contains(err.Error(), "big: unavailable") {
+		t.Errorf("expected the failing notifier's error; got %v", err)
+	}
+
+	if len(all.notified) != 2 || len(sol.notified) != 1 || sol.notified[0] != "SOLMYR" || len(bigProfit.notified) != 1 || bigProfit.notified[0] != "AVAXMYR" {
+		t.Errorf("unexpected routing: all %v, sol %v, big %v", all.notified, sol.notified, bigProfit.notified)
+	}
+}
+
+func TestCreateRouterFromConfig(t *testing.T) {
+	var cfg config.Config
+	err := json.Unmarshal([]byte(`{
+		"Discord": {"WebhookUrl": "https://discord.com/api/webhooks/123/token"},
+		"Notifiers": [
+			{"Type": "Telegram", "BotToken": "token", "ChatId": "42", "Pairs": ["SOLMYR"]},
+			{"Type": "Slack", "Url": "https://hooks.slack.com/services/x", "MinProfit": 10},
+			{"Type": "Webhook", "Url": "https://example.com/alerts", "Secret": "secret"}
+		]
+	}`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	router, err := CreateRouter(&cfg)
+	if err != nil {
+		t.Fatalf("failed to create router: %v", err)
+	}
+	names := make([]string, 0)
+	for _, route := range router.routes {
+		names = append(names, route.notifier.GetName())
+	}
+	if strings.Join(names, ",") != "Discord,Telegram,Slack,Webhook" || router.routes[2].minProfit.String() != "10" {
+		t.Errorf("unexpected routes %v", names)
+	}
+
+	cfg.Notifiers[0].Type = "Pager"
+	if _, err := CreateRouter(&cfg); err == nil {
+		t.Error("expected an unknown notifier type to be rejected")
+	}
+}
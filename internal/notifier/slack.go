@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+)
+
+// SlackNotifier posts opportunities to a Slack incoming webhook.
+type SlackNotifier struct {
+	webhookUrl string
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func CreateSlackNotifier(webhookUrl string) *SlackNotifier {
+	return &SlackNotifier{webhookUrl: webhookUrl}
+}
+
+func (notifier *SlackNotifier) GetName() string {
+	return domain.Slack.String()
+}
+
+func (notifier *SlackNotifier) Notify(ctx context.Context, alert domain.Alert) error {
+	body, err := json.Marshal(slackMessage{Text: formatAlert(alert)})
+	if err != nil {
+		return err
+	}
+
+	_, err = postJson(ctx, notifier.webhookUrl, body, nil)
+	return err
+}
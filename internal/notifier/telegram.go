@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"net/url"
+	"strings"
+)
+
+const telegramApiBaseUrl = "https://api.telegram.org"
+
+// TelegramNotifier sends opportunities as messages from a bot to a chat.
+type TelegramNotifier struct {
+	apiBaseUrl string
+	botToken   string
+	chatId     string
+}
+
+type telegramSendMessageRequest struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func CreateTelegramNotifier(botToken string, chatId string) *TelegramNotifier {
+	return &TelegramNotifier{apiBaseUrl: telegramApiBaseUrl, botToken: botToken, chatId: chatId}
+}
+
+func (notifier *TelegramNotifier) GetName() string {
+	return domain.Telegram.String()
+}
+
+func (notifier *TelegramNotifier) Notify(ctx context.Context, alert domain.Alert) error {
+	body, err := json.Marshal(telegramSendMessageRequest{ChatId: notifier.chatId, Text: formatAlert(alert)})
+	if err != nil {
+		return err
+	}
+
+	responseBody, err := postJson(ctx, notifier.apiBaseUrl+"/bot"+notifier.botToken+"/sendMessage", body, nil)
+	if err != nil {
+		return notifier.redact(err)
+	}
+
+	var response telegramResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return err
+	}
+	if !response.Ok {
+		return errors.New("telegram rejected the message: " + response.Description)
+	}
+	return nil
+}
+
+// redact hides the bot token from the request URL that transport errors quote, so failed alerts can be
+// logged without leaking it.
+func (notifier *TelegramNotifier) redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = strings.ReplaceAll(urlErr.URL, notifier.botToken, "<redacted>")
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed with the webhook secret
+// and prefixed with "sha256=".
+const SignatureHeader = "X-Signature-256"
+
+// WebhookNotifier posts alerts as JSON to any URL. When a secret is set, receivers can
+// verify the body with the signature in SignatureHeader.
+type WebhookNotifier struct {
+	url    string
+	secret string
+}
+
+func CreateWebhookNotifier(url string, secret string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret}
+}
+
+func (notifier *WebhookNotifier) GetName() string {
+	return domain.Webhook.String()
+}
+
+func (notifier *WebhookNotifier) Notify(ctx context.Context, alert domain.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string)
+	if notifier.secret != "" {
+		headers[SignatureHeader] = Sign(body, notifier.secret)
+	}
+
+	_, err = postJson(ctx, notifier.url, body, headers)
+	return err
+}
+
+// Sign returns the SignatureHeader value of the body.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
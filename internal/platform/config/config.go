@@ -2,54 +2,159 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"malaysia-crypto-exchange-arbitrage/internal/domain"
 	"os"
 	"sync"
+
+	"github.com/luno/luno-go/decimal"
 )
 
 type Config struct {
 	Market map[string]struct {
 		Enabled      bool
-		MaxPriceDiff float32
+		MaxPriceDiff decimal.Decimal
+		Symbols      map[string]string // exchange => pair traded there instead when it lists the base in another quote currency, e.g. Hata => SOLUSDT
 	}
 
 	Arbitrage map[string]struct {
-		MinProfit    float32
-		SlippageMode domain.SlippageDetectionModeEnum
-		Slippage     float32 //percentage
+		AlertPolicy         domain.AlertPolicyModeEnum // which of the profit thresholds below must be met to alert
+		MinProfit           decimal.Decimal            // minimum net profit in the quote currency
+		MinProfitPercentage decimal.Decimal            // minimum net profit as a percentage of the capital spent buying
+		SlippageMode        domain.SlippageDetectionModeEnum
+		Slippage            decimal.Decimal          //percentage
+		MaxCapital          decimal.Decimal          // maximum quote currency spent per trade on this pair, 0 for no pair limit
+		Mode                domain.ArbitrageModeEnum // Transfer moves the coins between exchanges, Inventory trades both legs from balances held on each exchange
+	}
+
+	MaxCapital decimal.Decimal // maximum quote currency spent per trade across all pairs, 0 for no global limit
+
+	MaxOrderBookSkewSeconds float64 // opportunities whose buy and sell books were taken further apart are flagged Skewed and not alerted, 0 for no limit
+
+	Triangular map[string]struct { // exchange => triangular arbitrage within that exchange
+		Enabled       bool
+		Pairs         []string        // order books polled to discover cycles, e.g. USDTMYR, SOLUSDT and SOLMYR
+		StartCurrency string          // currency every cycle starts and ends in, MYR when empty
+		Capital       decimal.Decimal // start currency spent per cycle, MaxCapital when 0
+		MinProfit     decimal.Decimal // minimum net profit in the start currency to publish a cycle
 	}
 
 	Exchange map[string]struct {
-		Enabled   bool
-		ApiKey    string
-		ApiSecret string
-		MakerFee  float32
-		TakerFee  float32
-		Crypto    map[string]struct {
+		Enabled                bool
+		ApiKey                 string
+		ApiSecret              string
+		MakerFee               decimal.Decimal
+		TakerFee               decimal.Decimal
+		MaxCapital             decimal.Decimal // maximum quote currency spent per trade buying on this exchange, 0 for no exchange limit
+		RequestsPerSecond      float64         // REST request rate limit, httpclient.DefaultOptions when 0
+		MaxRetries             int             // REST retries of idempotent requests on network errors, 429 and 5xx responses, httpclient.DefaultOptions when 0
+		MaxOrderBookAgeSeconds float64         // order books older than this are left out of the analysis, 0 for no limit
+		Crypto                 map[string]struct {
 			Address           string
 			Memo              string
-			WithdrawFee       float32
-			WithdrawMinAmount float32
-			DepositMinAmount  float32
+			Network           string
+			WithdrawFee       decimal.Decimal
+			WithdrawMinAmount decimal.Decimal
+			DepositMinAmount  decimal.Decimal
 		}
 	}
 
+	Precision map[string]int // asset => decimal places the exchanges settle amounts in, e.g. MYR => 2
+
+	Fx struct {
+		Exchange string                     // exchange whose order books price quote currency conversions, e.g. Luno for USDTMYR; static Rates only when empty
+		Rates    map[string]decimal.Decimal // static mid rates by pair, e.g. USDTMYR => 4.45, used when the exchange has no book for the pair
+		Fee      decimal.Decimal            // fee of a static rate conversion, book conversions pay the exchange's taker fee
+	}
+
 	Discord struct {
-		WebhookUrl string
+		WebhookUrl string // alerted for every opportunity, kept alongside Notifiers for older configs
+	}
+
+	Alerts struct {
+		CooldownSeconds        int             // minimum time between open or update alerts of the same pair and exchanges
+		ProfitChangePercentage decimal.Decimal // alert an open opportunity again when its net profit moved this much since the last alert, 0 to disable
+	}
+
+	CircuitBreaker struct {
+		FailureThreshold int // consecutive order book fetch errors leaving an exchange out of the analysis, 0 to disable
+		CooldownSeconds  int // how long an exchange is left out before it is probed again
+	}
+
+	History struct {
+		SaveIntervalSeconds float64 // minimum time between stored analyses of a route whose profitability did not change, 60 when 0
+		RetentionDays       int     // stored opportunities older than this are deleted, 30 when 0
+	}
+
+	Notifiers []struct {
+		Type      string          // Discord, Telegram, Slack or Webhook
+		Url       string          // Discord, Slack or generic webhook URL
+		BotToken  string          // Telegram bot token
+		ChatId    string          // Telegram chat receiving the alerts
+		Secret    string          // generic webhook HMAC-SHA256 signing key, unsigned when empty
+		Pairs     []string        // only alert these pairs, all when empty
+		MinProfit decimal.Decimal // only alert opportunities with at least this net profit
+	}
+
+	Trading struct {
+		Enabled  bool            // execute alerted opportunities
+		Paper    bool            // simulate execution instead of trading real funds
+		Balances domain.Balances // paper trading starting balances, and the Inventory mode balances of exchanges that cannot trade
+	}
+
+	Rebalance struct {
+		Enabled         bool
+		IntervalSeconds int                                   // how often balances are compared against the targets
+		Targets         map[string]map[string]decimal.Decimal // exchange => currency => target share of the currency held across exchanges, e.g. Luno => SOL => 0.5
+		Tolerance       decimal.Decimal                       // share of the total an exchange may drift from its target before transfers are proposed
 	}
 }
 
+// GetScale returns the number of decimal places amounts of the asset are rounded to.
+func (config *Config) GetScale(asset string) int {
+	if scale, ok := config.Precision[asset]; ok {
+		return scale
+	}
+	return domain.DefaultScale
+}
+
 var once sync.Once
 var config *Config
 
+// GetConfig returns the application config, loaded once from DefaultPath.
 func GetConfig() *Config {
 	once.Do(func() {
-		configBytes, err := os.ReadFile("config.json")
+		var err error
+		config, err = Load(DefaultPath())
 		if err != nil {
 			panic(err)
 		}
-		json.Unmarshal(configBytes, &config)
 	})
 
 	return config
 }
+
+// DefaultPath returns the file named by the CONFIG_PATH environment variable, or config.json in the
+// working directory.
+func DefaultPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return "config.json"
+}
+
+// Load reads a config file without touching the shared config returned by GetConfig.
+func Load(path string) (*Config, error) {
+	configBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *Config
+	err = json.Unmarshal(configBytes, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return config, nil
+}
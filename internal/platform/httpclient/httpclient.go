@@ -0,0 +1,253 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Options of an exchange's HTTP client.
+type Options struct {
+	RequestsPerSecond float64       // sustained request rate, 0 for no limit
+	Burst             int           // requests allowed at once before the rate applies
+	Timeout           time.Duration // per attempt, including reading the response body
+	MaxRetries        int           // attempts after the first of an idempotent request on a network error, 429 or 5xx
+	MinBackoff        time.Duration // backoff before the first retry, doubled on every retry
+	MaxBackoff        time.Duration
+}
+
+var DefaultOptions = Options{
+	RequestsPerSecond: 5,
+	Burst:             5,
+	Timeout:           10 * time.Second,
+	MaxRetries:        3,
+	MinBackoff:        250 * time.Millisecond,
+	MaxBackoff:        5 * time.Second,
+}
+
+// Connections are pooled across every exchange client.
+var sharedTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// Transport is an http.RoundTripper throttling an exchange's requests with a token bucket, timing
+// out each attempt and retrying idempotent requests on network errors, 429 and 5xx responses with
+// jittered exponential backoff. Every call is recorded in the exchange's metrics.
+type Transport struct {
+	exchange string
+	base     http.RoundTripper
+	limiter  *rate.Limiter
+	options  Options
+}
+
+// NewClient returns an HTTP client for the exchange's adapter, sending its requests through a Transport.
+func NewClient(exchange string, options Options) *http.Client {
+	return &http.Client{Transport: NewTransport(exchange, options, sharedTransport)}
+}
+
+func NewTransport(exchange string, options Options, base http.RoundTripper) *Transport {
+	limit := rate.Inf
+	if options.RequestsPerSecond > 0 {
+		limit = rate.Limit(options.RequestsPerSecond)
+	}
+	return &Transport{
+		exchange: exchange,
+		base:     base,
+		limiter:  rate.NewLimiter(limit, max(options.Burst, 1)),
+		options:  options,
+	}
+}
+
+// RoundTrip sends the request, waiting for the rate limit and retrying as configured. The returned
+// response body is already read, so it stays readable once the attempt's timeout has passed.
+func (transport *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	ctx := req.Context()
+	started := time.Now()
+	call := callRecord{exchange: transport.exchange, endpoint: req.URL.Path}
+	defer func() {
+		call.latency = time.Since(started)
+		call.err = err
+		if resp != nil {
+			call.status = resp.StatusCode
+		}
+		record(call)
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if err = transport.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err = transport.attempt(ctx, req, attempt)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			call.rateLimited++
+		}
+		if attempt >= transport.options.MaxRetries || !idempotent(req) || !rewindable(req) || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		backoff := transport.backoff(attempt, resp)
+		call.retries++
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (transport *Transport) attempt(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	if transport.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, transport.options.Timeout)
+		defer cancel()
+	}
+
+	attemptReq := req.WithContext(ctx)
+	if attempt > 0 && req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err := transport.base.RoundTrip(attemptReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// idempotent reports whether the request may be sent again without repeating its effect: GET, HEAD,
+// OPTIONS and TRACE requests, and others opting in with an Idempotency-Key header the exchange
+// deduplicates on. Orders and withdrawals are never placed twice by a retry.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// rewindable reports whether the request body can be sent again: requests without a body, and those
+// whose GetBody returns a fresh copy, as set by http.NewRequest for in-memory readers. Any other body
+// was consumed by the first attempt, so a retry would send it empty.
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryable reports whether a failed attempt may succeed when repeated: network errors other than
+// the caller giving up, rate limiting and server errors.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// backoff returns a random wait up to MinBackoff doubled per attempt, capped at MaxBackoff. A 429
+// response's Retry-After is honoured up to MaxBackoff.
+func (transport *Transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			return min(time.Duration(seconds)*time.Second, transport.options.MaxBackoff)
+		}
+	}
+
+	ceiling := transport.options.MinBackoff << attempt
+	if ceiling <= 0 || ceiling > transport.options.MaxBackoff {
+		ceiling = transport.options.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return transport.options.MinBackoff/2 + time.Duration(rand.Int63n(int64(ceiling)))/2
+}
+
+// EndpointMetrics summarizes the calls made to one endpoint of an exchange.
+type EndpointMetrics struct {
+	Calls          int64
+	Failures       int64 // calls ending in an error or a non 2xx response after every retry
+	Retries        int64
+	RateLimited    int64  // 429 responses received
+	LastStatus     int    `json:",omitempty"`
+	LastError      string `json:",omitempty"`
+	LastCallAt     time.Time
+	AverageLatency time.Duration // including rate limit waits and retries
+	MaxLatency     time.Duration
+	totalLatency   time.Duration
+}
+
+type callRecord struct {
+	exchange    string
+	endpoint    string
+	status      int
+	err         error
+	retries     int64
+	rateLimited int64
+	latency     time.Duration
+}
+
+var metrics = struct {
+	sync.Mutex
+	endpoints map[string]map[string]*EndpointMetrics // exchange => endpoint path => metrics
+}{endpoints: make(map[string]map[string]*EndpointMetrics)}
+
+func record(call callRecord) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	if metrics.endpoints[call.exchange] == nil {
+		metrics.endpoints[call.exchange] = make(map[string]*EndpointMetrics)
+	}
+	endpoint := metrics.endpoints[call.exchange][call.endpoint]
+	if endpoint == nil {
+		endpoint = &EndpointMetrics{}
+		metrics.endpoints[call.exchange][call.endpoint] = endpoint
+	}
+
+	endpoint.Calls++
+	endpoint.Retries += call.retries
+	endpoint.RateLimited += call.rateLimited
+	endpoint.LastStatus = call.status
+	endpoint.LastError = ""
+	if call.err != nil {
+		endpoint.LastError = call.err.Error()
+	}
+	if call.err != nil || call.status < 200 || call.status > 299 {
+		endpoint.Failures++
+	}
+	endpoint.LastCallAt = time.Now()
+	endpoint.totalLatency += call.latency
+	endpoint.AverageLatency = endpoint.totalLatency / time.Duration(endpoint.Calls)
+	endpoint.MaxLatency = max(endpoint.MaxLatency, call.latency)
+}
+
+// GetMetrics returns a copy of the metrics of every endpoint called by the exchange, by path.
+func GetMetrics(exchange string) map[string]EndpointMetrics {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	endpoints := make(map[string]EndpointMetrics, len(metrics.endpoints[exchange]))
+	for path, endpoint := range metrics.endpoints[exchange] {
+		endpoints[path] = *endpoint
+	}
+	return endpoints
+}
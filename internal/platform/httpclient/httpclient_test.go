@@ -0,0 +1,189 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testOptions = Options{
+	Timeout:    time.Second,
+	MaxRetries: 2,
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 5 * time.Millisecond,
+}
+
+// newServer responds with the statuses in order, repeating the last one, and counts the requests.
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(&calls, 1))
+		status := statuses[min(call, len(statuses))-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "60")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	server, calls := newServer(t, http.StatusServiceUnavailable, http.StatusOK)
+	client := NewClient("TestRetries", testOptions)
+
+	resp, err := client.Get(server.URL + "/orderbook")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "OK" {
+		t.Fatalf("expected the successful retry, got %d %q", resp.StatusCode, body)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected 2 requests, got %d", *calls)
+	}
+
+	metrics := GetMetrics("TestRetries")["/orderbook"]
+	if metrics.Calls != 1 || metrics.Retries != 1 || metrics.Failures != 0 || metrics.LastStatus != http.StatusOK {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestRetryAfterIsCappedAtMaxBackoff(t *testing.T) {
+	server, calls := newServer(t, http.StatusTooManyRequests)
+	client := NewClient("TestRateLimited", testOptions)
+
+	started := time.Now()
+	resp, err := client.Get(server.URL + "/orderbook")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the last 429, got %d", resp.StatusCode)
+	}
+	if *calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", *calls)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected Retry-After capped at MaxBackoff, took %v", elapsed)
+	}
+
+	metrics := GetMetrics("TestRateLimited")["/orderbook"]
+	if metrics.RateLimited != 3 || metrics.Retries != 2 || metrics.Failures != 1 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newServer(t, http.StatusBadRequest)
+	client := NewClient("TestClientError", testOptions)
+
+	resp, err := client.Get(server.URL + "/orderbook")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest || *calls != 1 {
+		t.Fatalf("expected a single 400, got %d after %d requests", resp.StatusCode, *calls)
+	}
+}
+
+func TestRetriesPostBodyWithIdempotencyKey(t *testing.T) {
+	var bodies []string
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("TestPost", testOptions)
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/order", strings.NewReader("pair=BTCMYR"))
+	req.Header.Set("Idempotency-Key", "order-1")
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] != "pair=BTCMYR" || bodies[1] != "pair=BTCMYR" {
+		t.Fatalf("expected the body resent, got %q", bodies)
+	}
+}
+
+func TestDoesNotRetryBodyThatCannotBeResent(t *testing.T) {
+	server, calls := newServer(t, http.StatusBadGateway, http.StatusOK)
+	client := NewClient("TestBodyNotResent", testOptions)
+
+	// A reader http.NewRequest cannot copy leaves GetBody unset
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/order", io.MultiReader(strings.NewReader("pair=BTCMYR")))
+	req.Header.Set("Idempotency-Key", "order-1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadGateway || atomic.LoadInt32(calls) != 1 {
+		t.Errorf("expected a single attempt, got %d after %d calls", resp.StatusCode, atomic.LoadInt32(calls))
+	}
+}
+
+func TestDoesNotRetryPost(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusTooManyRequests} {
+		server, calls := newServer(t, status)
+		client := NewClient("TestPostNotRetried", testOptions)
+
+		resp, err := client.Post(server.URL+"/order", "text/plain", strings.NewReader("pair=BTCMYR"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != status || atomic.LoadInt32(calls) != 1 {
+			t.Errorf("expected a single %d attempt, got %d after %d calls", status, resp.StatusCode, atomic.LoadInt32(calls))
+		}
+	}
+}
+
+func TestStopsWhenContextIsCancelled(t *testing.T) {
+	server, _ := newServer(t, http.StatusServiceUnavailable)
+	options := testOptions
+	options.MaxRetries = 10
+	options.MinBackoff, options.MaxBackoff = time.Second, time.Second
+	client := NewClient("TestCancelled", options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/orderbook", nil)
+
+	started := time.Now()
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the backoff to stop with the context, took %v", elapsed)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server, _ := newServer(t, http.StatusOK)
+	options := testOptions
+	options.RequestsPerSecond, options.Burst = 20, 1
+	client := NewClient("TestRateLimit", options)
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(server.URL + "/orderbook"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// The first request uses the burst, the next two wait 50ms each
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected requests throttled to 20 per second, took %v", elapsed)
+	}
+}
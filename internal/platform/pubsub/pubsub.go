@@ -0,0 +1,60 @@
+package pubsub
+
+import "sync"
+
+// Broker fans published messages out to every current subscriber. Publishing never blocks: a
+// subscriber whose buffer is full misses the message, so one slow reader cannot stall the publisher.
+type Broker[T any] struct {
+	subscribers map[*Subscription[T]]struct{}
+	mutex       sync.RWMutex
+}
+
+type Subscription[T any] struct {
+	C      <-chan T
+	ch     chan T
+	broker *Broker[T]
+	once   sync.Once
+}
+
+func New[T any]() *Broker[T] {
+	return &Broker[T]{subscribers: make(map[*Subscription[T]]struct{})}
+}
+
+// Subscribe registers a subscriber receiving messages published from now on on its C channel.
+func (broker *Broker[T]) Subscribe(buffer int) *Subscription[T] {
+	ch := make(chan T, buffer)
+	subscription := &Subscription[T]{C: ch, ch: ch, broker: broker}
+
+	broker.mutex.Lock()
+	broker.subscribers[subscription] = struct{}{}
+	broker.mutex.Unlock()
+
+	return subscription
+}
+
+// Publish delivers the message to every subscriber with room in its buffer and returns how many
+// subscribers dropped it.
+func (broker *Broker[T]) Publish(message T) (dropped int) {
+	broker.mutex.RLock()
+	defer broker.mutex.RUnlock()
+
+	for subscription := range broker.subscribers {
+		select {
+		case subscription.ch <- message:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (subscription *Subscription[T]) Close() {
+	subscription.once.Do(func() {
+		subscription.broker.mutex.Lock()
+		delete(subscription.broker.subscribers, subscription)
+		subscription.broker.mutex.Unlock()
+
+		close(subscription.ch)
+	})
+}
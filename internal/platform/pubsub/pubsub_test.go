@@ -0,0 +1,49 @@
+package pubsub
+
+import "testing"
+
+func TestPublishFansOut(t *testing.T) {
+	broker := New[int]()
+	first := broker.Subscribe(1)
+	second := broker.Subscribe(1)
+	defer first.Close()
+	defer second.Close()
+
+	if dropped := broker.Publish(7); dropped != 0 {
+		t.Fatalf("expected no drops; got %d", dropped)
+	}
+	if got := <-first.C; got != 7 {
+		t.Errorf("first subscriber got %d; want 7", got)
+	}
+	if got := <-second.C; got != 7 {
+		t.Errorf("second subscriber got %d; want 7", got)
+	}
+}
+
+func TestPublishDropsWhenBufferFull(t *testing.T) {
+	broker := New[int]()
+	subscription := broker.Subscribe(1)
+	defer subscription.Close()
+
+	broker.Publish(1)
+	if dropped := broker.Publish(2); dropped != 1 {
+		t.Fatalf("expected the second message to be dropped; got %d drops", dropped)
+	}
+	if got := <-subscription.C; got != 1 {
+		t.Errorf("got %d; want the first message", got)
+	}
+}
+
+func TestCloseUnsubscribes(t *testing.T) {
+	broker := New[int]()
+	subscription := broker.Subscribe(1)
+	subscription.Close()
+	subscription.Close()
+
+	if _, ok := <-subscription.C; ok {
+		t.Error("expected C to be closed")
+	}
+	if dropped := broker.Publish(1); dropped != 0 {
+		t.Errorf("expected a closed subscription to receive nothing; got %d drops", dropped)
+	}
+}
@@ -0,0 +1,266 @@
+package rebalancer
+
+import (
+	"context"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/logger"
+	"slices"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+var Logger = logger.Get()
+
+// Rebalancer compares the exchange balances against their target allocations and proposes the
+// crypto transfers restoring them. With paper trading the transfers are simulated on the paper
+// exchanges; real funds are never moved, the plan is only logged.
+type Rebalancer struct {
+	config    *config.Config
+	exchanges map[string]domain.Exchanger
+	pairs     map[string]string // currency => pair used to transfer it
+	simulate  bool
+}
+
+// CreateRebalancer returns a rebalancer for the exchanges. Each currency is transferred through the
+// first of the pairs, in order, that has it as base currency.
+func CreateRebalancer(config *config.Config, exchanges map[string]domain.Exchanger, pairs []string) *Rebalancer {
+	rebalancer := &Rebalancer{
+		config:    config,
+		exchanges: exchanges,
+		pairs:     make(map[string]string),
+		simulate:  config.Trading.Enabled && config.Trading.Paper,
+	}
+	for _, pair := range pairs {
+		base, _, err := domain.SplitPair(pair)
+		if err != nil {
+			continue
+		}
+		if _, ok := rebalancer.pairs[base]; !ok {
+			rebalancer.pairs[base] = pair
+		}
+	}
+	return rebalancer
+}
+
+// Start rebalances every interval until ctx is cancelled.
+func (rebalancer *Rebalancer) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	Logger.Info("Start rebalancing every " + interval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			Logger.Info("Stop rebalancing")
+			return
+		case <-ticker.C:
+			rebalancer.Run(ctx)
+		}
+	}
+}
+
+// Run plans the transfers restoring the targets, logs them and simulates them when paper trading.
+func (rebalancer *Rebalancer) Run(ctx context.Context) {
+	plan := rebalancer.Plan(ctx)
+	for _, reason := range plan.Skipped {
+		Logger.Info("Rebalance skipped: " + reason)
+	}
+	if len(plan.Transfers) == 0 {
+		return
+	}
+
+	for _, transfer := range plan.Transfers {
+		Logger.Info(fmt.Sprintf("Rebalance proposed: %v %s from %s to %s, fee %v", transfer.Amount, transfer.Currency, transfer.From, transfer.To, transfer.Fee))
+	}
+	if !rebalancer.simulate {
+		return
+	}
+
+	if _, err := rebalancer.Simulate(ctx, plan); err != nil {
+		Logger.Error("Failed to simulate rebalance: " + err.Error())
+	}
+}
+
+// Plan proposes the transfers restoring the target allocation of every targeted currency. For each
+// exchange short of its target, starting with the largest shortfall, the surplus is taken from the
+// exchange with the lowest transfer fee per unit whose withdraw and deposit minimums allow it.
+func (rebalancer *Rebalancer) Plan(ctx context.Context) (plan domain.RebalancePlan) {
+	plan.CreatedAt = time.Now()
+
+	balances, err := domain.LoadBalances(ctx, rebalancer.exchanges, rebalancer.config.Trading.Balances)
+	if err != nil {
+		Logger.Error("Failed to get balances, using configured balances: " + err.Error())
+	}
+	plan.Balances = balances
+
+	currencies := make([]string, 0)
+	for exchange, targets := range rebalancer.config.Rebalance.Targets {
+		if _, ok := rebalancer.exchanges[exchange]; !ok {
+			continue
+		}
+		for currency := range targets {
+			if !slices.Contains(currencies, currency) {
+				currencies = append(currencies, currency)
+			}
+		}
+	}
+	slices.Sort(currencies)
+
+	for _, currency := range currencies {
+		rebalancer.planCurrency(ctx, currency, balances, &plan)
+	}
+
+	return plan
+}
+
+// allocation is an exchange's distance from its target: positive when it holds a surplus,
+// negative when it is short.
+type allocation struct {
+	exchange string
+	diff     decimal.Decimal
+}
+
+func (rebalancer *Rebalancer) planCurrency(ctx context.Context, currency string, balances domain.Balances, plan *domain.RebalancePlan) {
+	pair, ok := rebalancer.pairs[currency]
+	if !ok {
+		plan.Skipped = append(plan.Skipped, "no enabled pair to transfer "+currency)
+		return
+	}
+	scale := rebalancer.config.GetScale(currency)
+
+	exchanges := make([]string, 0)
+	total, totalShare := decimal.Zero(), decimal.Zero()
+	for exchange, targets := range rebalancer.config.Rebalance.Targets {
+		share, targeted := targets[currency]
+		_, enabled := rebalancer.exchanges[exchange]
+		if !targeted || !enabled || share.Sign() < 0 {
+			continue
+		}
+		exchanges = append(exchanges, exchange)
+		total = total.Add(balances.Get(exchange, currency))
+		totalShare = totalShare.Add(share)
+	}
+	if len(exchanges) < 2 || total.Sign() <= 0 || totalShare.Sign() <= 0 {
+		return
+	}
+	slices.Sort(exchanges)
+
+	threshold := total.Mul(rebalancer.config.Rebalance.Tolerance)
+	sources := make([]*allocation, 0)
+	destinations := make([]*allocation, 0)
+	for _, exchange := range exchanges {
+		share := rebalancer.config.Rebalance.Targets[exchange][currency]
+		target := domain.RoundDown(domain.Quo(total.Mul(share), totalShare, domain.DefaultScale), scale)
+		diff := balances.Get(exchange, currency).Sub(target)
+		if diff.Sign() > 0 {
+			sources = append(sources, &allocation{exchange: exchange, diff: diff})
+		} else if diff.Neg().Cmp(threshold) > 0 {
+			destinations = append(destinations, &allocation{exchange: exchange, diff: diff})
+		}
+	}
+	slices.SortStableFunc(destinations, func(a, b *allocation) int {
+		return a.diff.Cmp(b.diff) // most negative, the largest shortfall, first
+	})
+
+	for _, destination := range destinations {
+		address, err := rebalancer.exchanges[destination.exchange].GetDepositAddress(ctx, pair)
+		if err != nil || address == "" {
+			plan.Skipped = append(plan.Skipped, "no "+currency+" deposit address on "+destination.exchange)
+			continue
+		}
+
+		excluded := make(map[string]bool)
+		for domain.RoundDown(destination.diff.Neg(), scale).Sign() > 0 {
+			transfer, ok := rebalancer.cheapestTransfer(ctx, pair, currency, address, destination, sources, excluded, scale)
+			if !ok {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s short %v %s with no source able to transfer it", destination.exchange, destination.diff.Neg(), currency))
+				break
+			}
+			plan.Transfers = append(plan.Transfers, transfer)
+			destination.diff = destination.diff.Add(transfer.Amount)
+			for _, source := range sources {
+				if source.exchange == transfer.From {
+					source.diff = source.diff.Sub(transfer.Amount)
+				}
+			}
+		}
+	}
+}
+
+// cheapestTransfer returns the transfer towards destination with the lowest fee per unit among the
+// sources that still hold a surplus. Sources failing the fee lookup or the minimums are excluded
+// from further transfers to this destination.
+func (rebalancer *Rebalancer) cheapestTransfer(ctx context.Context, pair string, currency string, address string, destination *allocation, sources []*allocation, excluded map[string]bool, scale int) (cheapest domain.RebalanceTransfer, ok bool) {
+	for _, source := range sources {
+		if excluded[source.exchange] || source.diff.Sign() <= 0 {
+			continue
+		}
+		amount := domain.RoundDown(domain.MinDecimal(source.diff, destination.diff.Neg()), scale)
+		if amount.Sign() <= 0 {
+			excluded[source.exchange] = true
+			continue
+		}
+
+		exchange := rebalancer.exchanges[source.exchange]
+		fee, err := exchange.GetTransferFee(ctx, pair, address, amount)
+		if err != nil {
+			Logger.Error("Failed to get " + source.exchange + " transfer fee: " + err.Error())
+			excluded[source.exchange] = true
+			continue
+		}
+		if fee.Sign() < 0 {
+			fee = decimal.Zero()
+		}
+		withdrawMin, err := exchange.GetWithdrawMin(ctx, pair)
+		if err != nil || amount.Cmp(withdrawMin) < 0 {
+			excluded[source.exchange] = true
+			continue
+		}
+		received := amount.Sub(fee)
+		depositMin, err := rebalancer.exchanges[destination.exchange].GetDepositMin(ctx, pair)
+		if err != nil || received.Sign() <= 0 || received.Cmp(depositMin) < 0 {
+			excluded[source.exchange] = true
+			continue
+		}
+
+		// Compare fee per unit without dividing: fee / amount < cheapest.Fee / cheapest.Amount
+		if !ok || fee.Mul(cheapest.Amount).Cmp(cheapest.Fee.Mul(amount)) < 0 {
+			cheapest = domain.RebalanceTransfer{
+				Currency: currency,
+				Pair:     pair,
+				From:     source.exchange,
+				To:       destination.exchange,
+				Amount:   amount,
+				Fee:      fee,
+				Received: received,
+			}
+			ok = true
+		}
+	}
+	return cheapest, ok
+}
+
+// Simulate withdraws every transfer of the plan on the paper exchanges, recording the withdrawal
+// ids. It stops at the first transfer that fails.
+func (rebalancer *Rebalancer) Simulate(ctx context.Context, plan domain.RebalancePlan) (domain.RebalancePlan, error) {
+	for i, transfer := range plan.Transfers {
+		trader, ok := rebalancer.exchanges[transfer.From].(domain.Trader)
+		if !ok {
+			return plan, fmt.Errorf("%s cannot withdraw", transfer.From)
+		}
+		address, err := rebalancer.exchanges[transfer.To].GetDepositAddress(ctx, transfer.Pair)
+		if err != nil {
+			return plan, fmt.Errorf("deposit address on %s: %w", transfer.To, err)
+		}
+
+		plan.Transfers[i].WithdrawalId, err = trader.Withdraw(ctx, transfer.Pair, address, transfer.Amount)
+		if err != nil {
+			return plan, fmt.Errorf("withdraw %v %s from %s: %w", transfer.Amount, transfer.Currency, transfer.From, err)
+		}
+	}
+	return plan, nil
+}
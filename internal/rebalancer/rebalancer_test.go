@@ -0,0 +1,127 @@
+package rebalancer
+
+import (
+	"context"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/exchange/paper"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+type stubExchange struct {
+	domain.Exchanger
+	name        string
+	transferFee decimal.Decimal
+	withdrawMin decimal.Decimal
+}
+
+func (exchange *stubExchange) GetName() string { return exchange.name }
+
+func (exchange *stubExchange) GetTransferFee(ctx context.Context, pair string, address string, amount decimal.Decimal) (decimal.Decimal, error) {
+	return exchange.transferFee, nil
+}
+
+func (exchange *stubExchange) GetWithdrawMin(ctx context.Context, pair string) (decimal.Decimal, error) {
+	return exchange.withdrawMin, nil
+}
+
+func (exchange *stubExchange) GetDepositMin(ctx context.Context, pair string) (decimal.Decimal, error) {
+	return decimal.Zero(), nil
+}
+
+func (exchange *stubExchange) GetDepositAddress(ctx context.Context, pair string) (string, error) {
+	return exchange.name + "-" + pair, nil
+}
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func equal(a decimal.Decimal, b string) bool {
+	return a.Cmp(dec(b)) == 0
+}
+
+// newConfig targets an even SOL split across the exchanges, with the given SOL balances.
+func newConfig(balances map[string]string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Precision = map[string]int{"SOL": 4}
+	cfg.Rebalance.Tolerance = dec("0.05")
+	cfg.Rebalance.Targets = make(map[string]map[string]decimal.Decimal)
+	cfg.Trading.Balances = make(domain.Balances)
+	share := decimal.NewFromInt64(1).Div(decimal.NewFromInt64(int64(len(balances))), 8)
+	for exchange, balance := range balances {
+		cfg.Rebalance.Targets[exchange] = map[string]decimal.Decimal{"SOL": share}
+		cfg.Trading.Balances[exchange] = map[string]decimal.Decimal{"SOL": dec(balance), "MYR": dec("1000")}
+	}
+	return cfg
+}
+
+func TestPlanTakesCheapestSource(t *testing.T) {
+	cfg := newConfig(map[string]string{"A": "5", "B": "5", "C": "2"})
+	exchanges := map[string]domain.Exchanger{
+		"A": &stubExchange{name: "A", transferFee: dec("0.05")},
+		"B": &stubExchange{name: "B", transferFee: dec("0.01")},
+		"C": &stubExchange{name: "C", transferFee: dec("0.01")},
+	}
+
+	plan := CreateRebalancer(cfg, exchanges, []string{"SOLMYR"}).Plan(context.Background())
+	if len(plan.Transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %+v", plan.Transfers)
+	}
+	// C is 2 short of its 4 target: B covers its 1 surplus first as it is cheaper, A the rest
+	first, second := plan.Transfers[0], plan.Transfers[1]
+	if first.From != "B" || first.To != "C" || !equal(first.Amount, "1") || !equal(first.Received, "0.99") {
+		t.Errorf("unexpected first transfer: %+v", first)
+	}
+	if second.From != "A" || second.To != "C" || !equal(second.Amount, "1") || !equal(second.Fee, "0.05") {
+		t.Errorf("unexpected second transfer: %+v", second)
+	}
+	if fee := plan.GetTotalFee()["SOL"]; !equal(fee, "0.06") {
+		t.Errorf("expected total fee 0.06, got %v", fee)
+	}
+}
+
+func TestPlanWithinToleranceOrBelowMinimum(t *testing.T) {
+	exchanges := map[string]domain.Exchanger{
+		"A": &stubExchange{name: "A", transferFee: dec("0.01")},
+		"B": &stubExchange{name: "B", transferFee: dec("0.01")},
+	}
+	plan := CreateRebalancer(newConfig(map[string]string{"A": "5.2", "B": "4.8"}), exchanges, []string{"SOLMYR"}).Plan(context.Background())
+	if len(plan.Transfers) != 0 || len(plan.Skipped) != 0 {
+		t.Errorf("expected no transfers within tolerance, got %+v", plan)
+	}
+
+	exchanges["A"] = &stubExchange{name: "A", transferFee: dec("0.01"), withdrawMin: dec("10")}
+	plan = CreateRebalancer(newConfig(map[string]string{"A": "8", "B": "2"}), exchanges, []string{"SOLMYR"}).Plan(context.Background())
+	if len(plan.Transfers) != 0 || len(plan.Skipped) != 1 {
+		t.Errorf("expected the transfer below the withdraw minimum to be skipped, got %+v", plan)
+	}
+
+	plan = CreateRebalancer(newConfig(map[string]string{"A": "8", "B": "2"}), exchanges, []string{"AVAXMYR"}).Plan(context.Background())
+	if len(plan.Transfers) != 0 || len(plan.Skipped) != 1 {
+		t.Errorf("expected SOL to be skipped without a pair to transfer it, got %+v", plan)
+	}
+}
+
+func TestRunSimulatesOnPaperExchanges(t *testing.T) {
+	cfg := newConfig(map[string]string{"RebalanceFrom": "8", "RebalanceTo": "2"})
+	cfg.Trading.Enabled, cfg.Trading.Paper = true, true
+	exchanges := make(map[string]domain.Exchanger)
+	for name := range cfg.Trading.Balances {
+		exchanges[name] = paper.CreateClient(&stubExchange{name: name, transferFee: dec("0.01")}, decimal.Zero(), cfg.Trading.Balances[name])
+	}
+
+	CreateRebalancer(cfg, exchanges, []string{"SOLMYR"}).Run(context.Background())
+
+	from, _ := exchanges["RebalanceFrom"].(domain.Trader).GetBalances(context.Background())
+	to, _ := exchanges["RebalanceTo"].(domain.Trader).GetBalances(context.Background())
+	if !equal(from["SOL"], "5") || !equal(to["SOL"], "4.99") {
+		t.Errorf("expected 3 SOL moved less the fee, got %v and %v", from["SOL"], to["SOL"])
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"malaysia-crypto-exchange-arbitrage/internal/database"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/httpclient"
+	"malaysia-crypto-exchange-arbitrage/internal/tracker"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/luno/luno-go/decimal"
+)
+
+const maxOpportunityLimit = 1000
+
+type orderBookResponse struct {
+	Exchange  string
+	Pair      string
+	Asks      []domain.PriceLevel
+	Bids      []domain.PriceLevel
+	UpdatedAt time.Time
+
+	ExchangeTimestamp time.Time // when the exchange reports the book was taken, zero when it does not
+	Age               time.Duration
+}
+
+type exchangeResponse struct {
+	tracker.ExchangeStatus
+	Enabled  bool
+	Healthy  bool
+	MakerFee decimal.Decimal
+	TakerFee decimal.Decimal
+	Http     map[string]httpclient.EndpointMetrics // REST calls by endpoint path
+}
+
+type pairResponse struct {
+	Pair                string
+	Enabled             bool
+	MaxPriceDiff        decimal.Decimal
+	AlertPolicy         string
+	MinProfit           decimal.Decimal
+	MinProfitPercentage decimal.Decimal
+	MaxCapital          decimal.Decimal
+	Exchanges           []string // enabled exchanges configured to transfer the pair's asset
+}
+
+func (s *FiberServer) registerApiRoutes() {
+	api := s.App.Group("/api")
+
+	api.Get("/opportunities", s.opportunitiesHandler)
+	api.Get("/orderbooks/:exchange/:pair", s.orderBookHandler)
+	api.Get("/exchanges", s.exchangesHandler)
+	api.Get("/pairs", s.pairsHandler)
+}
+
+func apiError(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(fiber.Map{"error": message})
+}
+
+// opportunitiesHandler returns stored opportunities, newest first. Query parameters: pair, exchange,
+// buyOn, sellOn, from and to (RFC3339), profitable, minNetProfit and limit.
+func (s *FiberServer) opportunitiesHandler(c *fiber.Ctx) error {
+	filter, err := parseOpportunityFilter(c)
+	if err != nil {
+		return apiError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	records, err := s.db.GetOpportunities(c.Context(), filter)
+	if err != nil {
+		return apiError(c, fiber.StatusInternalServerError, "failed to query opportunities: "+err.Error())
+	}
+	return c.JSON(records)
+}
+
+func parseOpportunityFilter(c *fiber.Ctx) (filter database.OpportunityFilter, err error) {
+	filter.Pair = c.Query("pair")
+	filter.Exchange = c.Query("exchange")
+	filter.BuyOn = c.Query("buyOn")
+	filter.SellOn = c.Query("sellOn")
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if profitable := c.Query("profitable"); profitable != "" {
+		value, err := strconv.ParseBool(profitable)
+		if err != nil {
+			return filter, fmt.Errorf("invalid profitable: %w", err)
+		}
+		filter.Profitable = &value
+	}
+	if minNetProfit := c.Query("minNetProfit"); minNetProfit != "" {
+		value, err := decimal.NewFromString(minNetProfit)
+		if err != nil {
+			return filter, fmt.Errorf("invalid minNetProfit: %w", err)
+		}
+		filter.MinNetProfit = &value
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 || filter.Limit > maxOpportunityLimit {
+			return filter, fmt.Errorf("invalid limit: expected 1 to %d", maxOpportunityLimit)
+		}
+	}
+
+	return filter, nil
+}
+
+// orderBookHandler returns the latest order book the watcher received. The depth query parameter
+// limits the number of levels per side.
+func (s *FiberServer) orderBookHandler(c *fiber.Ctx) error {
+	if s.Market == nil {
+		return apiError(c, fiber.StatusServiceUnavailable, "the watcher is not running")
+	}
+
+	orderbook, ok := s.Market.GetOrderBook(c.Params("exchange"), c.Params("pair"))
+	if !ok {
+		return apiError(c, fiber.StatusNotFound, "no order book for "+c.Params("pair")+" on "+c.Params("exchange"))
+	}
+
+	depth := c.QueryInt("depth", 0)
+	if depth < 0 {
+		return apiError(c, fiber.StatusBadRequest, "invalid depth")
+	}
+	if depth > 0 {
+		orderbook.Asks = orderbook.Asks[:min(depth, len(orderbook.Asks))]
+		orderbook.Bids = orderbook.Bids[:min(depth, len(orderbook.Bids))]
+	}
+
+	return c.JSON(orderBookResponse{
+		Exchange:  orderbook.Exchange.String(),
+		Pair:      orderbook.Pair,
+		Asks:      orderbook.Asks,
+		Bids:      orderbook.Bids,
+		UpdatedAt: orderbook.UpdatedAt,
+
+		ExchangeTimestamp: orderbook.ExchangeTimestamp,
+		Age:               orderbook.GetAge(time.Now()),
+	})
+}
+
+// exchangesHandler returns every configured exchange with its fees, fetch status and REST call metrics.
+func (s *FiberServer) exchangesHandler(c *fiber.Ctx) error {
+	if s.Config == nil {
+		return apiError(c, fiber.StatusServiceUnavailable, "config unavailable")
+	}
+
+	exchanges := make([]exchangeResponse, 0, len(s.Config.Exchange))
+	for name, exchangeConfig := range s.Config.Exchange {
+		status := tracker.ExchangeStatus{Exchange: name}
+		if s.Market != nil {
+			status = s.Market.GetExchangeStatus(name)
+		}
+		exchanges = append(exchanges, exchangeResponse{
+			ExchangeStatus: status,
+			Enabled:        exchangeConfig.Enabled,
+			Healthy:        status.IsHealthy(),
+			MakerFee:       exchangeConfig.MakerFee,
+			TakerFee:       exchangeConfig.TakerFee,
+			Http:           httpclient.GetMetrics(name),
+		})
+	}
+	slices.SortFunc(exchanges, func(a, b exchangeResponse) int {
+		return cmp.Compare(a.Exchange, b.Exchange)
+	})
+
+	return c.JSON(exchanges)
+}
+
+// pairsHandler returns every configured market with its arbitrage settings.
+func (s *FiberServer) pairsHandler(c *fiber.Ctx) error {
+	if s.Config == nil {
+		return apiError(c, fiber.StatusServiceUnavailable, "config unavailable")
+	}
+
+	pairs := make([]pairResponse, 0, len(s.Config.Market))
+	for pair, market := range s.Config.Market {
+		arbitrageConfig := s.Config.Arbitrage[pair]
+		exchanges := make([]string, 0)
+		for name, exchangeConfig := range s.Config.Exchange {
+			if _, ok := exchangeConfig.Crypto[pair]; ok && exchangeConfig.Enabled {
+				exchanges = append(exchanges, name)
+			}
+		}
+		slices.Sort(exchanges)
+
+		pairs = append(pairs, pairResponse{
+			Pair:                pair,
+			Enabled:             market.Enabled,
+			MaxPriceDiff:        market.MaxPriceDiff,
+			AlertPolicy:         arbitrageConfig.AlertPolicy.String(),
+			MinProfit:           arbitrageConfig.MinProfit,
+			MinProfitPercentage: arbitrageConfig.MinProfitPercentage,
+			MaxCapital:          arbitrageConfig.MaxCapital,
+			Exchanges:           exchanges,
+		})
+	}
+	slices.SortFunc(pairs, func(a, b pairResponse) int {
+		return cmp.Compare(a.Pair, b.Pair)
+	})
+
+	return c.JSON(pairs)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/database"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/tracker"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/luno/luno-go/decimal"
+)
+
+type fakeDatabase struct {
+	database.Service
+	filter  database.OpportunityFilter
+	records []database.OpportunityRecord
+}
+
+func (db *fakeDatabase) GetOpportunities(ctx context.Context, filter database.OpportunityFilter) ([]database.OpportunityRecord, error) {
+	db.filter = filter
+	return db.records, nil
+}
+
+func newApiTestServer(t *testing.T) (*FiberServer, *fakeDatabase) {
+	var cfg config.Config
+	err := json.Unmarshal([]byte(`{
+		"Market": {"SOLMYR": {"Enabled": true, "MaxPriceDiff": 10}, "XLMMYR": {"Enabled": false}},
+		"Arbitrage": {"SOLMYR": {"AlertPolicy": 1, "MinProfit": 1, "MinProfitPercentage": 0.2}},
+		"Exchange": {
+			"Luno": {"Enabled": true, "TakerFee": 0.006, "Crypto": {"SOLMYR": {}}},
+			"Hata": {"Enabled": true, "TakerFee": 0.002, "Crypto": {"SOLMYR": {}, "XLMMYR": {}}},
+			"MXGlobal": {"Enabled": false, "Crypto": {"SOLMYR": {}}}
+		}
+	}`), &cfg)
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	db := &fakeDatabase{}
+	s := &FiberServer{App: fiber.New(), db: db, Market: tracker.NewMarketTracker(0, 0), Config: &cfg}
+	s.registerApiRoutes()
+	return s, db
+}
+
+func get(t *testing.T, s *FiberServer, target string, response any) int {
+	t.Helper()
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	resp, err := s.App.Test(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("error reading response body. Err: %v", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(body, response); err != nil {
+			t.Fatalf("error decoding %s: %v", body, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestOpportunitiesHandlerParsesFilter(t *testing.T) {
+	s, db := newApiTestServer(t)
+	db.records = []database.OpportunityRecord{{Id: 7, ArbitrageOpportunity: domain.ArbitrageOpportunity{Pair: "SOLMYR", NetProfit: decimal.NewFromInt64(3)}}}
+
+	var records []database.OpportunityRecord
+	status := get(t, s, "/api/opportunities?pair=SOLMYR&exchange=Luno&from=2024-11-01T00:00:00Z&profitable=true&minNetProfit=1.5&limit=10", &records)
+	if status != http.StatusOK || len(records) != 1 || records[0].Id != 7 || records[0].NetProfit.String() != "3" {
+		t.Fatalf("unexpected response %d %+v", status, records)
+	}
+
+	filter := db.filter
+	if filter.Pair != "SOLMYR" || filter.Exchange != "Luno" || !filter.From.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)) ||
+		filter.Profitable == nil || !*filter.Profitable || filter.MinNetProfit == nil || filter.MinNetProfit.String() != "1.5" || filter.Limit != 10 {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+
+	for _, query := range []string{"from=yesterday", "profitable=maybe", "minNetProfit=lots", "limit=0"} {
+		if status := get(t, s, "/api/opportunities?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected; got %d", query, status)
+		}
+	}
+}
+
+func TestOrderBookHandler(t *testing.T) {
+	s, _ := newApiTestServer(t)
+	s.Market.RecordOrderBook(domain.OrderBook{
+		Exchange: domain.Luno,
+		Pair:     "SOLMYR",
+		Asks:     []domain.PriceLevel{{Price: decimal.NewFromInt64(1040), Volume: decimal.NewFromInt64(1)}, {Price: decimal.NewFromInt64(1041), Volume: decimal.NewFromInt64(2)}},
+	}, time.Now())
+
+	var orderbook orderBookResponse
+	if status := get(t, s, "/api/orderbooks/Luno/SOLMYR?depth=1", &orderbook); status != http.StatusOK {
+		t.Fatalf("expected the cached book; got %d", status)
+	}
+	if orderbook.Exchange != "Luno" || len(orderbook.Asks) != 1 || orderbook.Asks[0].Price.String() != "1040" || len(orderbook.Bids) != 0 {
+		t.Errorf("unexpected order book %+v", orderbook)
+	}
+
+	if status := get(t, s, "/api/orderbooks/Hata/SOLMYR", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a book never received; got %d", status)
+	}
+}
+
+func TestExchangesAndPairsHandlers(t *testing.T) {
+	s, _ := newApiTestServer(t)
+	s.Market.RecordOrderBook(domain.OrderBook{Exchange: domain.Luno, Pair: "SOLMYR"}, time.Now())
+
+	var exchanges []exchangeResponse
+	if status := get(t, s, "/api/exchanges", &exchanges); status != http.StatusOK || len(exchanges) != 3 {
+		t.Fatalf("expected the 3 configured exchanges; got %d %+v", status, exchanges)
+	}
+	if exchanges[0].Exchange != "Hata" || exchanges[0].Healthy || exchanges[1].Exchange != "Luno" || !exchanges[1].Healthy || exchanges[1].TakerFee.String() != "0.006" {
+		t.Errorf("unexpected exchanges %+v", exchanges)
+	}
+
+	var pairs []pairResponse
+	if status := get(t, s, "/api/pairs", &pairs); status != http.StatusOK || len(pairs) != 2 {
+		t.Fatalf("expected the 2 configured pairs; got %d %+v", status, pairs)
+	}
+	solmyr := pairs[0]
+	if solmyr.Pair != "SOLMYR" || !solmyr.Enabled || solmyr.AlertPolicy != domain.MinProfitPercentage.String() || len(solmyr.Exchanges) != 2 || solmyr.Exchanges[0] != "Hata" || solmyr.Exchanges[1] != "Luno" {
+		t.Errorf("unexpected pair %+v", solmyr)
+	}
+}
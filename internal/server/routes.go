@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"log"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,6 +30,7 @@ func (s *FiberServer) RegisterFiberRoutes() {
 
 	s.App.Get("/websocket", websocket.New(s.websocketHandler))
 
+	s.registerApiRoutes()
 }
 
 func (s *FiberServer) HelloWorldHandler(c *fiber.Ctx) error {
@@ -42,31 +45,114 @@ func (s *FiberServer) healthHandler(c *fiber.Ctx) error {
 	return c.JSON(s.db.Health())
 }
 
+const websocketEventBuffer = 256
+
+// websocketRequest is sent by clients to choose the events they receive, e.g.
+// {"action": "subscribe", "pairs": ["SOLMYR"], "exchanges": ["Luno"]}. Empty lists match everything.
+type websocketRequest struct {
+	Action    string
+	Pairs     []string
+	Exchanges []string
+}
+
+type eventFilter struct {
+	pairs     []string
+	exchanges []string
+}
+
+func (filter eventFilter) matches(event domain.Event) bool {
+	if len(filter.pairs) > 0 && !slices.Contains(filter.pairs, event.Pair) {
+		return false
+	}
+	if len(filter.exchanges) > 0 && !slices.ContainsFunc(event.Exchanges, func(exchange string) bool {
+		return slices.Contains(filter.exchanges, exchange)
+	}) {
+		return false
+	}
+	return true
+}
+
+// websocketHandler streams watcher events as JSON. Every client starts subscribed to all events and
+// receives the open opportunities matching its filter whenever it subscribes.
 func (s *FiberServer) websocketHandler(con *websocket.Conn) {
+	if s.Events == nil {
+		con.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "events unavailable"))
+		return
+	}
+
+	subscription := s.Events.Subscribe(websocketEventBuffer)
+	defer subscription.Close()
+
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := make(chan eventFilter)
 
 	go func() {
+		defer cancel()
 		for {
-			_, _, err := con.ReadMessage()
+			_, message, err := con.ReadMessage()
 			if err != nil {
-				cancel()
 				log.Println("Receiver Closing", err)
-				break
+				return
+			}
+
+			var request websocketRequest
+			if err := json.Unmarshal(message, &request); err != nil || request.Action != "subscribe" {
+				log.Printf("ignoring websocket message: %s", message)
+				continue
+			}
+			select {
+			case filters <- eventFilter{pairs: request.Pairs, exchanges: request.Exchanges}:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
+	filter := eventFilter{}
+	if err := s.writeOpenOpportunities(con, filter); err != nil {
+		log.Printf("could not write to socket: %v", err)
+		return
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			payload := fmt.Sprintf("server timestamp: %d", time.Now().UnixNano())
-			if err := con.WriteMessage(websocket.TextMessage, []byte(payload)); err != nil {
+		case filter = <-filters:
+			if err := s.writeOpenOpportunities(con, filter); err != nil {
+				log.Printf("could not write to socket: %v", err)
+				return
+			}
+		case event, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if err := con.WriteJSON(event); err != nil {
 				log.Printf("could not write to socket: %v", err)
 				return
 			}
-			time.Sleep(time.Second * 2)
 		}
 	}
 }
+
+func (s *FiberServer) writeOpenOpportunities(con *websocket.Conn, filter eventFilter) error {
+	if s.Tracker == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, opportunity := range s.Tracker.Open() {
+		event := domain.NewOpportunityEvent(domain.OpportunityOpened, opportunity, now)
+		if !filter.matches(event) {
+			continue
+		}
+		if err := con.WriteJSON(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,10 +1,21 @@
 package server
 
 import (
-	"github.com/gofiber/fiber/v2"
+	"context"
 	"io"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/pubsub"
+	"malaysia-crypto-exchange-arbitrage/internal/tracker"
+	"net"
 	"net/http"
 	"testing"
+	"time"
+
+	coderws "github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/luno/luno-go/decimal"
 )
 
 func TestHandler(t *testing.T) {
@@ -37,3 +48,63 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestWebsocketStreamsSubscribedEvents(t *testing.T) {
+	events := pubsub.New[domain.Event]()
+	opportunities := tracker.NewOpportunityTracker(nil, 0, decimal.Zero())
+	opportunities.Update("SOLMYR", []domain.ArbitrageOpportunity{{Pair: "SOLMYR", BuyOn: "Hata", SellOn: "Luno", NetProfit: decimal.NewFromInt64(5)}}, nil, time.Now())
+	opportunities.Update("AVAXMYR", []domain.ArbitrageOpportunity{{Pair: "AVAXMYR", BuyOn: "Luno", SellOn: "MXGlobal", NetProfit: decimal.NewFromInt64(1)}}, nil, time.Now())
+
+	app := fiber.New()
+	s := &FiberServer{App: app, Events: events, Tracker: opportunities}
+	app.Get("/websocket", websocket.New(s.websocketHandler))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	con, _, err := coderws.Dial(ctx, "ws://"+listener.Addr().String()+"/websocket", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer con.CloseNow()
+
+	read := func() domain.Event {
+		t.Helper()
+		var event struct {
+			Type        string
+			Pair        string
+			Opportunity *domain.ArbitrageOpportunity
+		}
+		if err := wsjson.Read(ctx, con, &event); err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		if event.Type != domain.OpportunityOpened.String() && event.Type != domain.TopOfBookChanged.String() {
+			t.Fatalf("unexpected event type %s", event.Type)
+		}
+		return domain.Event{Pair: event.Pair, Opportunity: event.Opportunity}
+	}
+
+	// Unfiltered clients first receive every open opportunity
+	if first, second := read(), read(); first.Pair != "SOLMYR" || second.Pair != "AVAXMYR" {
+		t.Fatalf("expected the open opportunities most profitable first; got %s, %s", first.Pair, second.Pair)
+	}
+
+	if err := wsjson.Write(ctx, con, map[string]any{"action": "subscribe", "pairs": []string{"SOLMYR"}}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if event := read(); event.Pair != "SOLMYR" || event.Opportunity == nil || event.Opportunity.NetProfit.String() != "5" {
+		t.Fatalf("expected the SOLMYR snapshot after subscribing; got %+v", event)
+	}
+
+	events.Publish(domain.NewTopOfBookEvent(domain.TopOfBook{Exchange: "Luno", Pair: "AVAXMYR"}, time.Now()))
+	events.Publish(domain.NewTopOfBookEvent(domain.TopOfBook{Exchange: "Luno", Pair: "SOLMYR"}, time.Now()))
+	if event := read(); event.Pair != "SOLMYR" {
+		t.Errorf("expected only SOLMYR events after subscribing; got %s", event.Pair)
+	}
+}
@@ -4,12 +4,21 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	"malaysia-crypto-exchange-arbitrage/internal/database"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/config"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/pubsub"
+	"malaysia-crypto-exchange-arbitrage/internal/tracker"
 )
 
 type FiberServer struct {
 	*fiber.App
 
 	db database.Service
+
+	Events  *pubsub.Broker[domain.Event] // streamed to websocket clients when set
+	Tracker *tracker.OpportunityTracker  // open opportunities sent to websocket clients when they (re)subscribe
+	Market  *tracker.MarketTracker       // latest order books and exchange status served by the API
+	Config  *config.Config               // exchanges and pairs served by the API
 }
 
 func New() *FiberServer {
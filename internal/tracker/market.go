@@ -0,0 +1,170 @@
+package tracker
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CachedOrderBook is the latest order book received from an exchange for a pair.
+type CachedOrderBook struct {
+	domain.OrderBook
+	UpdatedAt time.Time
+}
+
+// ExchangeStatus summarizes how fetching order books from an exchange has been going.
+type ExchangeStatus struct {
+	Exchange    string
+	LastUpdate  time.Time // last order book received
+	LastError   string
+	LastErrorAt time.Time
+	ErrorCount  int      // errors since the watcher started
+	Pairs       []string // pairs with a cached order book
+
+	ConsecutiveFailures int                     // errors since the last order book received
+	Latency             time.Duration           // of the last order book fetched over REST
+	Circuit             domain.CircuitStateEnum // Open while the exchange is left out of the analysis
+	CircuitOpenUntil    time.Time
+}
+
+// IsHealthy reports whether the exchange delivered an order book since its last error.
+func (status ExchangeStatus) IsHealthy() bool {
+	return !status.LastUpdate.IsZero() && !status.LastUpdate.Before(status.LastErrorAt)
+}
+
+// MarketTracker caches the latest order book of every exchange and pair seen by the watcher,
+// together with the errors each exchange returned. It also acts as a circuit breaker per exchange:
+// after failureThreshold errors in a row the circuit opens and Allow keeps the exchange out of the
+// analysis for the cooldown, then lets a single fetch through to probe whether it recovered.
+type MarketTracker struct {
+	orderbooks       map[string]map[string]CachedOrderBook // exchange => pair => latest book
+	statuses         map[string]*ExchangeStatus
+	failureThreshold int // consecutive errors opening the circuit, 0 to disable the circuit breaker
+	cooldown         time.Duration
+	mutex            sync.RWMutex
+}
+
+func NewMarketTracker(failureThreshold int, cooldown time.Duration) *MarketTracker {
+	return &MarketTracker{
+		orderbooks:       make(map[string]map[string]CachedOrderBook),
+		statuses:         make(map[string]*ExchangeStatus),
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+func (tracker *MarketTracker) RecordOrderBook(orderbook domain.OrderBook, at time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	exchange := orderbook.Exchange.String()
+	if tracker.orderbooks[exchange] == nil {
+		tracker.orderbooks[exchange] = make(map[string]CachedOrderBook)
+	}
+	tracker.orderbooks[exchange][orderbook.Pair] = CachedOrderBook{OrderBook: orderbook.Clone(), UpdatedAt: at}
+
+	status := tracker.status(exchange)
+	status.LastUpdate = at
+	status.ConsecutiveFailures = 0
+	status.CircuitOpenUntil = time.Time{}
+}
+
+// RecordLatency records how long fetching an order book from the exchange took.
+func (tracker *MarketTracker) RecordLatency(exchange string, latency time.Duration) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.status(exchange).Latency = latency
+}
+
+func (tracker *MarketTracker) RecordError(exchange string, err error, at time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	status := tracker.status(exchange)
+	status.LastError = err.Error()
+	status.LastErrorAt = at
+	status.ErrorCount++
+	status.ConsecutiveFailures++
+	if tracker.failureThreshold > 0 && status.ConsecutiveFailures >= tracker.failureThreshold {
+		status.CircuitOpenUntil = at.Add(tracker.cooldown)
+	}
+}
+
+// Allow reports whether the exchange may be fetched and analyzed. Once the cooldown of an open
+// circuit ends, a single caller is allowed through as a probe: the circuit stays open for another
+// cooldown unless the probe records an order book, which closes it.
+func (tracker *MarketTracker) Allow(exchange string, now time.Time) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	status := tracker.status(exchange)
+	switch tracker.circuitState(status, now) {
+	case domain.CircuitOpen:
+		return false
+	case domain.CircuitHalfOpen:
+		status.CircuitOpenUntil = now.Add(tracker.cooldown)
+	}
+	return true
+}
+
+// GetCircuitState returns the state of the exchange's circuit breaker at now.
+func (tracker *MarketTracker) GetCircuitState(exchange string, now time.Time) domain.CircuitStateEnum {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	status, ok := tracker.statuses[exchange]
+	if !ok {
+		return domain.CircuitClosed
+	}
+	return tracker.circuitState(status, now)
+}
+
+func (tracker *MarketTracker) circuitState(status *ExchangeStatus, now time.Time) domain.CircuitStateEnum {
+	if tracker.failureThreshold <= 0 || status.ConsecutiveFailures < tracker.failureThreshold {
+		return domain.CircuitClosed
+	}
+	if now.Before(status.CircuitOpenUntil) {
+		return domain.CircuitOpen
+	}
+	return domain.CircuitHalfOpen
+}
+
+// GetOrderBook returns a copy of the latest order book of the exchange and pair.
+func (tracker *MarketTracker) GetOrderBook(exchange string, pair string) (orderbook CachedOrderBook, ok bool) {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	orderbook, ok = tracker.orderbooks[exchange][pair]
+	orderbook.OrderBook = orderbook.OrderBook.Clone()
+	return orderbook, ok
+}
+
+// GetExchangeStatus returns the status of the exchange, zero apart from the name when nothing
+// has been recorded for it yet.
+func (tracker *MarketTracker) GetExchangeStatus(exchange string) ExchangeStatus {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	status := ExchangeStatus{Exchange: exchange}
+	if recorded, ok := tracker.statuses[exchange]; ok {
+		status = *recorded
+		status.Circuit = tracker.circuitState(recorded, time.Now())
+	}
+	status.Pairs = make([]string, 0, len(tracker.orderbooks[exchange]))
+	for pair := range tracker.orderbooks[exchange] {
+		status.Pairs = append(status.Pairs, pair)
+	}
+	slices.Sort(status.Pairs)
+	return status
+}
+
+func (tracker *MarketTracker) status(exchange string) *ExchangeStatus {
+	status, ok := tracker.statuses[exchange]
+	if !ok {
+		status = &ExchangeStatus{Exchange: exchange}
+		tracker.statuses[exchange] = status
+	}
+	return status
+}
@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"errors"
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func TestMarketTrackerCachesOrderBooksAndErrors(t *testing.T) {
+	tracker := NewMarketTracker(0, 0)
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	orderbook := domain.OrderBook{Exchange: domain.Luno, Pair: "SOLMYR", Asks: []domain.PriceLevel{{Price: decimal.NewFromInt64(1040), Volume: decimal.NewFromInt64(1)}}}
+	tracker.RecordOrderBook(orderbook, start)
+	orderbook.Asks[0].Price = decimal.NewFromInt64(1)
+
+	cached, ok := tracker.GetOrderBook("Luno", "SOLMYR")
+	if !ok || !cached.UpdatedAt.Equal(start) || cached.Asks[0].Price.String() != "1040" {
+		t.Fatalf("expected the book as recorded; got %+v", cached)
+	}
+	if _, ok := tracker.GetOrderBook("Hata", "SOLMYR"); ok {
+		t.Error("expected no book for an exchange that never delivered one")
+	}
+
+	status := tracker.GetExchangeStatus("Luno")
+	if !status.IsHealthy() || len(status.Pairs) != 1 || status.Pairs[0] != "SOLMYR" {
+		t.Errorf("expected a healthy exchange with SOLMYR cached; got %+v", status)
+	}
+
+	tracker.RecordError("Luno", errors.New("timeout"), start.Add(time.Second))
+	status = tracker.GetExchangeStatus("Luno")
+	if status.IsHealthy() || status.ErrorCount != 1 || status.LastError != "timeout" {
+		t.Errorf("expected an unhealthy exchange after the error; got %+v", status)
+	}
+
+	tracker.RecordOrderBook(orderbook, start.Add(2*time.Second))
+	if status := tracker.GetExchangeStatus("Luno"); !status.IsHealthy() || status.ErrorCount != 1 {
+		t.Errorf("expected the exchange to recover and keep its error count; got %+v", status)
+	}
+
+	if status := tracker.GetExchangeStatus("Hata"); status.Exchange != "Hata" || status.IsHealthy() {
+		t.Errorf("expected an unknown exchange to be reported unhealthy; got %+v", status)
+	}
+}
+
+func TestMarketTrackerCircuitBreaker(t *testing.T) {
+	tracker := NewMarketTracker(2, time.Minute)
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	orderbook := domain.OrderBook{Exchange: domain.Hata, Pair: "SOLMYR"}
+
+	tracker.RecordError("Hata", errors.New("503"), start)
+	if !tracker.Allow("Hata", start) {
+		t.Fatal("expected a single failure to keep the circuit closed")
+	}
+	tracker.RecordError("Hata", errors.New("503"), start)
+	if tracker.Allow("Hata", start.Add(time.Second)) {
+		t.Fatal("expected the circuit to open after 2 failures in a row")
+	}
+	if status := tracker.GetExchangeStatus("Hata"); status.ConsecutiveFailures != 2 || !status.CircuitOpenUntil.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the circuit open for the cooldown after 2 failures; got %+v", status)
+	}
+	if !tracker.Allow("Luno", start) {
+		t.Error("expected other exchanges to stay allowed")
+	}
+
+	// Once the cooldown ends a single probe is let through
+	probeAt := start.Add(time.Minute)
+	if state := tracker.GetCircuitState("Hata", probeAt); state != domain.CircuitHalfOpen {
+		t.Fatalf("expected a half open circuit after the cooldown; got %v", state)
+	}
+	if !tracker.Allow("Hata", probeAt) || tracker.Allow("Hata", probeAt) {
+		t.Fatal("expected exactly one probe after the cooldown")
+	}
+
+	// A failed probe opens the circuit for another cooldown
+	tracker.RecordError("Hata", errors.New("503"), probeAt)
+	if tracker.Allow("Hata", probeAt.Add(30*time.Second)) {
+		t.Fatal("expected the circuit to reopen after a failed probe")
+	}
+
+	// A successful probe closes it
+	recoveredAt := probeAt.Add(time.Minute)
+	if !tracker.Allow("Hata", recoveredAt) {
+		t.Fatal("expected a probe after the second cooldown")
+	}
+	tracker.RecordOrderBook(orderbook, recoveredAt)
+	if !tracker.Allow("Hata", recoveredAt) || tracker.GetCircuitState("Hata", recoveredAt) != domain.CircuitClosed {
+		t.Error("expected the circuit to close after an order book")
+	}
+	if status := tracker.GetExchangeStatus("Hata"); status.ConsecutiveFailures != 0 || status.ErrorCount != 3 {
+		t.Errorf("expected the failures in a row reset and the error count kept; got %+v", status)
+	}
+}
+
+func TestMarketTrackerCircuitBreakerDisabled(t *testing.T) {
+	tracker := NewMarketTracker(0, time.Minute)
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		tracker.RecordError("Hata", errors.New("503"), start)
+	}
+	if !tracker.Allow("Hata", start) || tracker.GetCircuitState("Hata", start) != domain.CircuitClosed {
+		t.Error("expected no circuit breaker with a 0 threshold")
+	}
+}
@@ -0,0 +1,166 @@
+package tracker
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/pubsub"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// OpportunityTracker follows every pair and exchange route through its lifecycle: opened when an
+// opportunity first appears, updated while its figures change and closed once it disappears. Each
+// transition is published as an event, and Update decides which of them are worth an alert.
+type OpportunityTracker struct {
+	events                 *pubsub.Broker[domain.Event]
+	cooldown               time.Duration                  // minimum time between open or update alerts of a route
+	profitChangePercentage decimal.Decimal                // net profit move since the last alert that is worth an update alert, 0 to disable
+	open                   map[string]*trackedOpportunity // route key => lifecycle
+	lastAlerts             map[string]time.Time           // route key => last open or update alert, kept after closing for the cooldown
+	mutex                  sync.Mutex
+}
+
+type trackedOpportunity struct {
+	opportunity     domain.ArbitrageOpportunity
+	openedAt        time.Time
+	peakProfit      decimal.Decimal
+	alerted         bool // the open alert was sent, so the close is alerted too
+	lastAlertProfit decimal.Decimal
+}
+
+func NewOpportunityTracker(events *pubsub.Broker[domain.Event], cooldown time.Duration, profitChangePercentage decimal.Decimal) *OpportunityTracker {
+	return &OpportunityTracker{
+		events:                 events,
+		cooldown:               cooldown,
+		profitChangePercentage: profitChangePercentage,
+		open:                   make(map[string]*trackedOpportunity),
+		lastAlerts:             make(map[string]time.Time),
+	}
+}
+
+// Update replaces the open opportunities of the pair with the given ones and returns the alerts to
+// send. A route is alerted once when it opens, or as soon as its cooldown ends if it reopened within
+// it; again when its net profit moved by profitChangePercentage since the last alert; and when it
+// closes, if its open was alerted. Open routes among unresolved, whose checks could not complete, are
+// kept as they are rather than closed.
+func (tracker *OpportunityTracker) Update(pair string, opportunities []domain.ArbitrageOpportunity, unresolved []domain.ArbitrageOpportunity, now time.Time) (alerts []domain.Alert) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	seen := make(map[string]bool, len(opportunities)+len(unresolved))
+	for _, opportunity := range unresolved {
+		seen[routeKey(opportunity)] = true
+	}
+	for _, opportunity := range opportunities {
+		key := routeKey(opportunity)
+		seen[key] = true
+
+		tracked, ok := tracker.open[key]
+		if !ok {
+			tracked = &trackedOpportunity{opportunity: opportunity, openedAt: now, peakProfit: opportunity.NetProfit}
+			tracker.open[key] = tracked
+			tracker.publish(domain.NewOpportunityEvent(domain.OpportunityOpened, opportunity, now))
+		} else {
+			previous := tracked.opportunity
+			tracked.opportunity = opportunity
+			if opportunity.NetProfit.Cmp(tracked.peakProfit) > 0 {
+				tracked.peakProfit = opportunity.NetProfit
+			}
+			if changed(previous, opportunity) {
+				tracker.publish(domain.NewOpportunityEvent(domain.OpportunityUpdated, opportunity, now))
+			}
+		}
+
+		if !tracker.coolingDown(key, now) {
+			if !tracked.alerted {
+				alerts = append(alerts, tracker.alert(key, tracked, domain.OpportunityOpened, now))
+			} else if tracker.movedSignificantly(tracked) {
+				alerts = append(alerts, tracker.alert(key, tracked, domain.OpportunityUpdated, now))
+			}
+		}
+	}
+
+	for key, tracked := range tracker.open {
+		if tracked.opportunity.Pair != pair || seen[key] {
+			continue
+		}
+		delete(tracker.open, key)
+		tracker.publish(domain.NewOpportunityEvent(domain.OpportunityClosed, tracked.opportunity, now))
+		if tracked.alerted {
+			alerts = append(alerts, domain.Alert{
+				Type:        domain.OpportunityClosed,
+				Opportunity: tracked.opportunity,
+				OpenedAt:    tracked.openedAt,
+				AlertedAt:   now,
+				PeakProfit:  tracked.peakProfit,
+			})
+		}
+	}
+
+	return alerts
+}
+
+// Open returns the open opportunities, most profitable first.
+func (tracker *OpportunityTracker) Open() []domain.ArbitrageOpportunity {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	opportunities := make([]domain.ArbitrageOpportunity, 0, len(tracker.open))
+	for _, tracked := range tracker.open {
+		opportunities = append(opportunities, tracked.opportunity)
+	}
+	slices.SortFunc(opportunities, func(a, b domain.ArbitrageOpportunity) int {
+		return b.NetProfit.Cmp(a.NetProfit)
+	})
+	return opportunities
+}
+
+func (tracker *OpportunityTracker) alert(key string, tracked *trackedOpportunity, alertType domain.EventTypeEnum, now time.Time) domain.Alert {
+	tracked.alerted = true
+	tracked.lastAlertProfit = tracked.opportunity.NetProfit
+	tracker.lastAlerts[key] = now
+
+	return domain.Alert{Type: alertType, Opportunity: tracked.opportunity, OpenedAt: tracked.openedAt, AlertedAt: now, PeakProfit: tracked.peakProfit}
+}
+
+func (tracker *OpportunityTracker) coolingDown(key string, now time.Time) bool {
+	lastAlert, ok := tracker.lastAlerts[key]
+	return ok && now.Sub(lastAlert) < tracker.cooldown
+}
+
+// movedSignificantly reports whether the net profit moved by at least profitChangePercentage
+// since the last alert.
+func (tracker *OpportunityTracker) movedSignificantly(tracked *trackedOpportunity) bool {
+	if tracker.profitChangePercentage.Sign() <= 0 {
+		return false
+	}
+	change := tracked.opportunity.NetProfit.Sub(tracked.lastAlertProfit)
+	if change.Sign() < 0 {
+		change = change.Neg()
+	}
+	threshold := tracked.lastAlertProfit.Mul(tracker.profitChangePercentage)
+	if threshold.Sign() < 0 {
+		threshold = threshold.Neg()
+	}
+	return change.MulInt64(100).Cmp(threshold) >= 0
+}
+
+func (tracker *OpportunityTracker) publish(event domain.Event) {
+	if tracker.events != nil {
+		tracker.events.Publish(event)
+	}
+}
+
+func routeKey(opportunity domain.ArbitrageOpportunity) string {
+	return opportunity.Pair + "/" + opportunity.BuyOn + "/" + opportunity.SellOn
+}
+
+// changed reports whether an opportunity moved enough to be worth an update event.
+func changed(previous domain.ArbitrageOpportunity, current domain.ArbitrageOpportunity) bool {
+	return previous.NetProfit.Cmp(current.NetProfit) != 0 ||
+		previous.BuyVolume.Cmp(current.BuyVolume) != 0 ||
+		previous.BuyPrice.Cmp(current.BuyPrice) != 0 ||
+		previous.SellPrice.Cmp(current.SellPrice) != 0
+}
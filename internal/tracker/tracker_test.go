@@ -0,0 +1,182 @@
+package tracker
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"malaysia-crypto-exchange-arbitrage/internal/platform/pubsub"
+	"testing"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func opportunity(pair string, buyOn string, sellOn string, netProfit int64) domain.ArbitrageOpportunity {
+	return domain.ArbitrageOpportunity{Pair: pair, BuyOn: buyOn, SellOn: sellOn, NetProfit: decimal.NewFromInt64(netProfit), Profitable: true}
+}
+
+func receive(t *testing.T, subscription *pubsub.Subscription[domain.Event]) []domain.EventTypeEnum {
+	t.Helper()
+	types := make([]domain.EventTypeEnum, 0)
+	for {
+		select {
+		case event := <-subscription.C:
+			types = append(types, event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func alertTypes(alerts []domain.Alert) []domain.EventTypeEnum {
+	types := make([]domain.EventTypeEnum, 0, len(alerts))
+	for _, alert := range alerts {
+		types = append(types, alert.Type)
+	}
+	return types
+}
+
+func TestUpdatePublishesLifecycle(t *testing.T) {
+	events := pubsub.New[domain.Event]()
+	subscription := events.Subscribe(10)
+	defer subscription.Close()
+	tracker := NewOpportunityTracker(events, 0, decimal.Zero())
+	now := time.Now()
+
+	tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3)}, nil, now)
+	if got := receive(t, subscription); len(got) != 1 || got[0] != domain.OpportunityOpened {
+		t.Fatalf("expected an opened event; got %v", got)
+	}
+
+	tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3)}, nil, now)
+	if got := receive(t, subscription); len(got) != 0 {
+		t.Fatalf("expected no event for an unchanged opportunity; got %v", got)
+	}
+
+	tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 4)}, nil, now)
+	if got := receive(t, subscription); len(got) != 1 || got[0] != domain.OpportunityUpdated {
+		t.Fatalf("expected an updated event; got %v", got)
+	}
+
+	tracker.Update("AVAXMYR", []domain.ArbitrageOpportunity{opportunity("AVAXMYR", "Luno", "Hata", 1)}, nil, now)
+	tracker.Update("SOLMYR", nil, nil, now)
+	if got := receive(t, subscription); len(got) != 2 || got[0] != domain.OpportunityOpened || got[1] != domain.OpportunityClosed {
+		t.Fatalf("expected AVAXMYR opened and SOLMYR closed; got %v", got)
+	}
+
+	open := tracker.Open()
+	if len(open) != 1 || open[0].Pair != "AVAXMYR" {
+		t.Errorf("expected only the AVAXMYR opportunity open; got %+v", open)
+	}
+}
+
+func TestUpdateAlertsOnceAndOnClose(t *testing.T) {
+	tracker := NewOpportunityTracker(nil, 0, decimal.Zero())
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+
+	alerts := tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3)}, nil, start)
+	if got := alertTypes(alerts); len(got) != 1 || got[0] != domain.OpportunityOpened {
+		t.Fatalf("expected an open alert; got %v", got)
+	}
+
+	for i := 1; i <= 3; i++ {
+		alerts = tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3+int64(i))}, nil, start.Add(time.Duration(i)*30*time.Second))
+		if len(alerts) != 0 {
+			t.Fatalf("expected no alert while the opportunity persists; got %v", alertTypes(alerts))
+		}
+	}
+
+	alerts = tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 2)}, nil, start.Add(2*time.Minute))
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alert for a smaller profit; got %v", alertTypes(alerts))
+	}
+
+	alerts = tracker.Update("SOLMYR", nil, nil, start.Add(150*time.Second))
+	if len(alerts) != 1 || alerts[0].Type != domain.OpportunityClosed {
+		t.Fatalf("expected a close alert; got %v", alertTypes(alerts))
+	}
+	if alerts[0].GetDuration() != 150*time.Second || alerts[0].PeakProfit.String() != "6" || alerts[0].Opportunity.NetProfit.String() != "2" {
+		t.Errorf("unexpected close alert %+v", alerts[0])
+	}
+}
+
+func TestUpdateKeepsUnresolvedRoutes(t *testing.T) {
+	events := pubsub.New[domain.Event]()
+	subscription := events.Subscribe(10)
+	defer subscription.Close()
+	tracker := NewOpportunityTracker(events, 0, decimal.Zero())
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	routes := []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3), opportunity("SOLMYR", "Luno", "MXGlobal", 2)}
+
+	tracker.Update("SOLMYR", routes, nil, start)
+	receive(t, subscription)
+
+	// The Hata route's transfer fee lookup failed: it stays open as it was, the other route closes
+	alerts := tracker.Update("SOLMYR", nil, routes[:1], start.Add(time.Minute))
+	if len(alerts) != 1 || alerts[0].Type != domain.OpportunityClosed || alerts[0].Opportunity.BuyOn != "Luno" {
+		t.Fatalf("expected only the Luno route closed; got %+v", alerts)
+	}
+	if got := receive(t, subscription); len(got) != 1 || got[0] != domain.OpportunityClosed {
+		t.Errorf("expected only a close event; got %v", got)
+	}
+	if open := tracker.Open(); len(open) != 1 || open[0].BuyOn != "Hata" {
+		t.Errorf("expected the Hata route still open; got %+v", open)
+	}
+
+	// Unresolved routes that were not open are not opened
+	if alerts := tracker.Update("SOLMYR", nil, routes[1:], start.Add(2*time.Minute)); len(alerts) != 1 || alerts[0].Opportunity.BuyOn != "Hata" {
+		t.Errorf("expected the Hata route closed once resolved; got %+v", alerts)
+	}
+	if open := tracker.Open(); len(open) != 0 {
+		t.Errorf("expected no open routes; got %+v", open)
+	}
+}
+
+func TestUpdateAppliesCooldown(t *testing.T) {
+	tracker := NewOpportunityTracker(nil, 5*time.Minute, decimal.Zero())
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	solmyr := []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", 3)}
+
+	tracker.Update("SOLMYR", solmyr, nil, start)
+	tracker.Update("SOLMYR", nil, nil, start.Add(time.Minute))
+
+	// Reopening within the cooldown is tracked but not alerted, and neither is its close
+	if alerts := tracker.Update("SOLMYR", solmyr, nil, start.Add(2*time.Minute)); len(alerts) != 0 {
+		t.Fatalf("expected the reopen to be suppressed; got %v", alertTypes(alerts))
+	}
+	if alerts := tracker.Update("SOLMYR", nil, nil, start.Add(3*time.Minute)); len(alerts) != 0 {
+		t.Fatalf("expected no close alert for a suppressed open; got %v", alertTypes(alerts))
+	}
+
+	// A reopened route that outlives the cooldown is alerted late
+	tracker.Update("SOLMYR", solmyr, nil, start.Add(4*time.Minute))
+	alerts := tracker.Update("SOLMYR", solmyr, nil, start.Add(5*time.Minute))
+	if len(alerts) != 1 || alerts[0].Type != domain.OpportunityOpened || alerts[0].GetDuration() != time.Minute {
+		t.Fatalf("expected a late open alert; got %+v", alerts)
+	}
+
+	// Other routes are not affected
+	if alerts := tracker.Update("SOLMYR", append(solmyr, opportunity("SOLMYR", "Luno", "Hata", 1)), nil, start.Add(5*time.Minute)); len(alerts) != 1 {
+		t.Errorf("expected the new route to be alerted; got %v", alertTypes(alerts))
+	}
+}
+
+func TestUpdateAlertsSignificantProfitChanges(t *testing.T) {
+	tracker := NewOpportunityTracker(nil, time.Minute, decimal.NewFromInt64(50))
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	update := func(netProfit int64, at time.Duration) []domain.EventTypeEnum {
+		return alertTypes(tracker.Update("SOLMYR", []domain.ArbitrageOpportunity{opportunity("SOLMYR", "Hata", "Luno", netProfit)}, nil, start.Add(at)))
+	}
+
+	update(4, 0)
+	if got := update(5, 2*time.Minute); len(got) != 0 {
+		t.Fatalf("expected no alert for a 25%% move; got %v", got)
+	}
+	if got := update(6, 3*time.Minute); len(got) != 1 || got[0] != domain.OpportunityUpdated {
+		t.Fatalf("expected an update alert for a 50%% move; got %v", got)
+	}
+	if got := update(1, 3*time.Minute+30*time.Second); len(got) != 0 {
+		t.Fatalf("expected the update within the cooldown to be suppressed; got %v", got)
+	}
+	if got := update(1, 5*time.Minute); len(got) != 1 || got[0] != domain.OpportunityUpdated {
+		t.Fatalf("expected the drop to be alerted after the cooldown; got %v", got)
+	}
+}
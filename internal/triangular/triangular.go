@@ -0,0 +1,165 @@
+package triangular
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"slices"
+	"time"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+// Parameters of a triangular analysis on one exchange.
+type Parameters struct {
+	StartCurrency string          // currency every cycle starts and ends in, e.g. MYR
+	Capital       decimal.Decimal // start currency spent on the first leg
+	TakerFee      decimal.Decimal // charged on what each leg receives
+	Scale         func(currency string) int
+}
+
+// maxResizes bounds how often a cycle is retried with less capital when a book is too thin for it.
+const maxResizes = 5
+
+// edge is a trade converting from into to: buying the base with the quote, or selling the base for it.
+type edge struct {
+	orderbook domain.OrderBook
+	side      domain.OrderSideEnum
+	from      string
+	to        string
+}
+
+// Analyze discovers every three leg cycle from the start currency through the order books of a single
+// exchange and returns them ranked by net profit, highest first. Each leg walks its order book as a
+// taker, so the return accounts for depth; a cycle is resized to the capital its thinnest book absorbs.
+func Analyze(orderbooks []domain.OrderBook, params Parameters) (opportunities []domain.TriangularOpportunity) {
+	edges := make(map[string][]edge)
+	for _, orderbook := range orderbooks {
+		base, quote, err := domain.SplitPair(orderbook.Pair)
+		if err != nil {
+			continue
+		}
+		edges[quote] = append(edges[quote], edge{orderbook: orderbook, side: domain.Buy, from: quote, to: base})
+		edges[base] = append(edges[base], edge{orderbook: orderbook, side: domain.Sell, from: base, to: quote})
+	}
+
+	now := time.Now()
+	for _, first := range edges[params.StartCurrency] {
+		for _, second := range edges[first.to] {
+			if second.to == params.StartCurrency || second.to == first.from {
+				continue
+			}
+			for _, third := range edges[second.to] {
+				if third.to != params.StartCurrency {
+					continue
+				}
+				opportunity, ok := evaluate([]edge{first, second, third}, params)
+				if !ok {
+					continue
+				}
+				opportunity.DetectedAt = now
+				opportunities = append(opportunities, opportunity)
+			}
+		}
+	}
+
+	slices.SortStableFunc(opportunities, func(a, b domain.TriangularOpportunity) int {
+		return b.NetProfit.Cmp(a.NetProfit)
+	})
+	return opportunities
+}
+
+// evaluate trades the capital around the cycle, shrinking it while any book runs out of depth.
+func evaluate(cycle []edge, params Parameters) (opportunity domain.TriangularOpportunity, ok bool) {
+	capital := params.Capital
+	for attempt := 0; attempt <= maxResizes && capital.Sign() > 0; attempt++ {
+		legs := make([]domain.TriangularLeg, 0, len(cycle))
+		amount := capital
+		resized := false
+		for _, edge := range cycle {
+			leg, exhausted := convert(edge, amount, params)
+			if leg.Input.Sign() <= 0 || leg.Output.Sign() <= 0 {
+				return opportunity, false
+			}
+			if exhausted {
+				// Scale the capital down to the share of this leg's input the book could absorb
+				capital = domain.RoundDown(domain.Quo(capital.Mul(leg.Input), amount, domain.DefaultScale), params.Scale(params.StartCurrency))
+				resized = true
+				break
+			}
+			legs = append(legs, leg)
+			amount = leg.Output
+		}
+		if resized {
+			continue
+		}
+
+		opportunity = domain.TriangularOpportunity{
+			Exchange:    cycle[0].orderbook.Exchange.String(),
+			Path:        []string{cycle[0].from, cycle[0].to, cycle[1].to, cycle[2].to},
+			Legs:        legs,
+			StartAmount: legs[0].Input,
+			EndAmount:   legs[len(legs)-1].Output,
+		}
+		opportunity.NetProfit = opportunity.EndAmount.Sub(opportunity.StartAmount)
+		opportunity.ReturnPercentage = domain.Quo(opportunity.NetProfit.MulInt64(100), opportunity.StartAmount, 4)
+		opportunity.Profitable = opportunity.NetProfit.Sign() > 0
+		return opportunity, true
+	}
+	return opportunity, false
+}
+
+// convert spends amount of the edge's from currency against its order book. exhausted reports that
+// the book ran out of levels before the whole amount was spent.
+func convert(edge edge, amount decimal.Decimal, params Parameters) (leg domain.TriangularLeg, exhausted bool) {
+	fromScale, toScale := params.Scale(edge.from), params.Scale(edge.to)
+	leg = domain.TriangularLeg{Pair: edge.orderbook.Pair, Side: edge.side, From: edge.from, To: edge.to}
+
+	if edge.side == domain.Buy && len(edge.orderbook.Asks) == 0 {
+		return leg, false
+	}
+
+	spent, received, volume := decimal.Zero(), decimal.Zero(), decimal.Zero()
+	remaining := amount
+	if edge.side == domain.Buy {
+		// Spending the quote currency on the asks, receiving the base currency
+		for _, ask := range edge.orderbook.Asks {
+			if remaining.Sign() <= 0 {
+				break
+			}
+			levelVolume := domain.MinDecimal(ask.Volume, remaining.Div(ask.Price, toScale))
+			if levelVolume.Sign() <= 0 {
+				break
+			}
+			cost := ask.Price.Mul(levelVolume)
+			spent, received, volume = spent.Add(cost), received.Add(levelVolume), volume.Add(levelVolume)
+			remaining = remaining.Sub(cost)
+			leg.Orders = append(leg.Orders, domain.PriceLevel{Price: ask.Price, Volume: levelVolume})
+		}
+		leg.Input = domain.RoundUp(spent, fromScale)
+		exhausted = len(leg.Orders) == len(edge.orderbook.Asks) && remaining.Div(edge.orderbook.Asks[len(edge.orderbook.Asks)-1].Price, toScale).Sign() > 0
+	} else {
+		// Selling the base currency on the bids, receiving the quote currency
+		for _, bid := range edge.orderbook.Bids {
+			if remaining.Sign() <= 0 {
+				break
+			}
+			levelVolume := domain.MinDecimal(bid.Volume, remaining)
+			spent, received, volume = spent.Add(levelVolume), received.Add(bid.Price.Mul(levelVolume)), volume.Add(levelVolume)
+			remaining = remaining.Sub(levelVolume)
+			leg.Orders = append(leg.Orders, domain.PriceLevel{Price: bid.Price, Volume: levelVolume})
+		}
+		leg.Input = spent
+		exhausted = remaining.Sign() > 0
+	}
+	if volume.Sign() <= 0 {
+		return leg, false
+	}
+
+	if edge.side == domain.Buy {
+		leg.Price = domain.Quo(spent, volume, domain.DefaultScale)
+	} else {
+		leg.Price = domain.Quo(received, volume, domain.DefaultScale)
+	}
+	leg.Fee = domain.RoundUp(received.Mul(params.TakerFee), toScale)
+	leg.Output = domain.RoundDown(received.Sub(leg.Fee), toScale)
+	return leg, exhausted
+}
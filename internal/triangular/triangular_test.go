@@ -0,0 +1,87 @@
+package triangular
+
+import (
+	"malaysia-crypto-exchange-arbitrage/internal/domain"
+	"testing"
+
+	"github.com/luno/luno-go/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func book(pair string, ask string, askVolume string, bid string, bidVolume string) domain.OrderBook {
+	return domain.OrderBook{
+		Exchange: domain.Luno,
+		Pair:     pair,
+		Asks:     []domain.PriceLevel{{Price: dec(ask), Volume: dec(askVolume)}},
+		Bids:     []domain.PriceLevel{{Price: dec(bid), Volume: dec(bidVolume)}},
+	}
+}
+
+func parameters(capital string, fee string) Parameters {
+	scales := map[string]int{"MYR": 2, "USDT": 2, "SOL": 4}
+	return Parameters{
+		StartCurrency: "MYR",
+		Capital:       dec(capital),
+		TakerFee:      dec(fee),
+		Scale:         func(currency string) int { return scales[currency] },
+	}
+}
+
+// SOL is cheap in USDT: MYR -> USDT -> SOL -> MYR returns 4.2 * 50 / 200 = 5% before fees.
+func orderbooks() []domain.OrderBook {
+	return []domain.OrderBook{
+		book("USDTMYR", "4.2", "10000", "4.19", "10000"),
+		book("SOLUSDT", "50", "100", "49.9", "100"),
+		book("SOLMYR", "221", "100", "220.5", "100"),
+	}
+}
+
+func TestAnalyzeFindsBothDirections(t *testing.T) {
+	opportunities := Analyze(orderbooks(), parameters("420", "0"))
+	if len(opportunities) != 2 {
+		t.Fatalf("expected the cycle in both directions, got %d", len(opportunities))
+	}
+
+	best := opportunities[0]
+	if best.GetRoute() != "MYR>USDT>SOL>MYR" || best.Exchange != "Luno" || !best.Profitable {
+		t.Fatalf("unexpected best cycle %s on %s", best.GetRoute(), best.Exchange)
+	}
+	// 420 MYR -> 100 USDT -> 2 SOL -> 441 MYR
+	if best.StartAmount.Cmp(dec("420")) != 0 || best.EndAmount.Cmp(dec("441")) != 0 || best.ReturnPercentage.Cmp(dec("5")) != 0 {
+		t.Errorf("expected 420 -> 441 (5%%), got %v -> %v (%v%%)", best.StartAmount, best.EndAmount, best.ReturnPercentage)
+	}
+	if opportunities[1].Profitable {
+		t.Errorf("expected the reverse cycle to lose, got %v", opportunities[1].NetProfit)
+	}
+}
+
+func TestAnalyzeDeductsTakerFees(t *testing.T) {
+	best := Analyze(orderbooks(), parameters("420", "0.01"))[0]
+	// 100 USDT - 1 fee = 99 -> 1.98 SOL - 0.0198 = 1.9602 -> 432.2241 MYR - 4.33 fee rounded up = 427.89
+	if best.EndAmount.Cmp(dec("427.89")) != 0 {
+		t.Errorf("expected 427.89 after fees, got %v", best.EndAmount)
+	}
+	if best.Legs[1].Side != domain.Buy || best.Legs[2].Side != domain.Sell || best.Legs[0].Fee.Cmp(dec("1")) != 0 {
+		t.Errorf("unexpected legs %+v", best.Legs)
+	}
+}
+
+func TestAnalyzeResizesToBookDepth(t *testing.T) {
+	books := orderbooks()
+	books[1] = book("SOLUSDT", "50", "1", "49.9", "100") // only 1 SOL, 50 USDT, on offer
+
+	best := Analyze(books, parameters("4200", "0"))[0]
+	if best.GetRoute() != "MYR>USDT>SOL>MYR" {
+		t.Fatalf("unexpected best cycle %s", best.GetRoute())
+	}
+	if best.StartAmount.Cmp(dec("210")) != 0 || best.Legs[1].Orders[0].Volume.Cmp(dec("1")) != 0 {
+		t.Errorf("expected the cycle resized to 210 MYR for 1 SOL, got %v for %+v", best.StartAmount, best.Legs[1].Orders)
+	}
+}